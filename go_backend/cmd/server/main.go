@@ -7,14 +7,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/xiaozhi-esp32-server/go_backend/internal/config"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/conversation"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/handlers"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/intent"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/knowledge"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/moderation"
 	internalmqtt "github.com/xiaozhi-esp32-server/go_backend/internal/mqtt"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/usage"
 )
 
 func main() {
@@ -34,43 +41,293 @@ func main() {
 	ttsManager := tts.NewTTSManager()
 	
 	// 注册TTS提供商
-	if cfg.TTSProvider == "douban" && cfg.DoubanAPIKey != "" {
-		doubanProvider := tts.NewDoubanTTSProvider(cfg.DoubanAPIKey)
-		ttsManager.RegisterProvider("douban", doubanProvider)
-		ttsManager.SetDefaultProvider("douban")
+	if ttsProvider, ok := newTTSProvider(cfg, cfg.TTSProvider); ok {
+		ttsManager.RegisterProvider(cfg.TTSProvider, ttsProvider)
+		ttsManager.SetDefaultProvider(cfg.TTSProvider)
 	} else {
 		// 默认使用模拟TTS提供商
 		mockProvider := tts.NewMockProvider()
 		ttsManager.RegisterProvider("mock", mockProvider)
 	}
 	
+	// 注册备用TTS提供商，主提供商失败或超时时按顺序切换
+	if len(cfg.TTSFallback) > 0 {
+		for _, name := range cfg.TTSFallback {
+			if _, err := ttsManager.GetProvider(name); err == nil {
+				continue // 已注册
+			}
+			ttsProvider, ok := newTTSProvider(cfg, name)
+			if !ok {
+				log.Printf("Warning: fallback TTS provider '%s' is unknown or not configured, skipped", name)
+				continue
+			}
+			ttsManager.RegisterProvider(name, ttsProvider)
+		}
+		
+		var chain []string
+		for _, name := range cfg.TTSFallback {
+			if _, err := ttsManager.GetProvider(name); err == nil {
+				chain = append(chain, name)
+			}
+		}
+		if err := ttsManager.SetFallbackChain(chain...); err != nil {
+			log.Printf("Warning: Failed to set TTS fallback chain: %v", err)
+		}
+	}
+	for _, spec := range cfg.TTSVoiceMap {
+		voice, provider, mapped, err := tts.ParseVoiceMapping(spec)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		ttsManager.SetVoiceMapping(voice, provider, mapped)
+	}
+	ttsManager.SetTimeout(time.Duration(cfg.TTSTimeout) * time.Second)
+	if cfg.TTSCacheSize > 0 {
+		ttsManager.SetCache(tts.NewAudioCache(cfg.TTSCacheSize << 20))
+	}
+	
 	// 初始化TTS管理器
 	if err := ttsManager.Initialize(); err != nil {
 		log.Printf("Warning: Failed to initialize TTS: %v", err)
 	} else {
 		log.Printf("TTS manager initialized with provider: %s", cfg.TTSProvider)
 	}
+	defer ttsManager.Cleanup()
 	
 	// 初始化 LLM 管理器
 	llmManager := llm.NewLLMManager()
 	
-	// 注册LLM提供商
+	// 注册LLM提供商，配置了录制文件时包装一层录制器，录下的文件可用 scripted 提供商回放
+	var script *llm.Script
+	if cfg.LLMProvider == "scripted" && cfg.LLMScript != "" {
+		var err error
+		if script, err = llm.LoadScript(cfg.LLMScript); err != nil {
+			log.Printf("Warning: Failed to load LLM script: %v", err)
+		}
+	}
 	if cfg.LLMProvider == "deepseek" && cfg.DeepseekAPIKey != "" {
-		deepseekProvider := llm.NewDeepseekProvider(cfg.DeepseekAPIKey, cfg.DeepseekModel)
+		var deepseekProvider llm.Provider = llm.NewDeepseekProvider(cfg.DeepseekAPIKey, cfg.DeepseekModel)
+		if cfg.LLMRecord != "" {
+			if recorder, err := llm.NewRecordingProvider("deepseek", deepseekProvider, cfg.LLMRecord); err != nil {
+				log.Printf("Warning: LLM recording disabled: %v", err)
+			} else {
+				deepseekProvider = recorder
+			}
+		}
 		llmManager.RegisterProvider("deepseek", deepseekProvider)
 		llmManager.SetDefaultProvider("deepseek")
+	} else if cfg.LLMProvider == "anthropic" && cfg.AnthropicAPIKey != "" {
+		var anthropicProvider llm.Provider = newAnthropicProvider(cfg)
+		if cfg.LLMRecord != "" {
+			if recorder, err := llm.NewRecordingProvider("anthropic", anthropicProvider, cfg.LLMRecord); err != nil {
+				log.Printf("Warning: LLM recording disabled: %v", err)
+			} else {
+				anthropicProvider = recorder
+			}
+		}
+		llmManager.RegisterProvider("anthropic", anthropicProvider)
+		llmManager.SetDefaultProvider("anthropic")
+	} else if script != nil {
+		llmManager.RegisterProvider("scripted", llm.NewScriptedProvider(script))
+		llmManager.SetDefaultProvider("scripted")
 	} else {
 		// 默认使用模拟LLM提供商
 		mockProvider := llm.NewMockProvider("模拟大语言模型")
 		llmManager.RegisterProvider("mock", mockProvider)
 	}
 	
+	// 注册备用LLM提供商，主提供商出现超时、限流或服务端错误时按顺序切换
+	if len(cfg.LLMFallback) > 0 {
+		for _, name := range cfg.LLMFallback {
+			if _, err := llmManager.GetProvider(name); err == nil {
+				continue // 已注册
+			}
+			switch name {
+			case "deepseek":
+				if cfg.DeepseekAPIKey == "" {
+					log.Printf("Warning: fallback provider 'deepseek' requires DEEPSEEK_API_KEY, skipped")
+					continue
+				}
+				llmManager.RegisterProvider(name, llm.NewDeepseekProvider(cfg.DeepseekAPIKey, cfg.DeepseekModel))
+			case "anthropic":
+				if cfg.AnthropicAPIKey == "" {
+					log.Printf("Warning: fallback provider 'anthropic' requires ANTHROPIC_API_KEY, skipped")
+					continue
+				}
+				llmManager.RegisterProvider(name, newAnthropicProvider(cfg))
+			case "mock":
+				llmManager.RegisterProvider(name, llm.NewMockProvider("模拟大语言模型"))
+			default:
+				log.Printf("Warning: unknown fallback LLM provider: %s", name)
+			}
+		}
+		
+		var chain []string
+		for _, name := range cfg.LLMFallback {
+			if _, err := llmManager.GetProvider(name); err == nil {
+				chain = append(chain, name)
+			}
+		}
+		if err := llmManager.SetFallbackChain(chain...); err != nil {
+			log.Printf("Warning: Failed to set LLM fallback chain: %v", err)
+		}
+	}
+	
 	// 初始化LLM管理器
 	if err := llmManager.Initialize(); err != nil {
 		log.Printf("Warning: Failed to initialize LLM: %v", err)
 	} else {
 		log.Printf("LLM manager initialized with provider: %s", cfg.LLMProvider)
 	}
+	
+	// 启动LLM健康探测，不健康的提供商会被暂时摘除
+	llmManager.StartHealthChecks(time.Duration(cfg.LLMHealthCheckInterval) * time.Second)
+	defer llmManager.StopHealthChecks()
+
+	// 初始化意图识别管理器
+	intentManager := intent.NewIntentManager()
+	for _, name := range cfg.IntentProviders {
+		switch name {
+		case "nointent":
+			intentManager.RegisterProvider(name, intent.NewNoIntentProvider())
+		case "keyword":
+			intentManager.RegisterProvider(name, intent.NewKeywordProvider(nil))
+		case "llm":
+			// 配置了独立的小模型时使用它，否则复用主LLM
+			if cfg.IntentLLMModel != "" && cfg.DeepseekAPIKey != "" {
+				intentLLM := llm.NewDeepseekProvider(cfg.DeepseekAPIKey, cfg.IntentLLMModel)
+				if err := intentLLM.Initialize(); err != nil {
+					log.Printf("Warning: Failed to initialize intent LLM: %v", err)
+					continue
+				}
+				intentManager.RegisterProvider(name, intent.NewLLMProvider(intentLLM))
+			} else {
+				intentManager.RegisterProvider(name, intent.NewLLMProvider(llmManager))
+			}
+		default:
+			log.Printf("Warning: unknown intent provider: %s", name)
+		}
+	}
+	
+	if err := intentManager.Initialize(); err != nil {
+		log.Printf("Warning: Failed to initialize intent recognition: %v", err)
+	} else {
+		log.Printf("Intent manager initialized with providers: %v", cfg.IntentProviders)
+	}
+
+	// 初始化内容安全审核，命中的事件上报到 MQTT 供家长查看
+	var moderationManager *moderation.ModerationManager
+	if len(cfg.ModerationProviders) > 0 {
+		moderationManager = moderation.NewModerationManager()
+		for _, name := range cfg.ModerationProviders {
+			switch name {
+			case "keyword":
+				rules := moderation.DefaultRules()
+				if cfg.ModerationBlocklist != "" {
+					extraRules, err := moderation.LoadRules(cfg.ModerationBlocklist)
+					if err != nil {
+						log.Printf("Warning: Failed to load moderation blocklist: %v", err)
+					} else {
+						rules = append(rules, extraRules...)
+					}
+				}
+				moderationManager.RegisterProvider(name, moderation.NewKeywordProvider(rules))
+			case "llm":
+				// 配置了独立的小模型时使用它，否则复用主LLM
+				if cfg.ModerationLLMModel != "" && cfg.DeepseekAPIKey != "" {
+					moderationLLM := llm.NewDeepseekProvider(cfg.DeepseekAPIKey, cfg.ModerationLLMModel)
+					if err := moderationLLM.Initialize(); err != nil {
+						log.Printf("Warning: Failed to initialize moderation LLM: %v", err)
+						continue
+					}
+					moderationManager.RegisterProvider(name, moderation.NewLLMProvider(moderationLLM))
+				} else {
+					moderationManager.RegisterProvider(name, moderation.NewLLMProvider(llmManager))
+				}
+			default:
+				log.Printf("Warning: unknown moderation provider: %s", name)
+			}
+		}
+		moderationManager.SetPublisher(mqttClient, cfg.ModerationTopic)
+		
+		if err := moderationManager.Initialize(); err != nil {
+			log.Printf("Warning: Failed to initialize moderation: %v", err)
+			moderationManager = nil
+		} else {
+			log.Printf("Moderation manager initialized with providers: %v", cfg.ModerationProviders)
+		}
+	}
+
+	// 初始化视觉模型，用于回答设备照片相关的问题
+	var visionManager *llm.LLMManager
+	switch cfg.VisionProvider {
+	case "openai":
+		if cfg.VisionAPIKey != "" && cfg.VisionModel != "" {
+			visionProvider := llm.NewDeepseekProvider(cfg.VisionAPIKey, cfg.VisionModel)
+			visionProvider.SetEndpoint(cfg.VisionAPIEndpoint)
+			visionProvider.EnableVision()
+			switch cfg.VisionStreamUsage {
+			case "true":
+				visionProvider.SetStreamUsage(true)
+			case "false":
+				visionProvider.SetStreamUsage(false)
+			}
+			visionManager = llm.NewLLMManager()
+			visionManager.RegisterProvider("vision", visionProvider)
+		}
+	case "mock":
+		visionManager = llm.NewLLMManager()
+		visionManager.RegisterProvider("vision", llm.NewMockProvider("模拟视觉模型"))
+	case "off", "":
+	default:
+		log.Printf("Warning: unknown vision provider: %s", cfg.VisionProvider)
+	}
+	if visionManager != nil {
+		if err := visionManager.Initialize(); err != nil {
+			log.Printf("Warning: Failed to initialize vision model: %v", err)
+			visionManager = nil
+		} else {
+			log.Printf("Vision model initialized with provider: %s", cfg.VisionProvider)
+		}
+	}
+
+	// 初始化本地知识库，每轮对话检索相关片段附加到提示词中
+	var knowledgeBase *knowledge.Base
+	if cfg.KnowledgeDir != "" {
+		var embedder knowledge.Embedder
+		if cfg.KnowledgeEmbeddingEndpoint != "" {
+			embedder = knowledge.NewOpenAIEmbedder(cfg.KnowledgeEmbeddingAPIKey, cfg.KnowledgeEmbeddingEndpoint, cfg.KnowledgeEmbeddingModel)
+		}
+		knowledgeBase = knowledge.NewBase(cfg.KnowledgeDir, cfg.KnowledgeChunkSize, embedder)
+		if err := knowledgeBase.Load(); err != nil {
+			log.Printf("Warning: Failed to load knowledge base: %v", err)
+			knowledgeBase = nil
+		}
+	}
+
+	// 初始化令牌用量统计，计数器持久化到数据目录
+	usageTracker := usage.NewTracker(filepath.Join(cfg.DataDir, "usage.json"), usage.Quota{
+		Daily:   int64(cfg.UsageDailyQuota),
+		Monthly: int64(cfg.UsageMonthlyQuota),
+	})
+	if err := usageTracker.Load(); err != nil {
+		log.Printf("Warning: Failed to load usage: %v", err)
+	}
+	for _, spec := range cfg.UsageDeviceQuotas {
+		deviceID, quota, err := usage.ParseQuotaSpec(spec)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		usageTracker.SetDeviceQuota(deviceID, quota)
+	}
+	usageTracker.StartAutoSave(time.Minute)
+	defer func() {
+		if err := usageTracker.Close(); err != nil {
+			log.Printf("Warning: Failed to save usage: %v", err)
+		}
+	}()
 
 	// 确保资源正常清理
 	defer func() {
@@ -82,9 +339,64 @@ func main() {
 		}
 	}()
 
+	// 预先合成延迟提示语，模型首字较慢时立即播放
+	var fillerCache *conversation.FillerCache
+	if len(cfg.FillerPhrases) > 0 {
+		fillerCache = conversation.NewFillerCache(ttsManager, cfg.FillerPhrases)
+	}
+	
+	// 预先合成语音故障提示，所有TTS提供商都失败时播放
+	var ttsErrorCache *conversation.FillerCache
+	if cfg.TTSErrorPrompt != "" {
+		ttsErrorCache = conversation.NewFillerCache(ttsManager, []string{cfg.TTSErrorPrompt})
+	}
+
+	// 按设备保存的声音设置（音色、语速、音调、音量），设备重新连接后沿用
+	voiceStore := conversation.NewVoiceStore(filepath.Join(cfg.DataDir, "voices.json"))
+	if err := voiceStore.Load(); err != nil {
+		log.Printf("Warning: Failed to load voice settings: %v", err)
+	}
+
+	// 多角色朗读时指定的说话人音色
+	speakerVoices := make(map[string]string)
+	for _, spec := range cfg.SpeakerVoices {
+		speaker, voice, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(speaker) == "" || strings.TrimSpace(voice) == "" {
+			log.Printf("Warning: Invalid speaker voice %q, expected speaker=voice", spec)
+			continue
+		}
+		speakerVoices[strings.ToLower(strings.TrimSpace(speaker))] = strings.TrimSpace(voice)
+	}
+
 	// 设置 HTTP 路由
 	// 主要的 WebSocket 路由
-	http.HandleFunc("/xiaozhi/v1/", handlers.WebSocketHandler(mqttClient, llmManager, ttsManager))
+	conversationOptions := conversation.Options{
+		ReasoningFiller: cfg.ReasoningFiller,
+		Intent:          intentManager,
+		MusicDir:        cfg.MusicDir,
+		Usage:           usageTracker,
+		QuotaMessage:    cfg.QuotaMessage,
+		Vision:          visionManager,
+		Moderation:      moderationManager,
+		Knowledge:       knowledgeBase,
+		KnowledgeTopK:   cfg.KnowledgeTopK,
+		Fillers:         fillerCache,
+		FillerDelay:     time.Duration(cfg.FillerDelay) * time.Millisecond,
+		SpeechBudget:    cfg.SpeechBudget,
+		ContinuePrompt:  cfg.ContinuePrompt,
+		TTSErrorPrompt:  ttsErrorCache,
+		Voices:          voiceStore,
+		SpeakerVoices:   speakerVoices,
+	}
+	// 访问令牌，WebSocket 连接、照片上传、用量查询和语音合成使用同一套校验
+	auth := handlers.NewAuthenticator(cfg.AuthTokens)
+	if !auth.Enabled() {
+		log.Printf("Warning: AUTH_TOKENS not set, device and API endpoints accept unauthenticated requests")
+	}
+	http.HandleFunc("/xiaozhi/v1/", handlers.WebSocketHandler(mqttClient, llmManager, ttsManager, conversationOptions, auth))
+	
+	// 设备照片上传端点，照片关联到设备当前的会话
+	http.HandleFunc("/api/vision/upload", handlers.VisionUploadHandler(auth))
 	
 	// 健康检查端点
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -107,6 +419,8 @@ func main() {
 			Version           string    `json:"version"`
 			TTSProvider       string    `json:"tts_provider"`
 			LLMProvider       string    `json:"llm_provider"`
+			LLMProviders      []llm.ProviderStatus `json:"llm_providers"`
+			TTSCache          *tts.CacheStats      `json:"tts_cache,omitempty"`
 		}{
 			Status:            "running",
 			ActiveConnections: handlers.GetActiveConnectionsCount(),
@@ -116,6 +430,10 @@ func main() {
 			Version:           "1.0.0", 
 			TTSProvider:       cfg.TTSProvider,
 			LLMProvider:       cfg.LLMProvider,
+			LLMProviders:      llmManager.ProviderStatuses(),
+		}
+		if cacheStats, ok := ttsManager.CacheStats(); ok {
+			statusInfo.TTSCache = &cacheStats
 		}
 		
 		// 将状态信息编码为 JSON 并写入响应
@@ -124,29 +442,39 @@ func main() {
 		}
 	})
 
-	// TTS API 端点
-	http.HandleFunc("/api/tts/voices", func(w http.ResponseWriter, r *http.Request) {
+	// 令牌用量端点，可通过 device_id 参数查询单个设备，列出了所有设备 ID，需要访问令牌
+	http.HandleFunc("/api/usage", auth.Require(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		
-		// 获取当前默认TTS提供商的声音列表
-		provider, err := ttsManager.GetProvider(cfg.TTSProvider)
-		if err != nil {
-			http.Error(w, "Failed to get TTS provider: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		
-		voices, err := provider.GetVoices()
-		if err != nil {
-			http.Error(w, "Failed to get voices: "+err.Error(), http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		if deviceID := r.URL.Query().Get("device_id"); deviceID != "" {
+			deviceUsage, ok := usageTracker.Device(deviceID)
+			if !ok {
+				http.Error(w, "Device not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(deviceUsage)
 			return
 		}
 		
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(voices)
-	})
+		json.NewEncoder(w).Encode(struct {
+			Devices   map[string]usage.DeviceUsage `json:"devices"`
+			Providers map[string]usage.Counter     `json:"providers"`
+		}{
+			Devices:   usageTracker.Devices(),
+			Providers: usageTracker.Providers(),
+		})
+	}))
+
+	// TTS API 端点：所有提供商的音色目录和音色试听，试听会调用付费的合成服务，需要访问令牌
+	http.HandleFunc("/api/tts/voices", handlers.TTSVoicesHandler(ttsManager))
+	http.HandleFunc("/api/tts/voices/{id}/preview", auth.Require(handlers.TTSVoicePreviewHandler(ttsManager)))
+	
+	// 文本合成端点，供运维生成广播音频和测试音色，需要访问令牌
+	http.HandleFunc("/api/tts/synthesize", auth.Require(handlers.TTSSynthesizeHandler(ttsManager, cfg.TTSAPIMaxChars)))
 
 	// 创建用于监听操作系统信号的通道（如 Ctrl+C）
 	sigChan := make(chan os.Signal, 1)
@@ -181,4 +509,51 @@ func main() {
 	// 短暂延迟，允许正在进行的操作（如 MQTT 断开连接）完成
 	time.Sleep(500 * time.Millisecond)
 	log.Println("服务器已优雅停止。")
-} 
\ No newline at end of file
+} 
+// newTTSProvider 按配置创建指定名称的 TTS 提供商，未知名称或缺少必要配置时返回 false
+func newTTSProvider(cfg *config.Config, name string) (tts.Provider, bool) {
+	switch name {
+	case "volcengine":
+		if cfg.VolcengineTTSAppID == "" || cfg.VolcengineTTSToken == "" {
+			return nil, false
+		}
+		volcengineProvider := tts.NewVolcengineTTSProvider(cfg.VolcengineTTSAppID, cfg.VolcengineTTSToken, cfg.VolcengineTTSCluster)
+		volcengineProvider.SetMode(cfg.VolcengineTTSMode)
+		volcengineProvider.SetDefaultVoice(cfg.VolcengineTTSVoice)
+		if cfg.VolcengineTTSMode == tts.VolcengineModeWebSocket {
+			volcengineProvider.SetEndpoints("", cfg.VolcengineTTSEndpoint)
+		} else {
+			volcengineProvider.SetEndpoints(cfg.VolcengineTTSEndpoint, "")
+		}
+		return volcengineProvider, true
+	case "local":
+		if cfg.LocalTTSCommand == "" {
+			return nil, false
+		}
+		localProvider := tts.NewLocalTTSProvider(cfg.LocalTTSCommand)
+		localProvider.SetOutput(cfg.LocalTTSOutput, cfg.LocalTTSSampleRate)
+		localProvider.SetVoices(cfg.LocalTTSVoice, cfg.LocalTTSVoices)
+		localProvider.SetPoolSize(cfg.LocalTTSPoolSize)
+		return localProvider, true
+	case "custom":
+		if cfg.CustomTTSURL == "" {
+			return nil, false
+		}
+		customProvider := tts.NewCustomTTSProvider(cfg.CustomTTSURL)
+		customProvider.SetRequest(cfg.CustomTTSMethod, cfg.CustomTTSHeaders, cfg.CustomTTSBody)
+		customProvider.SetResponse(cfg.CustomTTSResponse)
+		customProvider.SetVoices(cfg.CustomTTSVoice, cfg.CustomTTSVoices)
+		customProvider.SetSSML(cfg.CustomTTSSSML)
+		return customProvider, true
+	case "mock":
+		return tts.NewMockProvider(), true
+	}
+	return nil, false
+}
+
+// newAnthropicProvider 按配置创建 Anthropic 提供商
+func newAnthropicProvider(cfg *config.Config) *llm.AnthropicProvider {
+	provider := llm.NewAnthropicProvider(cfg.AnthropicAPIKey, cfg.AnthropicModel)
+	provider.SetEndpoint(cfg.AnthropicEndpoint)
+	return provider
+}
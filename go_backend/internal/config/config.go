@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config 结构体包含应用程序的所有配置项
@@ -15,15 +18,87 @@ type Config struct {
 
 	// 服务器配置
 	ServerPort string
+	AuthTokens []string // 设备访问令牌，设备以 Authorization: Bearer <令牌> 请求头携带，为空时不校验
 
 	// TTS配置
-	TTSProvider    string // 默认TTS提供商 (mock, douban)
-	DoubanAPIKey   string // 豆包API密钥
+	TTSProvider    string // 默认TTS提供商 (mock, volcengine, local, custom)，douban 是 volcengine 的别名
+	VolcengineTTSAppID    string // 火山引擎语音合成 appid
+	VolcengineTTSToken    string // 火山引擎语音合成 access token
+	VolcengineTTSCluster  string // 火山引擎语音合成集群
+	VolcengineTTSVoice    string // 火山引擎默认音色（voice_type）
+	VolcengineTTSMode     string // 火山引擎传输方式 (http, websocket)
+	VolcengineTTSEndpoint string // 火山引擎接口地址，按传输方式填写 HTTP 或 WebSocket 地址
+	LocalTTSCommand    string   // 本地引擎命令模板，文本从标准输入写入
+	LocalTTSOutput     string   // 本地引擎输出格式 (wav, pcm)
+	LocalTTSSampleRate int      // 本地引擎输出 PCM 时的采样率
+	LocalTTSVoice      string   // 本地引擎默认音色，替换命令模板中的 {voice}
+	LocalTTSVoices     []string // 本地引擎可用音色列表
+	LocalTTSPoolSize   int      // 预先启动的本地引擎进程数
+	CustomTTSURL      string            // 自定义 TTS 服务地址模板
+	CustomTTSMethod   string            // 自定义 TTS 请求方法
+	CustomTTSHeaders  map[string]string // 自定义 TTS 请求头，环境变量中为 JSON 对象
+	CustomTTSBody     string            // 自定义 TTS 请求体模板
+	CustomTTSResponse string            // 自定义 TTS 响应解析方式 (raw, json:字段路径, url:字段路径)
+	CustomTTSVoice    string            // 自定义 TTS 默认音色，替换模板中的 {voice}
+	CustomTTSVoices   []string          // 自定义 TTS 可用音色列表
+	CustomTTSSSML     bool              // 自定义 TTS 服务是否接受 SSML
+	TTSFallback       []string          // 备用TTS提供商链，按顺序尝试
+	TTSTimeout        int               // 单句合成超时（秒），超时后切换到下一个提供商
+	TTSVoiceMap       []string          // 切换提供商时的音色映射，格式为 音色=提供商:音色
+	TTSErrorPrompt    string            // 所有TTS提供商都失败时播放的提示，启动时预先合成，设为 off 关闭
+	TTSCacheSize      int               // 合成结果缓存大小（MB），0 表示不缓存
+	TTSAPIMaxChars    int               // 合成接口单次请求的最大字数
+	SpeakerVoices     []string          // 多角色朗读时说话人使用的音色，格式为 说话人=音色
 
 	// LLM配置
-	LLMProvider    string // 默认LLM提供商 (mock, deepseek)
+	LLMProvider    string // 默认LLM提供商 (mock, deepseek, anthropic, scripted)
 	DeepseekAPIKey string // Deepseek API密钥
 	DeepseekModel  string // Deepseek模型名称
+	AnthropicAPIKey   string // Anthropic API密钥
+	AnthropicModel    string // Anthropic模型名称
+	AnthropicEndpoint string // Anthropic Messages API 地址，为空时使用官方地址
+	LLMFallback    []string // 备用LLM提供商链，按顺序尝试
+	LLMHealthCheckInterval int // LLM健康探测间隔（秒），0表示不探测
+	ReasoningFiller string // 推理模型思考时播放的提示语，设为 off 关闭
+	FillerPhrases  []string // 模型迟迟没有输出时播放的提示语，启动时预先合成，设为 off 关闭
+	FillerDelay    int      // 进入思考状态后多久（毫秒）仍没有可朗读的句子时播放提示语
+	SpeechBudget   int      // 单次回复朗读的最大字数，角色没有单独设置时使用，0 表示不限制
+	ContinuePrompt string   // 回复被截断后询问是否继续的话，为空时使用会话内置的询问
+	LLMScript      string // scripted 提供商使用的脚本文件（YAML 或 JSON）
+	LLMRecord      string // 录制主LLM请求和响应的脚本文件，为空表示不录制
+
+	// 意图识别配置
+	IntentProviders []string // 意图识别链 (nointent, keyword, llm)，按顺序尝试
+	IntentLLMModel  string   // 意图识别使用的小模型，为空时复用主LLM
+	MusicDir        string   // 播放音乐意图使用的本地音乐目录
+
+	// 用量统计与配额
+	DataDir           string   // 持久化数据目录
+	UsageDailyQuota   int      // 每台设备每日令牌配额，0 表示不限制
+	UsageMonthlyQuota int      // 每台设备每月令牌配额，0 表示不限制
+	UsageDeviceQuotas []string // 单独设置的设备配额，格式为 设备ID:每日:每月
+	QuotaMessage      string   // 超出配额时朗读的提示
+
+	// 视觉模型配置
+	VisionProvider    string // 视觉模型提供商 (off, openai, mock)
+	VisionAPIKey      string // OpenAI 兼容视觉接口的 API 密钥
+	VisionAPIEndpoint string // OpenAI 兼容视觉接口的对话地址
+	VisionModel       string // 视觉模型名称
+	VisionStreamUsage string // 流式请求是否要求返回用量 (auto, true, false)，auto 表示只对 Deepseek 官方地址开启
+
+	// 内容安全配置
+	ModerationProviders []string // 内容审核链 (keyword, llm)，为空表示不审核
+	ModerationBlocklist string   // 额外的屏蔽词文件
+	ModerationLLMModel  string   // 审核分类使用的小模型，为空时复用主LLM
+	ModerationTopic     string   // 审核事件上报的 MQTT 主题前缀
+	
+	// 知识库配置
+	KnowledgeDir               string // 知识库文档目录，为空表示不启用
+	KnowledgeTopK              int    // 每轮附加到提示词中的片段数
+	KnowledgeChunkSize         int    // 单个片段的目标字数
+	KnowledgeEmbeddingEndpoint string // OpenAI 兼容的向量化接口地址，为空时只用 BM25 检索
+	KnowledgeEmbeddingAPIKey   string // 向量化接口的 API 密钥
+	KnowledgeEmbeddingModel    string // 向量化模型名称
 }
 
 // LoadConfig 从环境变量加载配置
@@ -37,16 +112,135 @@ func LoadConfig() *Config {
 
 		// 服务器默认值
 		ServerPort: getEnv("SERVER_PORT", "8000"),
+		AuthTokens: getEnvList("AUTH_TOKENS"),
 
 		// TTS 默认值
-		TTSProvider:  getEnv("TTS_PROVIDER", "mock"),
-		DoubanAPIKey: getEnv("DOUBAN_API_KEY", ""),
+		TTSProvider:  ttsProviderName(getEnv("TTS_PROVIDER", "mock")),
+		VolcengineTTSAppID:    getEnv("VOLCENGINE_TTS_APPID", ""),
+		VolcengineTTSToken:    getEnv("VOLCENGINE_TTS_TOKEN", ""),
+		VolcengineTTSCluster:  getEnv("VOLCENGINE_TTS_CLUSTER", "volcano_tts"),
+		VolcengineTTSVoice:    getEnv("VOLCENGINE_TTS_VOICE", "BV001_streaming"),
+		VolcengineTTSMode:     getEnv("VOLCENGINE_TTS_MODE", "http"),
+		VolcengineTTSEndpoint: getEnv("VOLCENGINE_TTS_ENDPOINT", ""),
 
 		// LLM 默认值
 		LLMProvider:    getEnv("LLM_PROVIDER", "mock"),
 		DeepseekAPIKey: getEnv("DEEPSEEK_API_KEY", ""),
 		DeepseekModel:  getEnv("DEEPSEEK_MODEL", "deepseek-chat"),
+		AnthropicAPIKey:   getEnv("ANTHROPIC_API_KEY", ""),
+		AnthropicModel:    getEnv("ANTHROPIC_MODEL", "claude-3-5-haiku-latest"),
+		AnthropicEndpoint: getEnv("ANTHROPIC_API_ENDPOINT", ""),
+		LLMFallback:    getEnvList("LLM_FALLBACK"),
+		LLMHealthCheckInterval: getEnvInt("LLM_HEALTH_CHECK_INTERVAL", 30),
+		ReasoningFiller: getEnv("REASONING_FILLER", "嗯，让我想一想。"),
+		LLMScript:      getEnv("LLM_SCRIPT", ""),
+		LLMRecord:      getEnv("LLM_RECORD", ""),
+	}
+	
+	// 本地 TTS 默认值
+	config.LocalTTSCommand = getEnv("LOCAL_TTS_COMMAND", "")
+	config.LocalTTSOutput = getEnv("LOCAL_TTS_OUTPUT", "wav")
+	config.LocalTTSSampleRate = getEnvInt("LOCAL_TTS_SAMPLE_RATE", 22050)
+	config.LocalTTSVoice = getEnv("LOCAL_TTS_VOICE", "")
+	config.LocalTTSVoices = getEnvList("LOCAL_TTS_VOICES")
+	if len(config.LocalTTSVoices) == 0 && config.LocalTTSVoice != "" {
+		config.LocalTTSVoices = []string{config.LocalTTSVoice}
+	}
+	config.LocalTTSPoolSize = getEnvInt("LOCAL_TTS_POOL_SIZE", 2)
+	
+	// 自定义 TTS 默认值
+	config.CustomTTSURL = getEnv("CUSTOM_TTS_URL", "")
+	config.CustomTTSMethod = getEnv("CUSTOM_TTS_METHOD", "POST")
+	config.CustomTTSBody = getEnv("CUSTOM_TTS_BODY", "")
+	config.CustomTTSResponse = getEnv("CUSTOM_TTS_RESPONSE", "raw")
+	config.CustomTTSVoice = getEnv("CUSTOM_TTS_VOICE", "")
+	config.CustomTTSVoices = getEnvList("CUSTOM_TTS_VOICES")
+	if len(config.CustomTTSVoices) == 0 && config.CustomTTSVoice != "" {
+		config.CustomTTSVoices = []string{config.CustomTTSVoice}
+	}
+	config.CustomTTSSSML = getEnv("CUSTOM_TTS_SSML", "false") == "true"
+	if headers := getEnv("CUSTOM_TTS_HEADERS", ""); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &config.CustomTTSHeaders); err != nil {
+			log.Printf("Warning: CUSTOM_TTS_HEADERS is not a valid JSON object: %v", err)
+		}
+	}
+	
+	// TTS 备用链默认值
+	config.TTSFallback = getEnvList("TTS_FALLBACK")
+	for i, name := range config.TTSFallback {
+		config.TTSFallback[i] = ttsProviderName(name)
+	}
+	config.TTSTimeout = getEnvInt("TTS_TIMEOUT", 10)
+	config.TTSVoiceMap = getEnvList("TTS_VOICE_MAP")
+	config.TTSErrorPrompt = getEnv("TTS_ERROR_PROMPT", "抱歉，我的声音出了点问题，请稍后再试。")
+	if config.TTSErrorPrompt == "off" {
+		config.TTSErrorPrompt = ""
+	}
+	config.TTSCacheSize = getEnvInt("TTS_CACHE_SIZE_MB", 32)
+	config.TTSAPIMaxChars = getEnvInt("TTS_API_MAX_CHARS", 1000)
+	config.SpeakerVoices = getEnvList("STORY_SPEAKER_VOICES")
+	
+	// 意图识别默认值
+	config.IntentProviders = getEnvList("INTENT_PROVIDERS")
+	if len(config.IntentProviders) == 0 {
+		config.IntentProviders = []string{"keyword"}
+	}
+	config.IntentLLMModel = getEnv("INTENT_LLM_MODEL", "")
+	config.MusicDir = getEnv("MUSIC_DIR", "music")
+	
+	// 用量统计默认值
+	config.DataDir = getEnv("DATA_DIR", "data")
+	config.UsageDailyQuota = getEnvInt("USAGE_DAILY_TOKEN_QUOTA", 0)
+	config.UsageMonthlyQuota = getEnvInt("USAGE_MONTHLY_TOKEN_QUOTA", 0)
+	config.UsageDeviceQuotas = getEnvList("USAGE_DEVICE_QUOTAS")
+	config.QuotaMessage = getEnv("USAGE_QUOTA_MESSAGE", "抱歉，这台设备的对话额度已经用完了，请稍后再来找我聊天吧。")
+	
+	// 视觉模型默认值
+	config.VisionProvider = getEnv("VISION_PROVIDER", "off")
+	config.VisionAPIKey = getEnv("VISION_API_KEY", "")
+	config.VisionAPIEndpoint = getEnv("VISION_API_ENDPOINT", "")
+	config.VisionModel = getEnv("VISION_MODEL", "")
+	config.VisionStreamUsage = getEnv("VISION_STREAM_USAGE", "auto")
+	
+	// 内容安全默认值
+	config.ModerationProviders = getEnvList("MODERATION_PROVIDERS")
+	if len(config.ModerationProviders) == 0 {
+		config.ModerationProviders = []string{"keyword"}
+	} else if len(config.ModerationProviders) == 1 && config.ModerationProviders[0] == "off" {
+		config.ModerationProviders = nil
+	}
+	config.ModerationBlocklist = getEnv("MODERATION_BLOCKLIST", "")
+	config.ModerationLLMModel = getEnv("MODERATION_LLM_MODEL", "")
+	config.ModerationTopic = getEnv("MODERATION_TOPIC", "xiaozhi/moderation")
+	
+	// 知识库默认值
+	config.KnowledgeDir = getEnv("KNOWLEDGE_DIR", "")
+	config.KnowledgeTopK = getEnvInt("KNOWLEDGE_TOP_K", 3)
+	config.KnowledgeChunkSize = getEnvInt("KNOWLEDGE_CHUNK_SIZE", 400)
+	config.KnowledgeEmbeddingEndpoint = getEnv("KNOWLEDGE_EMBEDDING_ENDPOINT", "")
+	config.KnowledgeEmbeddingAPIKey = getEnv("KNOWLEDGE_EMBEDDING_API_KEY", "")
+	config.KnowledgeEmbeddingModel = getEnv("KNOWLEDGE_EMBEDDING_MODEL", "")
+	
+	if config.VisionProvider == "openai" && (config.VisionAPIKey == "" || config.VisionModel == "") {
+		log.Printf("Warning: vision provider set to 'openai' but VISION_API_KEY or VISION_MODEL is not set")
+	}
+	
+	if config.ReasoningFiller == "off" {
+		config.ReasoningFiller = ""
 	}
+	
+	// 延迟提示语默认值
+	config.FillerPhrases = getEnvList("FILLER_PHRASES")
+	if len(config.FillerPhrases) == 0 {
+		config.FillerPhrases = []string{"嗯，让我想想。", "我查一下。", "稍等一下哦。"}
+	} else if len(config.FillerPhrases) == 1 && config.FillerPhrases[0] == "off" {
+		config.FillerPhrases = nil
+	}
+	config.FillerDelay = getEnvInt("FILLER_DELAY_MS", 1500)
+	
+	// 朗读长度默认值
+	config.SpeechBudget = getEnvInt("SPEECH_BUDGET_CHARS", 0)
+	config.ContinuePrompt = getEnv("SPEECH_CONTINUE_PROMPT", "")
 
 	// 记录配置加载情况
 	if os.Getenv("MQTT_BROKER") == "" {
@@ -57,16 +251,28 @@ func LoadConfig() *Config {
 		log.Printf("SERVER_PORT environment variable not set, using default: %s", config.ServerPort)
 	}
 	
+	if os.Getenv("DOUBAN_API_KEY") != "" {
+		log.Printf("Warning: DOUBAN_API_KEY is no longer used, configure VOLCENGINE_TTS_APPID and VOLCENGINE_TTS_TOKEN instead")
+	}
+	
 	if os.Getenv("TTS_PROVIDER") == "" {
 		log.Printf("TTS_PROVIDER environment variable not set, using default: %s", config.TTSProvider)
-	} else if config.TTSProvider == "douban" && config.DoubanAPIKey == "" {
-		log.Printf("Warning: TTS provider set to 'douban' but DOUBAN_API_KEY is not set")
+	} else if config.TTSProvider == "volcengine" && (config.VolcengineTTSAppID == "" || config.VolcengineTTSToken == "") {
+		log.Printf("Warning: TTS provider set to 'volcengine' but VOLCENGINE_TTS_APPID or VOLCENGINE_TTS_TOKEN is not set")
+	} else if config.TTSProvider == "local" && config.LocalTTSCommand == "" {
+		log.Printf("Warning: TTS provider set to 'local' but LOCAL_TTS_COMMAND is not set")
+	} else if config.TTSProvider == "custom" && config.CustomTTSURL == "" {
+		log.Printf("Warning: TTS provider set to 'custom' but CUSTOM_TTS_URL is not set")
 	}
 	
 	if os.Getenv("LLM_PROVIDER") == "" {
 		log.Printf("LLM_PROVIDER environment variable not set, using default: %s", config.LLMProvider)
 	} else if config.LLMProvider == "deepseek" && config.DeepseekAPIKey == "" {
 		log.Printf("Warning: LLM provider set to 'deepseek' but DEEPSEEK_API_KEY is not set")
+	} else if config.LLMProvider == "anthropic" && config.AnthropicAPIKey == "" {
+		log.Printf("Warning: LLM provider set to 'anthropic' but ANTHROPIC_API_KEY is not set")
+	} else if config.LLMProvider == "scripted" && config.LLMScript == "" {
+		log.Printf("Warning: LLM provider set to 'scripted' but LLM_SCRIPT is not set")
 	}
 
 	return config
@@ -79,4 +285,45 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
+}
+
+// getEnvInt 获取整数类型的环境变量，不存在或格式错误时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid integer for %s: %s, using default: %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return intValue
+}
+
+// ttsProviderName 把旧的 TTS 提供商名称换成现在的名称
+// 原来的 douban 提供商调用的是并不存在的接口，豆包语音实际由火山引擎提供
+func ttsProviderName(name string) string {
+	if name == "douban" {
+		log.Printf("TTS provider 'douban' is an alias for 'volcengine'")
+		return "volcengine"
+	}
+	return name
+}
+
+// getEnvList 获取逗号分隔的列表类型环境变量
+func getEnvList(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 } 
\ No newline at end of file
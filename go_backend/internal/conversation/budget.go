@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// errSpeechBudgetReached 由流式回调返回，朗读字数超出预算后停止模型输出
+var errSpeechBudgetReached = errors.New("speech budget reached")
+
+// continuationTTL 是"要我继续说吗"的有效期，超时后用户的回答按新问题处理
+const continuationTTL = 2 * time.Minute
+
+// defaultContinuePrompt 是没有配置 SPEECH_CONTINUE_PROMPT 时使用的续说询问
+const defaultContinuePrompt = "后面还有一些内容，要我继续说吗？"
+
+// affirmativeReplies 是表示愿意继续听的简短回答
+var affirmativeReplies = map[string]bool{
+	"要": true, "要的": true, "好": true, "好的": true, "好啊": true, "好呀": true,
+	"继续": true, "继续说": true, "接着说": true, "说吧": true, "想": true, "想听": true,
+	"嗯": true, "嗯嗯": true, "可以": true, "是": true, "是的": true, "对": true, "行": true,
+	"yes": true, "ok": true, "okay": true,
+}
+
+// speechBudget 统计一次回复已朗读的字数，超出预算后剩余的句子留待续说
+type speechBudget struct {
+	limit     int
+	spoken    int
+	text      strings.Builder // 预算内朗读的内容
+	remainder strings.Builder // 超出预算未朗读的内容
+	exceeded  bool
+}
+
+// newSpeechBudget 创建朗读预算，limit 为 0 表示不限制
+func newSpeechBudget(limit int) *speechBudget {
+	return &speechBudget{limit: limit}
+}
+
+// Allow 判断句子能否朗读；越过预算的那一句仍然读完，之后的句子计入剩余内容
+func (b *speechBudget) Allow(sentence string) bool {
+	if b.exceeded {
+		b.remainder.WriteString(sentence)
+		return false
+	}
+	b.text.WriteString(sentence)
+	b.spoken += len([]rune(sentence))
+	if b.limit > 0 && b.spoken >= b.limit {
+		b.exceeded = true
+	}
+	return true
+}
+
+// Defer 把尚未成句的尾部文本计入剩余内容
+func (b *speechBudget) Defer(text string) {
+	b.remainder.WriteString(text)
+}
+
+// Truncated 判断是否有超出预算而没有朗读的内容
+func (b *speechBudget) Truncated() bool {
+	return b.remainder.Len() > 0
+}
+
+// Remainder 返回没有朗读的内容
+func (b *speechBudget) Remainder() string {
+	return b.remainder.String()
+}
+
+// continuation 是一次被截断的回复，用户同意后从剩余内容接着说
+type continuation struct {
+	remainder string
+	createdAt time.Time
+}
+
+// speechLimit 返回当前角色的朗读字数预算，0 表示不限制
+func (cm *ConversationManager) speechLimit() int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.persona.SpeechBudget > 0 {
+		return cm.persona.SpeechBudget
+	}
+	return cm.options.SpeechBudget
+}
+
+// continuePrompt 返回续说询问
+func (cm *ConversationManager) continuePrompt() string {
+	if cm.options.ContinuePrompt != "" {
+		return cm.options.ContinuePrompt
+	}
+	return defaultContinuePrompt
+}
+
+// offerContinuation 保存被截断的内容，等待用户回答是否继续
+func (cm *ConversationManager) offerContinuation(remainder string) {
+	cm.mu.Lock()
+	cm.pendingContinuation = &continuation{
+		remainder: remainder,
+		createdAt: time.Now(),
+	}
+	cm.mu.Unlock()
+	log.Printf("[Conversation] Reply truncated by speech budget, %d chars pending", len([]rune(remainder)))
+}
+
+// takeContinuation 取出待续说的内容，只有用户给出肯定回答时返回 true
+// 不论回答是什么，待续说的内容都只保留到用户的下一句话
+func (cm *ConversationManager) takeContinuation(text string) (string, bool) {
+	cm.mu.Lock()
+	pending := cm.pendingContinuation
+	cm.pendingContinuation = nil
+	cm.mu.Unlock()
+
+	if pending == nil || time.Since(pending.createdAt) > continuationTTL {
+		return "", false
+	}
+	if !isAffirmative(text) {
+		return "", false
+	}
+	return pending.remainder, true
+}
+
+// isAffirmative 判断用户的回答是否表示同意
+func isAffirmative(text string) bool {
+	text = strings.ToLower(strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}))
+	if affirmativeReplies[text] {
+		return true
+	}
+	return strings.HasPrefix(text, "继续") || strings.HasPrefix(text, "接着")
+}
+
+// budgetInstruction 返回要求模型控制回答长度的提示
+func budgetInstruction(limit int) string {
+	return fmt.Sprintf("你的回答会被朗读出来，请尽量控制在%d字以内，先说最重要的内容。", limit)
+}
+
+// continueInstruction 返回让模型接着被截断的回答继续说的提示
+func continueInstruction(remainder string) string {
+	return fmt.Sprintf("用户希望你接着刚才没说完的回答继续说。下面这段内容会先朗读给用户：「%s」。请直接从这段内容之后接着往下说，不要重复已经说过的内容，也不要寒暄。", remainder)
+}
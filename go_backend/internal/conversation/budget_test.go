@@ -0,0 +1,152 @@
+package conversation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/usage"
+)
+
+// longReplyProvider 逐句流式输出一段长回复，最后一个数据块带用量
+type longReplyProvider struct {
+	sentences []string
+	usage     llm.Usage
+	sent      int // 调用方结束流之前发出的句子数
+}
+
+func (p *longReplyProvider) Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error) {
+	return &llm.Response{Content: strings.Join(p.sentences, "")}, nil
+}
+
+func (p *longReplyProvider) StreamChat(messages []llm.Message, options *llm.ChatOptions, callback llm.StreamCallback) error {
+	p.sent = 0
+	for _, sentence := range p.sentences {
+		if err := callback(&llm.ResponseChunk{Content: sentence}); err != nil {
+			return err
+		}
+		p.sent++
+	}
+	return callback(&llm.ResponseChunk{
+		IsFinal:  true,
+		Metadata: map[string]interface{}{llm.MetadataUsage: p.usage},
+	})
+}
+
+func (p *longReplyProvider) Capabilities() llm.Capabilities { return llm.Capabilities{} }
+func (p *longReplyProvider) Initialize() error              { return nil }
+func (p *longReplyProvider) Cleanup() error                 { return nil }
+
+// deviceAudio 记录测试客户端收到的音频消息
+type deviceAudio struct {
+	mu     sync.Mutex
+	frames [][]byte
+}
+
+// Frames 返回目前收到的音频消息
+func (d *deviceAudio) Frames() [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([][]byte{}, d.frames...)
+}
+
+// newTestConversation 创建一个连接到本地 WebSocket 的会话，客户端只记录收到的音频，文本消息全部丢弃
+func newTestConversation(t *testing.T, llmManager *llm.LLMManager, ttsManager *tts.TTSManager, options Options) (*ConversationManager, *deviceAudio) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	audio := &deviceAudio{}
+	go func() {
+		for {
+			messageType, data, err := client.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType == websocket.BinaryMessage {
+				audio.mu.Lock()
+				audio.frames = append(audio.frames, data)
+				audio.mu.Unlock()
+			}
+		}
+	}()
+
+	conn := <-conns
+	t.Cleanup(func() { conn.Close() })
+	return NewConversationManager(conn, llmManager, ttsManager, options, "test-device", "test-client"), audio
+}
+
+func TestTruncatedReplyRecordsUsage(t *testing.T) {
+	provider := &longReplyProvider{
+		sentences: []string{"从前有一座山。", "山里有一座庙。", "庙里有个老和尚。", "老和尚在给小和尚讲故事。", "讲的是什么呢？"},
+		usage:     llm.Usage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+	}
+	manager := llm.NewLLMManager()
+	manager.RegisterProvider("story", provider)
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		budget int
+	}{
+		{"truncated", 10},
+		{"complete", 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := usage.NewTracker("", usage.Quota{})
+			cm, _ := newTestConversation(t, manager, nil, Options{Usage: tracker, SpeechBudget: tt.budget})
+			cm.respond(llm.Message{Role: "user", Content: "讲个故事"}, "")
+
+			device, ok := tracker.Device("test-device")
+			if !ok || device.Total.Requests != 1 {
+				t.Fatalf("usage = %+v, want one recorded request", device)
+			}
+			counter := device.Providers["story"]
+			if counter == nil {
+				t.Fatalf("providers = %+v, want usage under the serving provider", device.Providers)
+			}
+
+			if tt.budget == 0 {
+				if *counter != (usage.Counter{Requests: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}) {
+					t.Errorf("usage = %+v, want the reported usage", *counter)
+				}
+				return
+			}
+
+			// 流在第三句后结束，没有收到最后一个数据块，用量按已生成的内容估算
+			if provider.sent != 2 {
+				t.Fatalf("provider sent %d sentences before the stream stopped, want 2", provider.sent)
+			}
+			generated := len([]rune(provider.sentences[0] + provider.sentences[1] + provider.sentences[2]))
+			if counter.CompletionTokens != int64(generated) || counter.PromptTokens == 0 {
+				t.Errorf("estimated usage = %+v, want %d completion tokens", *counter, generated)
+			}
+
+			if remainder := cm.pendingContinuation; remainder == nil || remainder.remainder != provider.sentences[2] {
+				t.Errorf("continuation = %+v", remainder)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package conversation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/textnorm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// narratorSpeaker 是旁白的说话人标签，标签之前的文字也按旁白朗读
+const narratorSpeaker = "narrator"
+
+// speakerPause 是换说话人时插入的停顿
+const speakerPause = 300 * time.Millisecond
+
+// speakerTagRe 匹配说话人标签，如 [wolf] 或带性别提示的 [rabbit:female]
+var speakerTagRe = regexp.MustCompile(`\[([^\[\]:\s]{1,16})(?::(male|female|child))?\]`)
+
+// dialogueInstruction 是多角色朗读时附加到系统提示中的格式说明
+const dialogueInstruction = "讲故事时，在每段旁白前加 [narrator]，在每句角色台词前加该角色的标签，例如 [wolf]，" +
+	"新角色可以用 [英文名:male]、[英文名:female] 或 [英文名:child] 标明声音。标签只用于选择声音，不要解释标签。"
+
+// dialogueLine 是一位说话人连续说的一段话
+type dialogueLine struct {
+	speaker string
+	hint    string // 标签中的性别提示
+	text    string
+}
+
+// dialogueRenderer 把带说话人标签的句子渲染成按角色分配音色的合成请求
+// 说话人标签可以跨句生效，直到出现下一个标签
+type dialogueRenderer struct {
+	cm       *ConversationManager
+	speakers map[string]tts.VoiceFilter // 角色预设的说话人声音
+	voices   map[string]string          // 本次会话已分配的音色
+	current  string                     // 当前说话人
+	hint     string
+	previous string // 上一句最后的说话人，用于判断是否需要停顿
+}
+
+// newDialogueRenderer 为支持多角色朗读的角色创建渲染器，角色没有说话人设置时返回 nil
+func (cm *ConversationManager) newDialogueRenderer() *dialogueRenderer {
+	cm.mu.Lock()
+	persona := cm.persona
+	cm.mu.Unlock()
+
+	if len(persona.Speakers) == 0 {
+		return nil
+	}
+	return &dialogueRenderer{
+		cm:       cm,
+		speakers: persona.Speakers,
+		voices:   make(map[string]string),
+		current:  narratorSpeaker,
+	}
+}
+
+// Split 去掉句子中的说话人标签，返回去掉标签后的句子和各说话人的台词
+func (d *dialogueRenderer) Split(sentence string) (string, []dialogueLine) {
+	var lines []dialogueLine
+	add := func(text string) {
+		if strings.TrimSpace(text) != "" {
+			lines = append(lines, dialogueLine{speaker: d.current, hint: d.hint, text: text})
+		}
+	}
+
+	rest := sentence
+	for {
+		loc := speakerTagRe.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		add(rest[:loc[0]])
+		d.current = strings.ToLower(rest[loc[2]:loc[3]])
+		d.hint = ""
+		if loc[4] >= 0 {
+			d.hint = rest[loc[4]:loc[5]]
+		}
+		rest = rest[loc[1]:]
+	}
+	add(rest)
+
+	return speakerTagRe.ReplaceAllString(sentence, ""), lines
+}
+
+// Markup 把台词渲染为 SSML-lite 标记，每位说话人使用自己的音色，换人时插入停顿
+func (d *dialogueRenderer) Markup(lines []dialogueLine) string {
+	var b strings.Builder
+	for _, line := range lines {
+		speech := textnorm.Normalize(line.text).Speech
+		if !textnorm.IsSpeakable(speech) {
+			continue
+		}
+		if d.previous != "" && line.speaker != d.previous {
+			fmt.Fprintf(&b, `<break time="%dms"/>`, speakerPause.Milliseconds())
+		}
+		d.previous = line.speaker
+
+		text := escapeMarkup(speech)
+		if voice := d.voice(line.speaker, line.hint); voice != "" {
+			text = fmt.Sprintf(`<voice name="%s">%s</voice>`, escapeMarkup(voice), text)
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// voice 返回说话人的音色，为空时使用会话当前的声音
+// 配置中指定的音色优先；旁白默认使用会话的声音；其他角色按角色预设或标签提示从音色目录中挑选，尽量不与已分配的音色重复
+func (d *dialogueRenderer) voice(speaker, hint string) string {
+	if voice, ok := d.voices[speaker]; ok {
+		return voice
+	}
+	if voice := d.cm.options.SpeakerVoices[speaker]; voice != "" {
+		d.voices[speaker] = voice
+		return voice
+	}
+
+	filter, known := d.speakers[speaker]
+	if speaker == narratorSpeaker && !known {
+		d.voices[speaker] = ""
+		return ""
+	}
+	if !known {
+		filter = hintFilter(hint)
+	}
+
+	voice := d.pick(filter)
+	log.Printf("[Conversation] Assigned voice %q to speaker %s", voice, speaker)
+	d.voices[speaker] = voice
+	return voice
+}
+
+// pick 从音色目录中挑选一个满足条件且尚未分配的音色，都已分配时复用第一个满足条件的音色
+// 没有满足条件的音色时放宽为任意中文音色
+func (d *dialogueRenderer) pick(filter tts.VoiceFilter) string {
+	if d.cm.ttsManager == nil {
+		return ""
+	}
+	filter.Language = "zh"
+	candidates, err := d.cm.ttsManager.Voices(filter)
+	if err == nil && len(candidates) == 0 && filter != (tts.VoiceFilter{Language: "zh"}) {
+		candidates, err = d.cm.ttsManager.Voices(tts.VoiceFilter{Language: "zh"})
+	}
+	if err != nil || len(candidates) == 0 {
+		return ""
+	}
+
+	used := make(map[string]bool, len(d.voices))
+	for _, voice := range d.voices {
+		used[voice] = true
+	}
+	d.cm.mu.Lock()
+	used[d.cm.voice.Voice] = true // 会话声音留给旁白
+	d.cm.mu.Unlock()
+
+	for _, candidate := range candidates {
+		if !used[candidate.ID] {
+			return candidate.ID
+		}
+	}
+	return candidates[0].ID
+}
+
+// hintFilter 把标签中的性别提示转换为音色过滤条件
+func hintFilter(hint string) tts.VoiceFilter {
+	switch hint {
+	case "male", "female":
+		return tts.VoiceFilter{Gender: hint}
+	case "child":
+		return tts.VoiceFilter{Tag: "age=child"}
+	}
+	return tts.VoiceFilter{}
+}
+
+// escapeMarkup 转义要放进标记中的文字
+func escapeMarkup(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// synthesizeMarkup 使用当前会话的声音设置合成一段 SSML-lite 标记
+func (cm *ConversationManager) synthesizeMarkup(markup string) ([]byte, error) {
+	if cm.ttsManager == nil {
+		return nil, nil
+	}
+
+	cm.mu.Lock()
+	request := cm.voice.request(markup)
+	cm.mu.Unlock()
+
+	request.Markup = true
+	return cm.ttsManager.Synthesize(request)
+}
@@ -0,0 +1,175 @@
+package conversation
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// FillerCache 保存启动时预先合成的提示语音频，等待模型时无需再调用 TTS
+type FillerCache struct {
+	phrases []string
+	audio   [][]byte
+}
+
+// NewFillerCache 合成所有提示语，合成失败的提示语会被跳过
+func NewFillerCache(ttsManager *tts.TTSManager, phrases []string) *FillerCache {
+	cache := &FillerCache{}
+	for _, phrase := range phrases {
+		audioData, err := synthesizeSpeech(ttsManager, phrase)
+		if err != nil || len(audioData) == 0 {
+			log.Printf("[Conversation] Skipping filler %q: %v", phrase, err)
+			continue
+		}
+		cache.phrases = append(cache.phrases, phrase)
+		cache.audio = append(cache.audio, audioData)
+	}
+	log.Printf("[Conversation] Cached %d filler phrases", len(cache.phrases))
+	return cache
+}
+
+// Len 返回可用的提示语数量
+func (c *FillerCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.phrases)
+}
+
+// Pick 随机选择一句提示语
+func (c *FillerCache) Pick() (string, []byte) {
+	i := rand.Intn(len(c.phrases))
+	return c.phrases[i], c.audio[i]
+}
+
+// fillerTurn 协调一轮回复中的提示语：每轮最多播放一句，正式回复开始时立即打断
+type fillerTurn struct {
+	cm       *ConversationManager
+	done     sync.WaitGroup // 提示语播放结束，正式回复的第一句要等待它
+	stop     chan struct{}
+	stopOnce sync.Once
+	mu       sync.Mutex
+	played   bool
+}
+
+// newFillerTurn 为一轮回复创建提示语协调器
+func (cm *ConversationManager) newFillerTurn() *fillerTurn {
+	return &fillerTurn{
+		cm:   cm,
+		stop: make(chan struct{}),
+	}
+}
+
+// Arm 在 delay 之后仍没有开始正式回复时播放一句缓存的提示语
+func (ft *fillerTurn) Arm(cache *FillerCache, delay time.Duration) {
+	if cache.Len() == 0 {
+		return
+	}
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			text, audioData := cache.Pick()
+			ft.Play(text, audioData)
+		case <-ft.stop:
+		}
+	}()
+}
+
+// Play 播放一句提示语，audioData 为空时现场合成
+// 本轮已经播放过提示语或正式回复已经开始时不播放
+func (ft *fillerTurn) Play(text string, audioData []byte) {
+	ft.mu.Lock()
+	if ft.played {
+		ft.mu.Unlock()
+		return
+	}
+	select {
+	case <-ft.stop:
+		ft.mu.Unlock()
+		return
+	default:
+	}
+	ft.played = true
+	ft.done.Add(1)
+	ft.mu.Unlock()
+
+	go func() {
+		defer ft.done.Done()
+
+		if len(audioData) == 0 {
+			var err error
+			if audioData, err = ft.cm.synthesize(text); err != nil || len(audioData) == 0 {
+				log.Printf("[Conversation] Error synthesizing filler: %v", err)
+				return
+			}
+		}
+		log.Printf("[Conversation] Playing filler: %s", text)
+		ft.cm.speakFiller(audioData, ft.stop)
+	}()
+}
+
+// Stop 正式回复即将开始或本轮结束，打断正在播放的提示语并取消尚未播放的提示语
+func (ft *fillerTurn) Stop() {
+	// 持锁关闭，保证 Stop 返回后 Play 不会再开始播放，Wait 不会漏掉
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.stopOnce.Do(func() {
+		close(ft.stop)
+	})
+}
+
+// Wait 等待提示语停止播放
+func (ft *fillerTurn) Wait() {
+	ft.done.Wait()
+}
+
+// speakFiller 在思考期间播放一段提示语音频，播放完毕后恢复思考状态
+// stop 关闭时在当前音频块之后停止发送，由正式回复接管说话状态
+func (cm *ConversationManager) speakFiller(audioData []byte, stop <-chan struct{}) {
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	cm.mu.Lock()
+	cm.sendSpeakingResponse()
+	cm.mu.Unlock()
+
+	chunkSize := 32 * 1024 // 与 sendAudio 保持一致
+	for i := 0; i < len(audioData); i += chunkSize {
+		select {
+		case <-stop:
+			log.Printf("[Conversation] Filler interrupted by reply")
+			return
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(audioData) {
+			end = len(audioData)
+		}
+
+		cm.mu.Lock()
+		err := cm.conn.WriteMessage(websocket.BinaryMessage, audioData[i:end])
+		cm.mu.Unlock()
+
+		if err != nil {
+			log.Printf("[Conversation] Error sending filler audio: %v", err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cm.mu.Lock()
+	cm.sendThinkingResponse()
+	cm.mu.Unlock()
+}
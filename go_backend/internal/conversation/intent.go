@@ -0,0 +1,258 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/intent"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/models"
+)
+
+// defaultDeviceVolume 是设备未上报音量时假定的音量
+const defaultDeviceVolume = 70
+
+// musicExtensions 是播放音乐意图支持的文件类型
+var musicExtensions = map[string]bool{
+	".mp3":  true,
+	".wav":  true,
+	".opus": true,
+	".p3":   true,
+}
+
+// handleIntent 识别用户意图，命中明确意图时直接处理并返回 true
+func (cm *ConversationManager) handleIntent(text string) bool {
+	if cm.options.Intent == nil {
+		return false
+	}
+
+	cm.mu.Lock()
+	history := append([]llm.Message{}, cm.chatHistory...)
+	cm.mu.Unlock()
+
+	result, err := cm.options.Intent.Recognize(text, history)
+	if err != nil {
+		log.Printf("[Conversation] Intent recognition failed, falling back to chat: %v", err)
+		return false
+	}
+	cm.recordAuxiliaryUsage(result.LLMMetadata, "intent")
+	if result.IsPassThrough() {
+		return false
+	}
+
+	log.Printf("[Conversation] Handling intent %s (from %s) with args %v", result.Intent, result.Provider, result.Arguments)
+
+	switch result.Intent {
+	case intent.IntentExit:
+		cm.handleExitIntent()
+	case intent.IntentPlayMusic:
+		cm.handlePlayMusicIntent(result.StringArg("song_name"))
+	case intent.IntentChangeVolume:
+		cm.handleChangeVolumeIntent(result)
+	case intent.IntentChangeRole:
+		cm.handleChangeRoleIntent(result.StringArg("role"))
+	case intent.IntentChangeVoice:
+		cm.handleChangeVoiceIntent(result)
+	default:
+		log.Printf("[Conversation] Unknown intent %s, falling back to chat", result.Intent)
+		return false
+	}
+
+	return true
+}
+
+// handleExitIntent 说再见并关闭连接
+func (cm *ConversationManager) handleExitIntent() {
+	cm.speakResponse("好的，再见！")
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "goodbye")
+	if err := cm.conn.WriteMessage(websocket.CloseMessage, closeMessage); err != nil {
+		log.Printf("[Conversation] Error sending close message: %v", err)
+	}
+}
+
+// handlePlayMusicIntent 从音乐目录中查找并播放歌曲，歌名为空或 random 时随机播放
+func (cm *ConversationManager) handlePlayMusicIntent(songName string) {
+	songPath, err := findMusic(cm.options.MusicDir, songName)
+	if err != nil {
+		log.Printf("[Conversation] Music not found for %q: %v", songName, err)
+		cm.speakResponse("抱歉，没有找到可以播放的音乐。")
+		return
+	}
+
+	audioData, err := os.ReadFile(songPath)
+	if err != nil {
+		log.Printf("[Conversation] Error reading music file %s: %v", songPath, err)
+		cm.speakResponse("抱歉，音乐文件读取失败了。")
+		return
+	}
+
+	title := strings.TrimSuffix(filepath.Base(songPath), filepath.Ext(songPath))
+	log.Printf("[Conversation] Playing music %s (%d bytes)", songPath, len(audioData))
+
+	cm.mu.Lock()
+	cm.sendSpeakingResponse()
+	cm.currentState = models.StateSpeaking
+	cm.mu.Unlock()
+
+	cm.sendTextResponse(fmt.Sprintf("正在播放《%s》", title))
+	cm.sendAudio(audioData)
+	cm.finishSpeaking()
+}
+
+// findMusic 在音乐目录中按歌名模糊查找文件
+func findMusic(musicDir, songName string) (string, error) {
+	if musicDir == "" {
+		return "", fmt.Errorf("music directory not configured")
+	}
+
+	entries, err := os.ReadDir(musicDir)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		if entry.IsDir() || !musicExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		candidates = append(candidates, entry.Name())
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no music files in %s", musicDir)
+	}
+
+	songName = strings.TrimSpace(songName)
+	if songName == "" || songName == "random" || songName == "音乐" || songName == "歌" {
+		return filepath.Join(musicDir, candidates[rand.Intn(len(candidates))]), nil
+	}
+
+	for _, name := range candidates {
+		title := strings.TrimSuffix(name, filepath.Ext(name))
+		if strings.Contains(title, songName) || strings.Contains(songName, title) {
+			return filepath.Join(musicDir, name), nil
+		}
+	}
+	return "", fmt.Errorf("no music matching %q", songName)
+}
+
+// handleChangeVolumeIntent 计算目标音量并向设备下发 IoT 命令
+func (cm *ConversationManager) handleChangeVolumeIntent(result *intent.Result) {
+	cm.mu.Lock()
+	volume := cm.deviceVolume
+	cm.mu.Unlock()
+
+	if absolute, ok := result.IntArg("volume"); ok {
+		volume = absolute
+	} else if delta, ok := result.IntArg("delta"); ok {
+		volume += delta
+	}
+	if volume < 0 {
+		volume = 0
+	} else if volume > 100 {
+		volume = 100
+	}
+
+	if err := cm.setDeviceVolume(volume); err != nil {
+		log.Printf("[Conversation] Error sending volume command: %v", err)
+		cm.speakResponse("抱歉，音量调整失败了。")
+		return
+	}
+	cm.speakResponse(fmt.Sprintf("好的，音量已调到%d。", volume))
+}
+
+// setDeviceVolume 通过 IoT 命令设置设备音量
+func (cm *ConversationManager) setDeviceVolume(volume int) error {
+	if err := cm.sendIoTCommand(models.IoTCommand{
+		Name:       "Speaker",
+		Method:     "SetVolume",
+		Parameters: map[string]interface{}{"volume": volume},
+	}); err != nil {
+		return err
+	}
+
+	cm.updateVoiceSettings(func(s *VoiceSettings) {
+		s.Volume = &volume
+	})
+	cm.mu.Lock()
+	cm.deviceVolume = volume
+	cm.mu.Unlock()
+
+	log.Printf("[Conversation] Sent volume command (%d) to %s", volume, cm.clientIP)
+	return nil
+}
+
+// sendIoTCommand 向设备下发一条 IoT 命令
+func (cm *ConversationManager) sendIoTCommand(command models.IoTCommand) error {
+	msg := models.IoTCommandMessage{
+		Type:     models.TypeIoT,
+		Commands: []models.IoTCommand{command},
+	}
+
+	jsonData, _ := json.Marshal(msg)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.conn.WriteMessage(websocket.TextMessage, jsonData)
+}
+
+// handleChangeRoleIntent 切换角色并清空之前的对话
+func (cm *ConversationManager) handleChangeRoleIntent(roleName string) {
+	persona, ok := findPersona(roleName)
+	if !ok {
+		cm.speakResponse(fmt.Sprintf("没有找到这个角色，可以选择：%s。", strings.Join(personaNames(), "、")))
+		return
+	}
+
+	cm.mu.Lock()
+	cm.persona = persona
+	cm.chatHistory = []llm.Message{
+		{
+			Role:    "system",
+			Content: persona.Prompt(),
+		},
+	}
+	cm.mu.Unlock()
+
+	log.Printf("[Conversation] Switched persona to %s for %s", persona.Name, cm.clientIP)
+	cm.speakResponse(fmt.Sprintf("好的，我现在是%s了。", persona.Name))
+}
+
+// processIoTStates 处理设备上报的 IoT 状态（调用方需持有锁）
+func (cm *ConversationManager) processIoTStates(data map[string]interface{}) {
+	rawStates, ok := data["states"]
+	if !ok {
+		return
+	}
+
+	// 借助 JSON 重新解码为结构化的状态列表
+	encoded, err := json.Marshal(rawStates)
+	if err != nil {
+		return
+	}
+	var states []models.IoTState
+	if err := json.Unmarshal(encoded, &states); err != nil {
+		log.Printf("[Conversation] Failed to parse IoT states: %v", err)
+		return
+	}
+
+	for _, state := range states {
+		if state.Name != "Speaker" {
+			continue
+		}
+		if volume, ok := state.State["volume"].(float64); ok {
+			cm.deviceVolume = int(volume)
+			log.Printf("[Conversation] Device volume reported: %d", cm.deviceVolume)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package conversation
+
+import (
+	"log"
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/knowledge"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// withKnowledge 检索与用户问题相关的知识库片段，作为系统消息插入到用户消息之前
+// 参考资料只随本次请求发送，不写入聊天历史
+func (cm *ConversationManager) withKnowledge(history []llm.Message, query string) []llm.Message {
+	if cm.options.Knowledge == nil || strings.TrimSpace(query) == "" || len(history) == 0 {
+		return history
+	}
+
+	hits := cm.options.Knowledge.Retrieve(query, cm.options.KnowledgeTopK)
+	if len(hits) == 0 {
+		log.Printf("[Conversation] Knowledge: no passages for %q", query)
+		return history
+	}
+	log.Printf("[Conversation] Knowledge citations for %q: %s", query, strings.Join(knowledge.Citations(hits), "; "))
+
+	last := len(history) - 1
+	messages := make([]llm.Message, 0, len(history)+1)
+	messages = append(messages, history[:last]...)
+	messages = append(messages, llm.Message{
+		Role:    "system",
+		Content: knowledge.FormatContext(hits),
+	})
+	return append(messages, history[last])
+}
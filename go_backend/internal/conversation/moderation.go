@@ -0,0 +1,44 @@
+package conversation
+
+import (
+	"log"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/moderation"
+)
+
+// moderate 审核一段文本并按当前角色的策略处理，返回处理后的文本和实际采用的处理方式
+// 审核服务不可用时放行，被处理的内容会上报给家长
+func (cm *ConversationManager) moderate(text, direction string) (string, moderation.Action) {
+	if cm.options.Moderation == nil || text == "" {
+		return text, moderation.ActionAllow
+	}
+
+	verdict, err := cm.options.Moderation.Check(text)
+	if err != nil {
+		log.Printf("[Conversation] Moderation failed, allowing text: %v", err)
+		return text, moderation.ActionAllow
+	}
+	cm.recordAuxiliaryUsage(verdict.LLMMetadata, "moderation")
+
+	cm.mu.Lock()
+	persona := cm.persona
+	deviceID, clientID := cm.deviceID, cm.clientID
+	cm.mu.Unlock()
+
+	result, action := persona.Safety.Apply(text, verdict)
+	if action == moderation.ActionAllow {
+		return text, action
+	}
+
+	cm.options.Moderation.Report(moderation.Event{
+		DeviceID:   deviceID,
+		ClientID:   clientID,
+		Direction:  direction,
+		Persona:    persona.Name,
+		Action:     action,
+		Categories: verdict.Categories,
+		Provider:   verdict.Provider,
+		Text:       text,
+	})
+	return result, action
+}
@@ -0,0 +1,102 @@
+package conversation
+
+import (
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/emotion"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/moderation"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// Persona 定义一个可切换的助手角色
+type Persona struct {
+	Name         string            // 角色名称
+	Aliases      []string          // 用户切换角色时可能使用的其他叫法
+	SystemPrompt string            // 角色的系统提示词
+	Safety       moderation.Policy // 内容安全策略
+	SpeechBudget int               // 单次回复朗读的最大字数，0 表示使用全局设置
+
+	// Speakers 多角色朗读时各说话人标签对应的声音，为空时不解析说话人标签
+	Speakers map[string]tts.VoiceFilter
+}
+
+// defaultPersonaName 是会话初始使用的角色
+const defaultPersonaName = "小智"
+
+// builtinPersonas 是内置的角色列表
+var builtinPersonas = []Persona{
+	{
+		Name:         defaultPersonaName,
+		Aliases:      []string{"默认", "助手", "语音助手"},
+		SystemPrompt: "你是一个友好的语音助手，请简短、清晰地回答用户问题。回应应直接、有帮助，避免不必要的冗长解释。",
+		Safety:       moderation.Policy{Action: moderation.ActionReplace},
+	},
+	{
+		Name:         "英语老师",
+		Aliases:      []string{"英语", "老师"},
+		SystemPrompt: "你是一位耐心的英语老师，用中文和简单的英语帮助用户练习口语。每次回答简短，适当纠正用户的语法并给出例句。",
+		Safety:       moderation.Policy{Action: moderation.ActionRewrite, Fallback: "这个词不太适合练习，我们换一个句子吧。"},
+		SpeechBudget: 150,
+	},
+	{
+		Name:         "好奇小男孩",
+		Aliases:      []string{"小男孩", "小朋友"},
+		SystemPrompt: "你是一个八岁的好奇小男孩，说话活泼，喜欢追问为什么。回答要简短、口语化，像和朋友聊天一样。",
+		Safety:       moderation.Policy{Action: moderation.ActionBlock, Fallback: "这个问题我们去问问爸爸妈妈吧，我们玩点别的好不好？"},
+		SpeechBudget: 100,
+	},
+	{
+		Name:         "故事大王",
+		Aliases:      []string{"讲故事", "故事"},
+		SystemPrompt: "你是一位会讲故事的故事大王，给小朋友讲温暖、有趣的童话故事。旁白生动，角色台词要符合角色的性格，每次讲一小段，讲完问小朋友想不想听下去。",
+		Safety:       moderation.Policy{Action: moderation.ActionBlock, Fallback: "这个故事不太适合讲，我们换一个故事吧。"},
+		SpeechBudget: 400,
+		Speakers: map[string]tts.VoiceFilter{
+			"wolf":    {Gender: "male"},
+			"bear":    {Gender: "male"},
+			"grandma": {Gender: "female"},
+			"mother":  {Gender: "female"},
+			"rabbit":  {Tag: "age=child"},
+			"child":   {Tag: "age=child"},
+		},
+	},
+}
+
+// Prompt 返回发送给模型的完整系统提示，包含让模型以表情开头的说明
+// 支持多角色朗读的角色还包含说话人标签的格式说明
+func (p Persona) Prompt() string {
+	prompt := p.SystemPrompt
+	if len(p.Speakers) > 0 {
+		prompt += "\n" + dialogueInstruction
+	}
+	return prompt + "\n" + emotion.PromptInstruction
+}
+
+// findPersona 按名称或别名查找角色
+func findPersona(name string) (Persona, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Persona{}, false
+	}
+
+	for _, persona := range builtinPersonas {
+		if persona.Name == name || strings.Contains(name, persona.Name) {
+			return persona, true
+		}
+		for _, alias := range persona.Aliases {
+			if alias == name || strings.Contains(name, alias) {
+				return persona, true
+			}
+		}
+	}
+	return Persona{}, false
+}
+
+// personaNames 返回所有内置角色的名称
+func personaNames() []string {
+	names := make([]string, 0, len(builtinPersonas))
+	for _, persona := range builtinPersonas {
+		names = append(names, persona.Name)
+	}
+	return names
+}
@@ -0,0 +1,300 @@
+package conversation
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/gorilla/websocket"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/emotion"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/models"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/moderation"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/textnorm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// maxSentenceRunes 是没有句末标点时强制在逗号处切分的长度
+const maxSentenceRunes = 80
+
+// sentenceSplitter 把 LLM 的流式输出切分成句子，代码块内不切分
+type sentenceSplitter struct {
+	buffer []rune
+}
+
+// Feed 追加一段流式文本，返回已经完整的句子
+func (s *sentenceSplitter) Feed(text string) []string {
+	s.buffer = append(s.buffer, []rune(text)...)
+
+	var sentences []string
+	for {
+		end := s.findBoundary()
+		if end < 0 {
+			break
+		}
+		sentence := strings.TrimSpace(string(s.buffer[:end]))
+		s.buffer = s.buffer[end:]
+		if sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences
+}
+
+// Flush 返回缓冲区中剩余的文本
+func (s *sentenceSplitter) Flush() string {
+	rest := strings.TrimSpace(string(s.buffer))
+	s.buffer = nil
+	return rest
+}
+
+// findBoundary 返回第一个句子结束位置（不含），没有完整句子时返回 -1
+func (s *sentenceSplitter) findBoundary() int {
+	inFence := false
+	lastComma := -1
+
+	for i := 0; i < len(s.buffer); i++ {
+		// 代码块标记 ``` 之间的内容不切分
+		if i+2 < len(s.buffer) && s.buffer[i] == '`' && s.buffer[i+1] == '`' && s.buffer[i+2] == '`' {
+			inFence = !inFence
+			i += 2
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		switch s.buffer[i] {
+		case '。', '！', '？', '!', '?', '；', '\n':
+			return s.extendClosing(i + 1)
+		case '.':
+			// 英文句号需要看到后面的空白才能确定，且不能是小数点或列表序号
+			if i+1 >= len(s.buffer) {
+				return -1
+			}
+			if unicode.IsSpace(s.buffer[i+1]) && i > 0 && !unicode.IsDigit(s.buffer[i-1]) {
+				return s.extendClosing(i + 1)
+			}
+		case '，', ',':
+			lastComma = i
+		}
+
+		if i+1 >= maxSentenceRunes && lastComma > 0 {
+			return lastComma + 1
+		}
+	}
+	return -1
+}
+
+// extendClosing 把紧跟在句末标点后的引号和括号也归入当前句子
+func (s *sentenceSplitter) extendClosing(end int) int {
+	for end < len(s.buffer) && strings.ContainsRune(`"'”’）)」』`, s.buffer[end]) {
+		end++
+	}
+	return end
+}
+
+// SplitSentences 把一段完整文本切分成句子
+func SplitSentences(text string) []string {
+	var splitter sentenceSplitter
+	sentences := splitter.Feed(text)
+	if rest := splitter.Flush(); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// speechPipeline 按顺序规范化、合成并播放句子
+// LLM 流式输出的同时，前面的句子已经开始播放，缩短用户等待时间
+type speechPipeline struct {
+	cm        *ConversationManager
+	sentences chan string
+	done      chan struct{}
+	fillers   *fillerTurn // 第一句播放前要打断的提示语
+	started   bool
+	spoken    int               // 成功播放的句子数
+	emojis    []string          // 第一句播放前收集到的表情符号，用于推断情绪
+	ttsFailed bool              // 语音合成全部失败，已播放故障提示，剩余句子只发送文字
+	dialogue  *dialogueRenderer // 多角色朗读的渲染器，角色不支持时为 nil
+
+	// 内容安全审核
+	moderate   bool            // 是否在朗读前审核每个句子
+	flagged    bool            // 是否有句子被拦截、替换或改写
+	stopped    bool            // 被拦截后丢弃剩余句子
+	transcript strings.Builder // 审核后实际朗读的文本
+}
+
+// newSpeechPipeline 创建并启动一个播放管道，fillers 可以为 nil
+func (cm *ConversationManager) newSpeechPipeline(fillers *fillerTurn) *speechPipeline {
+	sp := &speechPipeline{
+		cm:        cm,
+		sentences: make(chan string, 32),
+		done:      make(chan struct{}),
+		fillers:   fillers,
+		dialogue:  cm.newDialogueRenderer(),
+	}
+	go sp.run()
+	return sp
+}
+
+// Push 提交一个待播放的句子
+func (sp *speechPipeline) Push(sentence string) {
+	sp.sentences <- sentence
+}
+
+// Close 结束提交并等待所有句子播放完毕，返回成功播放的句子数
+func (sp *speechPipeline) Close() int {
+	close(sp.sentences)
+	<-sp.done
+	return sp.spoken
+}
+
+// run 依次处理提交的句子
+func (sp *speechPipeline) run() {
+	defer close(sp.done)
+
+	for sentence := range sp.sentences {
+		if sp.stopped {
+			continue
+		}
+		if sp.moderate {
+			var ok bool
+			if sentence, ok = sp.moderateSentence(sentence); !ok {
+				continue
+			}
+		}
+		sp.transcript.WriteString(sentence)
+
+		var lines []dialogueLine
+		if sp.dialogue != nil {
+			sentence, lines = sp.dialogue.Split(sentence)
+		}
+
+		result := textnorm.Normalize(sentence)
+		if !sp.started {
+			sp.emojis = append(sp.emojis, result.Emojis...)
+		}
+		if !textnorm.IsSpeakable(result.Speech) {
+			continue
+		}
+
+		if !sp.started {
+			if sp.fillers != nil {
+				sp.fillers.Stop()
+				sp.fillers.Wait()
+			}
+
+			// 第一句之前发送情绪，让设备表情和语音同时变化
+			sp.cm.sendEmotion(emotion.Detect(result.Display, sp.emojis))
+
+			sp.cm.mu.Lock()
+			sp.cm.sendSpeakingResponse()
+			sp.cm.currentState = models.StateSpeaking
+			sp.cm.mu.Unlock()
+			sp.started = true
+		}
+
+		sp.cm.sendTextResponse(result.Display)
+		if sp.ttsFailed {
+			continue
+		}
+
+		audioData, err := sp.synthesize(result.Speech, lines)
+		if err != nil {
+			log.Printf("[Conversation] Error synthesizing speech: %v", err)
+			sp.playErrorPrompt()
+			continue
+		}
+		if len(audioData) > 0 {
+			sp.cm.sendAudio(audioData)
+			sp.spoken++
+		}
+	}
+}
+
+// synthesize 合成一句话，多角色朗读时按说话人分配音色
+func (sp *speechPipeline) synthesize(speech string, lines []dialogueLine) ([]byte, error) {
+	if sp.dialogue == nil {
+		return sp.cm.synthesize(speech)
+	}
+
+	markup := sp.dialogue.Markup(lines)
+	if markup == "" {
+		return nil, nil
+	}
+	return sp.cm.synthesizeMarkup(markup)
+}
+
+// playErrorPrompt 播放缓存的语音故障提示，之后的句子不再尝试合成，避免每句都等待超时
+func (sp *speechPipeline) playErrorPrompt() {
+	prompt := sp.cm.options.TTSErrorPrompt
+	if prompt.Len() == 0 {
+		return
+	}
+
+	sp.ttsFailed = true
+	_, audioData := prompt.Pick()
+	sp.cm.sendAudio(audioData)
+	sp.spoken++
+}
+
+// moderateSentence 审核一个句子，返回要朗读的文本，false 表示丢弃该句
+func (sp *speechPipeline) moderateSentence(sentence string) (string, bool) {
+	checked, action := sp.cm.moderate(sentence, moderation.DirectionOutput)
+	switch action {
+	case moderation.ActionBlock:
+		sp.stopped = true
+	case moderation.ActionReplace:
+		// 同一次回复只播放一次兜底回复
+		if sp.flagged {
+			return "", false
+		}
+	case moderation.ActionAllow:
+		return sentence, true
+	}
+
+	sp.flagged = true
+	return checked, true
+}
+
+// synthesize 使用当前会话的声音设置合成一句话，声音设置修改后从下一句开始生效
+func (cm *ConversationManager) synthesize(text string) ([]byte, error) {
+	if cm.ttsManager == nil {
+		return nil, nil
+	}
+
+	cm.mu.Lock()
+	request := cm.voice.request(text)
+	cm.mu.Unlock()
+
+	return cm.ttsManager.Synthesize(request)
+}
+
+// synthesizeSpeech 使用提供商的默认声音合成一句话
+func synthesizeSpeech(ttsManager *tts.TTSManager, text string) ([]byte, error) {
+	if ttsManager == nil {
+		return nil, nil
+	}
+	return ttsManager.SynthesizeSpeech(text, map[string]string{
+		"format": "mp3",
+	})
+}
+
+// sendEmotion 发送设备表情消息
+func (cm *ConversationManager) sendEmotion(e emotion.Emotion) {
+	msg := models.EmotionMessage{
+		Type:    models.TypeLLM,
+		Text:    e.Emoji(),
+		Emotion: string(e),
+	}
+
+	jsonData, _ := json.Marshal(msg)
+	log.Printf("[Conversation] Sending emotion %s to %s", e, cm.clientIP)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.conn.WriteMessage(websocket.TextMessage, jsonData); err != nil {
+		log.Printf("[Conversation] Error sending emotion: %v", err)
+	}
+}
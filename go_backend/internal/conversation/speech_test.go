@@ -0,0 +1,59 @@
+package conversation
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// brokenTTS 是一个总是失败的 TTS 提供商
+type brokenTTS struct {
+	calls int32
+}
+
+func (p *brokenTTS) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return nil, errors.New("vendor unavailable")
+}
+
+func (p *brokenTTS) GetVoices() ([]tts.Voice, error) { return nil, nil }
+func (p *brokenTTS) Initialize() error               { return nil }
+func (p *brokenTTS) Cleanup() error                  { return nil }
+
+func TestTTSErrorPromptPlaysOnce(t *testing.T) {
+	provider := &brokenTTS{}
+	ttsManager := tts.NewTTSManager()
+	ttsManager.RegisterProvider("broken", provider)
+	if err := ttsManager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := []byte("error-prompt")
+	cm, audio := newTestConversation(t, nil, ttsManager, Options{
+		TTSErrorPrompt: &FillerCache{phrases: []string{"抱歉，我的声音出了点问题。"}, audio: [][]byte{prompt}},
+	})
+
+	pipeline := cm.newSpeechPipeline(nil)
+	for _, sentence := range []string{"第一句话。", "第二句话。", "第三句话。"} {
+		pipeline.Push(sentence)
+	}
+	if spoken := pipeline.Close(); spoken != 1 {
+		t.Errorf("spoken = %d, want only the error prompt", spoken)
+	}
+
+	// 第一句失败后播放故障提示，之后的句子不再尝试合成
+	if n := atomic.LoadInt32(&provider.calls); n != 1 {
+		t.Errorf("provider called %d times, want 1", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(audio.Frames()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if frames := audio.Frames(); len(frames) != 1 || !bytes.Equal(frames[0], prompt) {
+		t.Errorf("device received %q, want the error prompt once", frames)
+	}
+}
@@ -2,18 +2,75 @@ package conversation
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/intent"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/knowledge"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/models"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/moderation"
 	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/usage"
 	"github.com/google/uuid"
 )
 
+// Options 包含会话的可调参数
+type Options struct {
+	// ReasoningFiller 推理模型思考期间播放的简短提示语，为空则不播放
+	ReasoningFiller string
+	
+	// Intent 意图识别管理器，为 nil 时所有输入都交给主模型
+	Intent *intent.IntentManager
+	
+	// MusicDir 播放音乐意图使用的本地音乐目录
+	MusicDir string
+	
+	// Usage 令牌用量统计，为 nil 时不统计也不限制配额
+	Usage *usage.Tracker
+	
+	// QuotaMessage 设备超出配额时朗读的提示
+	QuotaMessage string
+	
+	// Vision 回答照片问题的视觉模型，为 nil 时不提供拍照工具
+	Vision *llm.LLMManager
+	
+	// Moderation 内容安全审核，为 nil 时不审核
+	Moderation *moderation.ModerationManager
+	
+	// Fillers 预先合成的提示语，模型迟迟没有输出时播放，为 nil 时不播放
+	Fillers *FillerCache
+	
+	// FillerDelay 进入思考状态后多久仍没有可朗读的句子时播放提示语
+	FillerDelay time.Duration
+	
+	// Voices 按设备保存的声音设置，为 nil 时设置只在本次会话中有效
+	Voices *VoiceStore
+	
+	// SpeakerVoices 多角色朗读时指定说话人使用的音色，优先于角色预设，未指定的说话人自动分配
+	SpeakerVoices map[string]string
+	
+	// TTSErrorPrompt 预先合成的语音故障提示，所有 TTS 提供商都失败时播放，为 nil 时不播放
+	TTSErrorPrompt *FillerCache
+	
+	// SpeechBudget 单次回复朗读的最大字数，角色没有单独设置时使用，0 表示不限制
+	SpeechBudget int
+	
+	// ContinuePrompt 回复被截断后询问用户是否继续的话
+	ContinuePrompt string
+	
+	// Knowledge 本地知识库，为 nil 时不检索
+	Knowledge *knowledge.Base
+	
+	// KnowledgeTopK 每轮附加到提示词中的知识库片段数
+	KnowledgeTopK int
+}
+
 // ConversationManager 管理与单个客户端的会话状态
 type ConversationManager struct {
 	// WebSocket 连接
@@ -46,14 +103,29 @@ type ConversationManager struct {
 	
 	// 聊天历史
 	chatHistory     []llm.Message
+	
+	// 超出朗读预算后等待用户确认的续说内容
+	pendingContinuation *continuation
+	
+	// 当前角色、设备音量和声音设置
+	persona         Persona
+	deviceVolume    int
+	voice           VoiceSettings
+	
+	// 设备上传的照片和等待照片回答的问题
+	pendingImage    *deviceImage
+	photoQuestion   string
+	
+	// 会话参数
+	options         Options
 }
 
 // NewConversationManager 创建一个新的会话管理器
-func NewConversationManager(conn *websocket.Conn, llmManager *llm.LLMManager, ttsManager *tts.TTSManager, deviceID, clientID string) *ConversationManager {
-	// 创建初始系统提示
-	systemPrompt := "你是一个友好的语音助手，请简短、清晰地回答用户问题。回应应直接、有帮助，避免不必要的冗长解释。"
+func NewConversationManager(conn *websocket.Conn, llmManager *llm.LLMManager, ttsManager *tts.TTSManager, options Options, deviceID, clientID string) *ConversationManager {
+	// 使用默认角色的系统提示
+	persona, _ := findPersona(defaultPersonaName)
 	
-	return &ConversationManager{
+	cm := &ConversationManager{
 		conn:            conn,
 		currentState:    models.StateIdle,
 		audioFrameCount: 0,
@@ -67,13 +139,20 @@ func NewConversationManager(conn *websocket.Conn, llmManager *llm.LLMManager, tt
 		clientID:        clientID,
 		llmManager:      llmManager,
 		ttsManager:      ttsManager,
+		options:         options,
+		persona:         persona,
+		deviceVolume:    defaultDeviceVolume,
 		chatHistory: []llm.Message{
 			{
 				Role:    "system",
-				Content: systemPrompt,
+				Content: persona.Prompt(),
 			},
 		},
 	}
+	if deviceID != "" {
+		cm.loadVoiceSettings()
+	}
+	return cm
 }
 
 // Start 启动会话管理
@@ -184,6 +263,10 @@ func (cm *ConversationManager) HandleTextMessage(data []byte) error {
 			// 处理用户文本消息
 			go cm.processUserText(textContent)
 		}
+		
+	case models.TypeIoT:
+		// 设备上报的 IoT 状态（如当前音量）
+		cm.processIoTStates(jsonMsg)
 	}
 	
 	return nil
@@ -246,43 +329,195 @@ func (cm *ConversationManager) processUserSpeech() {
 
 // processUserText 处理用户文本（来自语音识别或直接文本输入）
 func (cm *ConversationManager) processUserText(text string) {
-	// 将用户消息添加到聊天历史
+	// 内容安全审核：按角色策略拦截或改写用户输入
+	text, action := cm.moderate(text, moderation.DirectionInput)
+	if action == moderation.ActionBlock || action == moderation.ActionReplace {
+		cm.speakResponse(text)
+		return
+	}
+	
+	// 上一个回答因为太长被截断，用户同意继续时接着说
+	if resume, ok := cm.takeContinuation(text); ok {
+		if cm.checkQuota() {
+			return
+		}
+		cm.respond(llm.Message{Role: "user", Content: text}, resume)
+		return
+	}
+	
+	// 意图识别：命中明确意图（退出、播放音乐、调音量、切换角色）时直接处理，不调用主模型
+	if cm.handleIntent(text) {
+		return
+	}
+	
+	// 超出令牌配额时不再调用大模型，直接播放提示
+	if cm.checkQuota() {
+		return
+	}
+	
+	// 将用户消息添加到聊天历史，设备刚上传过照片时一并带上
 	userMessage := llm.Message{
 		Role:    "user",
 		Content: text,
 	}
+	if image := cm.takePendingImage(); image != nil {
+		userMessage = llm.NewImageMessage(text, llm.NewImagePart(image.data, image.mimeType))
+	}
 	
+	cm.respond(userMessage, "")
+}
+
+// respond 把用户消息发给大模型，边生成边朗读回复，并保存到聊天历史
+// 带图片的消息交给视觉模型；主模型请求拍照时向设备下发拍照命令
+// resume 是上一次超出朗读预算而截断的内容，不为空时先朗读它，再让模型接着往下说
+func (cm *ConversationManager) respond(userMessage llm.Message, resume string) {
 	cm.mu.Lock()
-	cm.chatHistory = append(cm.chatHistory, userMessage)
+	cm.chatHistory = append(cm.chatHistory, userMessage.TextOnly()) // 历史中只保留文字，图片只发送一次
 	history := append([]llm.Message{}, cm.chatHistory...) // 复制一份历史记录
 	cm.mu.Unlock()
+	history[len(history)-1] = userMessage
+	history = cm.withKnowledge(history, userMessage.Content)
+	
+	// 朗读预算：提示模型控制长度，超出后在句子边界停止
+	budget := newSpeechBudget(cm.speechLimit())
+	if budget.limit > 0 {
+		history[0].Content += "\n" + budgetInstruction(budget.limit)
+	}
+	if resume != "" {
+		last := len(history) - 1
+		history = append(history[:last], llm.Message{Role: "system", Content: continueInstruction(resume)}, history[last])
+	}
+	
+	llmManager := cm.llmManager
+	options := cm.chatOptions()
+	if userMessage.HasImage() {
+		llmManager = cm.options.Vision
+		options = nil
+	}
 	
 	var llmResponse string
+	streamed := false // 回复是否已经在流式输出过程中播放
 	
 	// 调用LLM获取响应
-	if cm.llmManager != nil {
+	if llmManager != nil {
+		streamed = true
+		var contentBuilder strings.Builder
+		var reasoningBuilder strings.Builder
+		var splitter sentenceSplitter
+		var toolCalls []llm.ToolCall
+		var provider string      // 实际处理请求的提供商
+		usageRecorded := false // 是否收到了带用量的最后一个数据块
+		
+		// 一段时间内还没有可以朗读的句子时播放缓存的提示语，掩盖首字延迟
+		fillers := cm.newFillerTurn()
+		fillers.Arm(cm.options.Fillers, cm.options.FillerDelay)
+		
+		// 句子边播边合成，第一句会打断提示语；模型输出在朗读前逐句审核
+		pipeline := cm.newSpeechPipeline(fillers)
+		pipeline.moderate = cm.options.Moderation != nil
+		
+		// 续说时先朗读上次没读完的内容，不完整的尾句和模型的输出拼在一起
+		if resume != "" {
+			contentBuilder.WriteString(resume)
+			for _, sentence := range splitter.Feed(resume) {
+				if budget.Allow(sentence) {
+					pipeline.Push(sentence)
+				}
+			}
+		}
+		
 		// 使用流式响应方式获取大模型回复
-		err := cm.llmManager.StreamChat(history, nil, func(chunk *llm.ResponseChunk) error {
-			// 可以实现流式输出，这里简化处理
+		err := llmManager.StreamChat(history, options, func(chunk *llm.ResponseChunk) error {
+			// 推理内容只记录不朗读；首次出现时播放提示语，避免设备长时间无声
+			if reasoning, ok := chunk.Metadata[llm.MetadataReasoningContent].(string); ok && reasoning != "" {
+				reasoningBuilder.WriteString(reasoning)
+				if cm.options.ReasoningFiller != "" {
+					fillers.Play(cm.options.ReasoningFiller, nil)
+				}
+			}
+			
+			if name, ok := chunk.Metadata[llm.MetadataProvider].(string); ok {
+				provider = name
+			}
+			if tokens, ok := llm.UsageFromMetadata(chunk.Metadata); ok {
+				cm.recordUsage(chunk.Metadata, tokens)
+				usageRecorded = true
+			}
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+			
 			if chunk.Content != "" {
 				log.Printf("[Conversation] LLM chunk: %s", chunk.Content)
+				contentBuilder.WriteString(chunk.Content)
+				for _, sentence := range splitter.Feed(chunk.Content) {
+					if budget.Allow(sentence) {
+						pipeline.Push(sentence)
+					}
+				}
+				// 超出预算后又出现了完整的句子，说明回答还很长，停止生成
+				if budget.Truncated() {
+					return errSpeechBudgetReached
+				}
 			}
 			return nil
 		})
+		if errors.Is(err, errSpeechBudgetReached) {
+			err = nil
+			// 提前结束的流收不到带用量的最后一个数据块，按已生成的内容估算，避免长回复绕过配额
+			if !usageRecorded {
+				generated := strings.TrimPrefix(contentBuilder.String(), resume)
+				cm.recordUsage(map[string]interface{}{llm.MetadataProvider: provider}, llm.EstimateUsage(history, generated))
+			}
+		}
+		
+		if reasoningBuilder.Len() > 0 {
+			log.Printf("[Conversation] LLM reasoning finished (%d chars), excluded from history", len([]rune(reasoningBuilder.String())))
+		}
 		
+		llmResponse = contentBuilder.String()
 		if err != nil {
 			log.Printf("[Conversation] Error getting LLM response: %v", err)
-			llmResponse = "抱歉，我暂时无法回答您的问题。请稍后再试。"
-		} else {
-			// 获取完整响应（通过非流式方法）
-			response, err := cm.llmManager.Chat(history, nil)
-			if err != nil {
-				log.Printf("[Conversation] Error getting LLM response: %v", err)
+			if llmResponse == "" {
 				llmResponse = "抱歉，我暂时无法回答您的问题。请稍后再试。"
+				for _, sentence := range splitter.Feed(llmResponse) {
+					pipeline.Push(sentence)
+				}
+			}
+		}
+		
+		// 模型请求拍照：下发拍照命令，照片上传后再用视觉模型回答
+		if question, ok := findPhotoRequest(toolCalls, userMessage.Content); ok {
+			if err := cm.requestPhoto(question); err != nil {
+				log.Printf("[Conversation] Error requesting photo: %v", err)
+			} else if llmResponse == "" {
+				llmResponse = "好的，让我看一下。"
+				for _, sentence := range splitter.Feed(llmResponse) {
+					pipeline.Push(sentence)
+				}
+			}
+		}
+		
+		if rest := splitter.Flush(); rest != "" {
+			if budget.exceeded {
+				budget.Defer(rest)
 			} else {
-				llmResponse = response.Content
+				pipeline.Push(rest)
 			}
 		}
+		
+		// 回答被截断时询问是否继续，历史中只保存实际朗读的内容
+		if budget.Truncated() {
+			llmResponse = budget.text.String() + cm.continuePrompt()
+			pipeline.Push(cm.continuePrompt())
+			cm.offerContinuation(budget.Remainder())
+		}
+		pipeline.Close()
+		fillers.Stop()
+		fillers.Wait()
+		
+		// 有句子被审核处理过时，历史中保存实际朗读的内容
+		if pipeline.flagged {
+			llmResponse = pipeline.transcript.String()
+		}
 	} else {
 		// 如果LLM管理器不可用，生成随机响应
 		llmResponse = cm.generateRandomResponse()
@@ -304,59 +539,82 @@ func (cm *ConversationManager) processUserText(text string) {
 	}
 	cm.mu.Unlock()
 	
-	// 发送响应文本前的状态变更
+	if streamed {
+		cm.finishSpeaking()
+	} else {
+		cm.speakResponse(llmResponse)
+	}
+}
+
+// checkQuota 检查设备配额，超出时播放提示并返回 true
+func (cm *ConversationManager) checkQuota() bool {
+	if cm.options.Usage == nil {
+		return false
+	}
+	
 	cm.mu.Lock()
-	cm.sendSpeakingResponse()
-	cm.currentState = models.StateSpeaking
+	deviceID := cm.deviceID
 	cm.mu.Unlock()
 	
-	// 发送文本响应消息
-	cm.sendTextResponse(llmResponse)
+	err := cm.options.Usage.Check(deviceID)
+	if err == nil {
+		return false
+	}
+	
+	log.Printf("[Conversation] %v", err)
+	cm.speakResponse(cm.options.QuotaMessage)
+	return true
+}
+
+// recordUsage 记录一次大模型请求的令牌用量
+func (cm *ConversationManager) recordUsage(metadata map[string]interface{}, tokens llm.Usage) {
+	if cm.options.Usage == nil {
+		return
+	}
+	
+	provider, _ := metadata[llm.MetadataProvider].(string)
 	
-	// 如果TTS管理器可用，合成语音
-	var audioData []byte
-	var ttsErr error
+	cm.mu.Lock()
+	deviceID := cm.deviceID
+	cm.mu.Unlock()
 	
-	if cm.ttsManager != nil {
-		audioData, ttsErr = cm.ttsManager.SynthesizeSpeech(llmResponse, map[string]string{
-			"voice_id": "zh_female_qingxin", // 默认声音
-			"format":   "mp3",
-		})
-		
-		if ttsErr != nil {
-			log.Printf("[Conversation] Error synthesizing speech: %v", ttsErr)
+	cm.options.Usage.Record(deviceID, provider, tokens)
+	log.Printf("[Conversation] LLM usage for %s via %s: prompt=%d completion=%d total=%d",
+		deviceID, provider, tokens.PromptTokens, tokens.CompletionTokens, tokens.TotalTokens)
+}
+
+// recordAuxiliaryUsage 记录意图识别、内容审核等辅助请求的令牌用量
+// 独立配置的小模型不经过 LLMManager，元数据中没有提供商名称，此时记在 fallback 名下
+func (cm *ConversationManager) recordAuxiliaryUsage(calls []map[string]interface{}, fallback string) {
+	for _, metadata := range calls {
+		tokens, ok := llm.UsageFromMetadata(metadata)
+		if !ok {
+			continue
 		}
+		if provider, _ := metadata[llm.MetadataProvider].(string); provider == "" {
+			metadata = map[string]interface{}{llm.MetadataProvider: fallback}
+		}
+		cm.recordUsage(metadata, tokens)
+	}
+}
+
+// speakResponse 按句朗读一段完整的回复，结束后恢复到空闲状态
+func (cm *ConversationManager) speakResponse(text string) {
+	pipeline := cm.newSpeechPipeline(nil)
+	for _, sentence := range SplitSentences(text) {
+		pipeline.Push(sentence)
 	}
 	
-	// 如果合成成功，发送音频数据
-	if audioData != nil && len(audioData) > 0 {
-		// 分块发送大音频文件，每块最大32KB
-		chunkSize := 32 * 1024 // 32KB
-		
-		for i := 0; i < len(audioData); i += chunkSize {
-			end := i + chunkSize
-			if end > len(audioData) {
-				end = len(audioData)
-			}
-			
-			cm.mu.Lock()
-			err := cm.conn.WriteMessage(websocket.BinaryMessage, audioData[i:end])
-			cm.mu.Unlock()
-			
-			if err != nil {
-				log.Printf("[Conversation] Error sending audio chunk: %v", err)
-				break
-			}
-			
-			// 短暂暂停，避免发送过快
-			time.Sleep(50 * time.Millisecond)
-		}
-	} else {
-		// 模拟TTS延迟
+	if pipeline.Close() == 0 {
+		// 没有可播放的音频时模拟TTS延迟
 		time.Sleep(1 * time.Second)
 	}
 	
-	// 恢复到空闲状态
+	cm.finishSpeaking()
+}
+
+// finishSpeaking 恢复到空闲状态
+func (cm *ConversationManager) finishSpeaking() {
 	cm.mu.Lock()
 	cm.sendIdleResponse()
 	cm.currentState = models.StateIdle
@@ -364,6 +622,30 @@ func (cm *ConversationManager) processUserText(text string) {
 	cm.mu.Unlock()
 }
 
+// sendAudio 分块发送音频数据，每块最大32KB
+func (cm *ConversationManager) sendAudio(audioData []byte) {
+	chunkSize := 32 * 1024 // 32KB
+	
+	for i := 0; i < len(audioData); i += chunkSize {
+		end := i + chunkSize
+		if end > len(audioData) {
+			end = len(audioData)
+		}
+		
+		cm.mu.Lock()
+		err := cm.conn.WriteMessage(websocket.BinaryMessage, audioData[i:end])
+		cm.mu.Unlock()
+		
+		if err != nil {
+			log.Printf("[Conversation] Error sending audio chunk: %v", err)
+			break
+		}
+		
+		// 短暂暂停，避免发送过快
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 // processClientHello 处理客户端的 hello 消息
 func (cm *ConversationManager) processClientHello(data map[string]interface{}) {
 	// 提取客户端和设备 ID
@@ -376,6 +658,7 @@ func (cm *ConversationManager) processClientHello(data map[string]interface{}) {
 		if deviceID, ok := data["device_id"].(string); ok {
 			cm.deviceID = deviceID
 			log.Printf("[Conversation] Device ID from message: %s", deviceID)
+			cm.loadVoiceSettings()
 		}
 	} else {
 		log.Printf("[Conversation] Using Device ID from header: %s", cm.deviceID)
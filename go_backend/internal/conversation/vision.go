@@ -0,0 +1,151 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/models"
+)
+
+// takePhotoToolName 是提供给主模型的拍照工具名称
+const takePhotoToolName = "take_photo"
+
+// pendingImageTTL 是上传的照片可用于回答下一个问题的有效期
+const pendingImageTTL = 2 * time.Minute
+
+// ErrVisionUnavailable 表示没有配置视觉模型
+var ErrVisionUnavailable = errors.New("vision model not configured")
+
+// takePhotoTool 让主模型在需要看东西时请求设备拍照
+var takePhotoTool = llm.Tool{
+	Type: "function",
+	Function: llm.ToolFunction{
+		Name:        takePhotoToolName,
+		Description: "用设备摄像头拍一张照片。当用户想让你看看眼前的东西、识别物体、读取文字或描述场景时调用。",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"question": map[string]interface{}{
+					"type":        "string",
+					"description": "需要根据照片回答的问题",
+				},
+			},
+			"required": []string{"question"},
+		},
+	},
+}
+
+// deviceImage 是设备上传的一张照片
+type deviceImage struct {
+	data       []byte
+	mimeType   string
+	receivedAt time.Time
+}
+
+// AttachImage 把设备上传的照片关联到当前会话
+// 如果有等待照片的问题（或上传时附带了问题），立即用视觉模型回答；否则照片留给用户的下一个问题
+func (cm *ConversationManager) AttachImage(data []byte, mimeType, question string) error {
+	if cm.options.Vision == nil {
+		return ErrVisionUnavailable
+	}
+
+	cm.mu.Lock()
+	cm.pendingImage = &deviceImage{
+		data:       data,
+		mimeType:   mimeType,
+		receivedAt: time.Now(),
+	}
+	if question == "" {
+		question = cm.photoQuestion
+	}
+	cm.photoQuestion = ""
+	if question != "" {
+		cm.currentState = models.StateThinking
+		cm.sendThinkingResponse()
+	}
+	cm.mu.Unlock()
+
+	log.Printf("[Conversation] Received %d byte image (%s) from %s", len(data), mimeType, cm.clientIP)
+
+	if question != "" {
+		go cm.answerWithImage(question)
+	}
+	return nil
+}
+
+// answerWithImage 用刚上传的照片回答问题
+func (cm *ConversationManager) answerWithImage(question string) {
+	if cm.checkQuota() {
+		return
+	}
+
+	image := cm.takePendingImage()
+	if image == nil {
+		cm.speakResponse("抱歉，照片已经过期了，请再拍一张吧。")
+		return
+	}
+
+	cm.respond(llm.NewImageMessage(question, llm.NewImagePart(image.data, image.mimeType)), "")
+}
+
+// takePendingImage 取出尚未过期的照片，取出后不再重复使用
+func (cm *ConversationManager) takePendingImage() *deviceImage {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	image := cm.pendingImage
+	cm.pendingImage = nil
+	if image == nil || time.Since(image.receivedAt) > pendingImageTTL {
+		return nil
+	}
+	return image
+}
+
+// chatOptions 返回主模型的请求参数，配置了视觉模型时提供拍照工具
+func (cm *ConversationManager) chatOptions() *llm.ChatOptions {
+	if cm.options.Vision == nil {
+		return nil
+	}
+	return &llm.ChatOptions{
+		Tools: []llm.Tool{takePhotoTool},
+	}
+}
+
+// requestPhoto 通过 IoT 命令让设备拍照，照片上传后回答 question
+func (cm *ConversationManager) requestPhoto(question string) error {
+	if err := cm.sendIoTCommand(models.IoTCommand{
+		Name:       "Camera",
+		Method:     "TakePhoto",
+		Parameters: map[string]interface{}{"question": question},
+	}); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	cm.photoQuestion = question
+	cm.mu.Unlock()
+
+	log.Printf("[Conversation] Requested photo from %s for question: %s", cm.clientIP, question)
+	return nil
+}
+
+// findPhotoRequest 从工具调用中找出拍照请求，参数缺失时使用用户原话作为问题
+func findPhotoRequest(toolCalls []llm.ToolCall, fallbackQuestion string) (string, bool) {
+	for _, call := range toolCalls {
+		if call.Function.Name != takePhotoToolName {
+			continue
+		}
+
+		var args struct {
+			Question string `json:"question"`
+		}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || args.Question == "" {
+			return fallbackQuestion, true
+		}
+		return args.Question, true
+	}
+	return "", false
+}
@@ -0,0 +1,245 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/intent"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// 语速和音调倍率的可调范围
+const (
+	minVoiceRatio = 0.5
+	maxVoiceRatio = 2.0
+)
+
+// VoiceSettings 是一台设备的声音设置，零值表示使用默认值
+type VoiceSettings struct {
+	Voice  string  `json:"voice,omitempty"`  // 音色ID，可带提供商前缀
+	Speed  float64 `json:"speed,omitempty"`  // 语速倍率
+	Pitch  float64 `json:"pitch,omitempty"`  // 音调倍率
+	Volume *int    `json:"volume,omitempty"` // 最近一次设置的设备音量
+}
+
+// request 返回按当前设置合成 text 的请求
+func (s VoiceSettings) request(text string) tts.SynthesisRequest {
+	return tts.SynthesisRequest{
+		Text:  text,
+		Voice: s.Voice,
+		Speed: s.Speed,
+		Pitch: s.Pitch,
+	}
+}
+
+// VoiceStore 按设备保存声音设置，设备重新连接后沿用上次的设置
+type VoiceStore struct {
+	mu      sync.Mutex
+	path    string // 持久化文件路径，为空时只保存在内存中
+	devices map[string]VoiceSettings
+}
+
+// NewVoiceStore 创建一个声音设置存储，path 为空时不持久化
+func NewVoiceStore(path string) *VoiceStore {
+	return &VoiceStore{
+		path:    path,
+		devices: make(map[string]VoiceSettings),
+	}
+}
+
+// Load 从持久化文件加载设置，文件不存在时从空开始
+func (s *VoiceStore) Load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading voice settings file: %w", err)
+	}
+
+	devices := make(map[string]VoiceSettings)
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return fmt.Errorf("error parsing voice settings file: %w", err)
+	}
+
+	s.mu.Lock()
+	s.devices = devices
+	s.mu.Unlock()
+	return nil
+}
+
+// Get 返回设备的声音设置
+func (s *VoiceStore) Get(deviceID string) VoiceSettings {
+	if s == nil || deviceID == "" {
+		return VoiceSettings{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.devices[deviceID]
+}
+
+// Set 保存设备的声音设置并写入持久化文件
+// 设置变化不频繁，每次修改都立即写入
+func (s *VoiceStore) Set(deviceID string, settings VoiceSettings) error {
+	if s == nil || deviceID == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.devices[deviceID] = settings
+	data, err := json.MarshalIndent(s.devices, "", "  ")
+	s.mu.Unlock()
+	if err != nil || s.path == "" {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error creating voice settings directory: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免写到一半时进程退出导致文件损坏
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing voice settings file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("error replacing voice settings file: %w", err)
+	}
+	return nil
+}
+
+// loadVoiceSettings 加载设备上次的声音设置（调用方需持有锁）
+func (cm *ConversationManager) loadVoiceSettings() {
+	cm.voice = cm.options.Voices.Get(cm.deviceID)
+	if cm.voice.Volume != nil {
+		cm.deviceVolume = *cm.voice.Volume
+	}
+	if cm.voice != (VoiceSettings{}) {
+		log.Printf("[Conversation] Restored voice settings for %s: %+v", cm.deviceID, cm.voice)
+	}
+}
+
+// updateVoiceSettings 修改当前会话的声音设置并按设备保存，从下一句开始生效
+func (cm *ConversationManager) updateVoiceSettings(update func(*VoiceSettings)) {
+	cm.mu.Lock()
+	update(&cm.voice)
+	settings := cm.voice
+	deviceID := cm.deviceID
+	cm.mu.Unlock()
+
+	if err := cm.options.Voices.Set(deviceID, settings); err != nil {
+		log.Printf("[Conversation] Error saving voice settings: %v", err)
+	}
+}
+
+// handleChangeVoiceIntent 调整语速、音调或音色，并用新的声音回复
+func (cm *ConversationManager) handleChangeVoiceIntent(result *intent.Result) {
+	if result.BoolArg("reset") {
+		cm.updateVoiceSettings(func(s *VoiceSettings) {
+			s.Voice, s.Speed, s.Pitch = "", 0, 0
+		})
+		cm.speakResponse("好的，已经恢复默认的声音。")
+		return
+	}
+
+	var reply string
+	if gender, voiceArg := result.StringArg("gender"), result.StringArg("voice"); gender != "" || voiceArg != "" {
+		voice, ok := cm.pickVoice(gender, voiceArg)
+		if !ok {
+			cm.speakResponse("抱歉，没有找到合适的声音。")
+			return
+		}
+		cm.updateVoiceSettings(func(s *VoiceSettings) {
+			s.Voice = voice.ID
+		})
+		reply = fmt.Sprintf("好的，换成%s的声音了。", voice.Name)
+	}
+
+	speed, hasSpeed := result.FloatArg("speed")
+	speedDelta, hasSpeedDelta := result.FloatArg("speed_delta")
+	pitch, hasPitch := result.FloatArg("pitch")
+	pitchDelta, hasPitchDelta := result.FloatArg("pitch_delta")
+	if hasSpeed || hasSpeedDelta || hasPitch || hasPitchDelta {
+		cm.updateVoiceSettings(func(s *VoiceSettings) {
+			s.Speed = adjustRatio(s.Speed, speed, hasSpeed, speedDelta)
+			s.Pitch = adjustRatio(s.Pitch, pitch, hasPitch, pitchDelta)
+		})
+		switch {
+		case speedDelta < 0:
+			reply = "好的，我会说慢一点。"
+		case speedDelta > 0:
+			reply = "好的，我会说快一点。"
+		case reply == "":
+			reply = "好的，声音已经调整了。"
+		}
+	}
+
+	if reply == "" {
+		log.Printf("[Conversation] change_voice intent without usable arguments: %v", result.Arguments)
+		reply = "你想让我怎么调整声音呢？可以说说慢一点，或者换个男声。"
+	}
+	cm.speakResponse(reply)
+}
+
+// adjustRatio 按绝对值或增量调整倍率，结果限制在可调范围内，current 为 0 表示默认倍率 1
+func adjustRatio(current, absolute float64, hasAbsolute bool, delta float64) float64 {
+	if current <= 0 {
+		current = 1.0
+	}
+	if hasAbsolute {
+		current = absolute
+	}
+	current += delta
+	if current < minVoiceRatio {
+		current = minVoiceRatio
+	} else if current > maxVoiceRatio {
+		current = maxVoiceRatio
+	}
+	if current == 1.0 {
+		return 0
+	}
+	return current
+}
+
+// pickVoice 从音色目录中选择音色：voice 为 next 时换成下一个音色，为其他值时按ID或名称查找
+// gender 不为空时只在该性别的中文音色中选择，并尽量避开当前音色
+func (cm *ConversationManager) pickVoice(gender, voice string) (tts.Voice, bool) {
+	if cm.ttsManager == nil {
+		return tts.Voice{}, false
+	}
+	voices, err := cm.ttsManager.Voices(tts.VoiceFilter{Language: "zh", Gender: gender})
+	if err != nil || len(voices) == 0 {
+		log.Printf("[Conversation] No voices available (gender=%q): %v", gender, err)
+		return tts.Voice{}, false
+	}
+
+	if voice != "" && voice != "next" {
+		for _, v := range voices {
+			if v.ID == voice || v.Name == voice || v.ID == tts.QualifyVoiceID(v.Provider, voice) {
+				return v, true
+			}
+		}
+		return tts.Voice{}, false
+	}
+
+	cm.mu.Lock()
+	current := cm.voice.Voice
+	cm.mu.Unlock()
+
+	// 依次轮换：选当前音色之后的一个，当前音色不在列表中时选第一个
+	for i, v := range voices {
+		if v.ID == current {
+			return voices[(i+1)%len(voices)], true
+		}
+	}
+	return voices[0], true
+}
@@ -0,0 +1,148 @@
+// Package emotion 为助手回复推断设备屏幕上显示的表情
+//
+// 固件根据 {"type":"llm","emotion":"happy"} 消息切换表情，只认识固定的一组情绪名称。
+// 优先使用模型按提示在回复开头给出的表情符号，没有时退回到关键词分类。
+package emotion
+
+import (
+	"strings"
+)
+
+// Emotion 表示固件支持的情绪名称
+type Emotion string
+
+// 固件支持的情绪集合
+const (
+	Neutral     Emotion = "neutral"
+	Happy       Emotion = "happy"
+	Laughing    Emotion = "laughing"
+	Funny       Emotion = "funny"
+	Sad         Emotion = "sad"
+	Angry       Emotion = "angry"
+	Crying      Emotion = "crying"
+	Loving      Emotion = "loving"
+	Embarrassed Emotion = "embarrassed"
+	Surprised   Emotion = "surprised"
+	Shocked     Emotion = "shocked"
+	Thinking    Emotion = "thinking"
+	Winking     Emotion = "winking"
+	Cool        Emotion = "cool"
+	Relaxed     Emotion = "relaxed"
+	Delicious   Emotion = "delicious"
+	Kissy       Emotion = "kissy"
+	Confident   Emotion = "confident"
+	Sleepy      Emotion = "sleepy"
+	Silly       Emotion = "silly"
+	Confused    Emotion = "confused"
+)
+
+// emotionEmojis 是每种情绪在显示消息中使用的代表表情
+var emotionEmojis = map[Emotion]string{
+	Neutral:     "😶",
+	Happy:       "🙂",
+	Laughing:    "😆",
+	Funny:       "😂",
+	Sad:         "😔",
+	Angry:       "😠",
+	Crying:      "😭",
+	Loving:      "😍",
+	Embarrassed: "😳",
+	Surprised:   "😲",
+	Shocked:     "😱",
+	Thinking:    "🤔",
+	Winking:     "😉",
+	Cool:        "😎",
+	Relaxed:     "😌",
+	Delicious:   "🤤",
+	Kissy:       "😘",
+	Confident:   "😏",
+	Sleepy:      "😴",
+	Silly:       "😜",
+	Confused:    "🙄",
+}
+
+// emojiEmotions 把模型可能输出的表情符号映射到固件情绪
+var emojiEmotions = map[string]Emotion{
+	"😶": Neutral, "😐": Neutral, "🙂": Happy, "😊": Happy, "😀": Happy, "😃": Happy, "😄": Happy,
+	"☺": Happy, "🥰": Loving, "😍": Loving, "❤": Loving, "💕": Loving, "😆": Laughing, "😁": Laughing,
+	"🤣": Funny, "😂": Funny, "😹": Funny, "😔": Sad, "😢": Sad, "😞": Sad, "🥺": Sad, "😟": Sad,
+	"😠": Angry, "😡": Angry, "🤬": Angry, "😭": Crying, "😳": Embarrassed, "😅": Embarrassed,
+	"😲": Surprised, "😮": Surprised, "😯": Surprised, "🤩": Surprised, "😱": Shocked, "😨": Shocked,
+	"🤔": Thinking, "🧐": Thinking, "😉": Winking, "😎": Cool, "😌": Relaxed, "🤤": Delicious,
+	"😋": Delicious, "😘": Kissy, "😚": Kissy, "😏": Confident, "💪": Confident, "👍": Confident,
+	"😴": Sleepy, "🥱": Sleepy, "😜": Silly, "🤪": Silly, "😝": Silly, "🙄": Confused, "😕": Confused,
+	"🤷": Confused, "🎉": Happy, "✨": Happy, "🌟": Happy,
+}
+
+// keywordRule 是关键词分类规则
+type keywordRule struct {
+	emotion  Emotion
+	keywords []string
+}
+
+// keywordRules 按优先级排列的关键词分类规则
+var keywordRules = []keywordRule{
+	{Crying, []string{"太难过了", "哭", "伤心欲绝"}},
+	{Sad, []string{"抱歉", "对不起", "遗憾", "难过", "可惜", "伤心", "不幸"}},
+	{Angry, []string{"生气", "愤怒", "气死"}},
+	{Shocked, []string{"天哪", "太可怕", "吓"}},
+	{Surprised, []string{"哇", "真的吗", "居然", "竟然", "没想到"}},
+	{Laughing, []string{"哈哈", "嘻嘻", "好笑"}},
+	{Loving, []string{"喜欢你", "爱你", "想你", "抱抱"}},
+	{Thinking, []string{"让我想想", "我想一下", "思考"}},
+	{Confused, []string{"不太明白", "不确定", "不清楚", "疑惑"}},
+	{Sleepy, []string{"晚安", "困了", "睡觉", "好梦"}},
+	{Delicious, []string{"好吃", "美味", "香喷喷"}},
+	{Cool, []string{"厉害", "酷", "帅"}},
+	{Happy, []string{"太好了", "恭喜", "开心", "高兴", "好的", "当然", "没问题", "你好"}},
+}
+
+// FromEmoji 把表情符号映射到固件情绪
+func FromEmoji(emoji string) (Emotion, bool) {
+	emoji = strings.TrimRight(emoji, "\uFE0F\u200D")
+	if emotion, ok := emojiEmotions[emoji]; ok {
+		return emotion, true
+	}
+
+	// 组合表情（肤色、连接符）按第一个字符匹配
+	for _, r := range emoji {
+		if emotion, ok := emojiEmotions[string(r)]; ok {
+			return emotion, true
+		}
+		break
+	}
+	return "", false
+}
+
+// Classify 用关键词对文本做轻量情绪分类，没有命中时返回 Neutral
+func Classify(text string) Emotion {
+	for _, rule := range keywordRules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(text, keyword) {
+				return rule.emotion
+			}
+		}
+	}
+	return Neutral
+}
+
+// Detect 优先根据表情符号判断情绪，没有可识别的表情时按文本分类
+func Detect(text string, emojis []string) Emotion {
+	for _, emoji := range emojis {
+		if emotion, ok := FromEmoji(emoji); ok {
+			return emotion
+		}
+	}
+	return Classify(text)
+}
+
+// Emoji 返回情绪对应的代表表情
+func (e Emotion) Emoji() string {
+	if emoji, ok := emotionEmojis[e]; ok {
+		return emoji
+	}
+	return emotionEmojis[Neutral]
+}
+
+// PromptInstruction 是追加到系统提示中、要求模型输出开头表情的说明
+const PromptInstruction = "每次回复请以一个最能表达你当前情绪的表情符号开头（例如😊、😂、😔、🤔、😲），之后不要再使用表情符号。"
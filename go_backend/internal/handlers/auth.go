@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Authenticator 校验设备请求携带的访问令牌
+// 设备固件在 WebSocket 握手和 HTTP 请求中以 Authorization: Bearer <令牌> 请求头携带令牌，
+// 未配置任何令牌时不做校验，与旧版本行为一致
+type Authenticator struct {
+	tokens []string
+}
+
+// NewAuthenticator 创建访问令牌校验器，空白令牌会被忽略
+func NewAuthenticator(tokens []string) *Authenticator {
+	a := &Authenticator{}
+	for _, token := range tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			a.tokens = append(a.tokens, token)
+		}
+	}
+	return a
+}
+
+// Enabled 判断是否配置了访问令牌
+func (a *Authenticator) Enabled() bool {
+	return a != nil && len(a.tokens) > 0
+}
+
+// Check 判断请求是否携带有效令牌，未启用校验时总是通过
+func (a *Authenticator) Check(r *http.Request) bool {
+	if !a.Enabled() {
+		return true
+	}
+
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return false
+	}
+	token := []byte(strings.TrimSpace(header[len("Bearer "):]))
+
+	valid := false
+	for _, expected := range a.tokens {
+		// 逐个比较全部令牌，耗时不随匹配位置变化
+		if subtle.ConstantTimeCompare(token, []byte(expected)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// Require 包装 HTTP 处理函数，未携带有效令牌的请求返回 401
+func (a *Authenticator) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.Check(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticator(t *testing.T) {
+	auth := NewAuthenticator([]string{"token-a", " ", "token-b"})
+
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"Bearer token-a", true},
+		{"bearer token-b", true},
+		{"Bearer  token-b ", true},
+		{"Bearer token-c", false},
+		{"token-a", false},
+		{"Bearer ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/xiaozhi/v1/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := auth.Check(r); got != tt.ok {
+			t.Errorf("Check(%q) = %v, want %v", tt.header, got, tt.ok)
+		}
+	}
+
+	// 未配置令牌时不做校验
+	if open := NewAuthenticator(nil); open.Enabled() || !open.Check(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("authenticator without tokens rejects requests")
+	}
+}
+
+func TestVisionUploadRequiresToken(t *testing.T) {
+	handler := VisionUploadHandler(NewAuthenticator([]string{"secret"}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/vision/upload", strings.NewReader("image"))
+	r.Header.Set("Device-Id", "aa:bb:cc:dd:ee:ff")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", w.Code)
+	}
+
+	// 令牌有效但设备没有会话
+	r = httptest.NewRequest(http.MethodPost, "/api/vision/upload", strings.NewReader("image"))
+	r.Header.Set("Device-Id", "aa:bb:cc:dd:ee:ff")
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status with token = %d, want 404 for a device without a session", w.Code)
+	}
+}
+
+func TestAuthenticatorRequire(t *testing.T) {
+	called := false
+	handler := NewAuthenticator([]string{"secret"}).Require(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/api/usage", nil))
+	if w.Code != http.StatusUnauthorized || called {
+		t.Errorf("status without token = %d, handler called %v", w.Code, called)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/usage", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != http.StatusOK || !called {
+		t.Errorf("status with token = %d, handler called %v", w.Code, called)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/conversation"
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// maxPreviewTextRunes 是试听文本的最大字数
+const maxPreviewTextRunes = 100
+
+// maxSynthesizeBodySize 是合成请求体的大小上限
+const maxSynthesizeBodySize = 64 << 10 // 64KB
+
+// synthesizeRequest 是合成接口的请求体
+type synthesizeRequest struct {
+	Text   string  `json:"text"`
+	Voice  string  `json:"voice,omitempty"`  // 可带提供商前缀，为空时使用默认音色
+	Format string  `json:"format,omitempty"` // mp3、wav、pcm、opus、p3，默认 mp3
+	Speed  float64 `json:"speed,omitempty"`
+	Pitch  float64 `json:"pitch,omitempty"`
+	Volume float64 `json:"volume,omitempty"`
+	SSML   bool    `json:"ssml,omitempty"` // text 是 SSML-lite 标记，整体合成不按句切分
+}
+
+// TTSVoicesHandler 返回所有 TTS 提供商的音色目录
+// 音色ID形如 提供商:音色，可用 language、gender、tag（名称或 名称=取值）参数过滤
+func TTSVoicesHandler(ttsManager *tts.TTSManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		voices, err := ttsManager.Voices(tts.VoiceFilter{
+			Language: query.Get("language"),
+			Gender:   query.Get("gender"),
+			Tag:      query.Get("tag"),
+		})
+		if err != nil {
+			http.Error(w, "Failed to get voices: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(voices)
+	}
+}
+
+// TTSVoicePreviewHandler 用指定音色合成一段示例语音，可用 text 参数替换默认的示例句子
+// 路由需包含 {id} 路径参数
+func TTSVoicePreviewHandler(ttsManager *tts.TTSManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.PathValue("id")
+		text := r.URL.Query().Get("text")
+		if len([]rune(text)) > maxPreviewTextRunes {
+			http.Error(w, "Text too long, at most "+strconv.Itoa(maxPreviewTextRunes)+" characters", http.StatusBadRequest)
+			return
+		}
+
+		audioData, err := ttsManager.PreviewVoice(id, text)
+		if err != nil {
+			if errors.Is(err, tts.ErrVoiceNotFound) || errors.Is(err, tts.ErrProviderNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Printf("[TTS] Failed to preview voice %s: %v", id, err)
+			http.Error(w, "Failed to synthesize preview: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", tts.AudioContentType(audioData))
+		w.Header().Set("Content-Length", strconv.Itoa(len(audioData)))
+		w.Write(audioData)
+	}
+}
+
+// synthesisErrorTrailer 是音频开始输出后合成失败时设置的响应尾部字段
+const synthesisErrorTrailer = "X-Synthesis-Error"
+
+// TTSSynthesizeHandler 返回把任意文本合成为音频的 HTTP 处理函数
+// 文本按句合成，每合成一句就写出并刷新，长文本也能尽快开始播放；maxRunes 限制文本字数。
+// 第一句失败时返回 502，提供商给不出请求的格式时返回 415；之后的句子失败时音频已经开始输出，响应提前结束，
+// 并在 X-Synthesis-Error 尾部字段中给出原因，客户端据此判断音频不完整
+func TTSSynthesizeHandler(ttsManager *tts.TTSManager, maxRunes int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSynthesizeBodySize)
+		var req synthesizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" {
+			http.Error(w, "text is required", http.StatusBadRequest)
+			return
+		}
+		if maxRunes > 0 && len([]rune(req.Text)) > maxRunes {
+			http.Error(w, "Text too long, at most "+strconv.Itoa(maxRunes)+" characters", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if req.Format == "" {
+			req.Format = tts.FormatMP3
+		}
+		if !tts.IsSupportedFormat(req.Format) {
+			http.Error(w, "Unsupported format: "+req.Format, http.StatusBadRequest)
+			return
+		}
+
+		segments := []string{req.Text}
+		if !req.SSML {
+			segments = conversation.SplitSentences(req.Text)
+		}
+
+		stream := tts.NewAudioStream(req.Format)
+		flusher, _ := w.(http.Flusher)
+		for i, segment := range segments {
+			audioData, err := ttsManager.Synthesize(tts.SynthesisRequest{
+				Text:   segment,
+				Markup: req.SSML,
+				Voice:  req.Voice,
+				Format: tts.ProviderFormat(req.Format),
+				Speed:  req.Speed,
+				Pitch:  req.Pitch,
+				Volume: req.Volume,
+			})
+			if err == nil {
+				audioData, err = stream.Next(audioData)
+			}
+			if err != nil {
+				log.Printf("[TTS] Failed to synthesize segment %d: %v", i+1, err)
+				if i == 0 {
+					// 提供商给不出请求的格式时拒绝请求，而不是返回另一种格式的音频
+					if errors.Is(err, tts.ErrUnsupportedConversion) {
+						http.Error(w, "Format "+req.Format+" is not available from the voice's provider: "+err.Error(), http.StatusUnsupportedMediaType)
+						return
+					}
+					http.Error(w, "Failed to synthesize: "+err.Error(), http.StatusBadGateway)
+					return
+				}
+				// 音频已经开始输出，只能提前结束，并通过尾部字段告知客户端
+				reason := fmt.Sprintf("segment %d of %d: %v", i+1, len(segments), err)
+				w.Header().Set(synthesisErrorTrailer, strings.Join(strings.Fields(reason), " "))
+				return
+			}
+
+			if i == 0 {
+				w.Header().Set("Content-Type", tts.FormatContentType(req.Format))
+				w.Header().Set("X-Audio-Format", req.Format)
+				w.Header().Set("Trailer", synthesisErrorTrailer)
+			}
+			if _, err := w.Write(audioData); err != nil {
+				log.Printf("[TTS] Client disconnected during synthesis: %v", err)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/tts"
+)
+
+// flakyProvider 合成包含“失败”的句子时报错，其余句子返回一段 WAV
+type flakyProvider struct{}
+
+func (flakyProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	if strings.Contains(text, "失败") {
+		return nil, errors.New("engine crashed")
+	}
+	// 16kHz 单声道 16 位，100 个采样
+	header := []byte("RIFF\xec\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x80\x3e\x00\x00\x00\x7d\x00\x00\x02\x00\x10\x00data\xc8\x00\x00\x00")
+	return append(header, make([]byte, 200)...), nil
+}
+
+func (flakyProvider) GetVoices() ([]tts.Voice, error) { return nil, nil }
+func (flakyProvider) Initialize() error               { return nil }
+func (flakyProvider) Cleanup() error                  { return nil }
+
+// mp3Provider 只能返回 MP3
+type mp3Provider struct{}
+
+func (mp3Provider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	return []byte{0xFF, 0xFB, 0x90, 0x00, 0x01, 0x02}, nil
+}
+
+func (mp3Provider) GetVoices() ([]tts.Voice, error) { return nil, nil }
+func (mp3Provider) Initialize() error               { return nil }
+func (mp3Provider) Cleanup() error                  { return nil }
+
+func synthesize(t *testing.T, text string) *http.Response {
+	return synthesizeWith(t, flakyProvider{}, text, "wav")
+}
+
+func synthesizeWith(t *testing.T, provider tts.Provider, text, format string) *http.Response {
+	t.Helper()
+
+	manager := tts.NewTTSManager()
+	manager.RegisterProvider("test", provider)
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(TTSSynthesizeHandler(manager, 0))
+	t.Cleanup(server.Close)
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"text":"`+text+`","format":"`+format+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestSynthesizeReportsLaterFailureInTrailer(t *testing.T) {
+	resp := synthesize(t, "第一句话。这一句会失败。最后一句。")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) != 44+200 {
+		t.Errorf("body = %d bytes, want only the first sentence", len(body))
+	}
+	// 尾部字段在读完响应体之后才可用
+	if got := resp.Trailer.Get("X-Synthesis-Error"); !strings.Contains(got, "segment 2 of 3") {
+		t.Errorf("X-Synthesis-Error = %q", got)
+	}
+}
+
+func TestSynthesizeCompleteHasNoErrorTrailer(t *testing.T) {
+	resp := synthesize(t, "第一句话。第二句话。")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || len(body) != 44+400 {
+		t.Fatalf("status = %d, body = %d bytes", resp.StatusCode, len(body))
+	}
+	if got := resp.Trailer.Get("X-Synthesis-Error"); got != "" {
+		t.Errorf("X-Synthesis-Error = %q on a complete response", got)
+	}
+}
+
+func TestSynthesizeFirstSegmentFailure(t *testing.T) {
+	resp := synthesize(t, "一开始就失败。")
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", resp.StatusCode)
+	}
+}
+
+func TestSynthesizeRejectsUnavailableFormat(t *testing.T) {
+	for _, format := range []string{"wav", "pcm", "p3"} {
+		resp := synthesizeWith(t, mp3Provider{}, "你好。", format)
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("%s from an mp3-only provider: status = %d, want 415", format, resp.StatusCode)
+		}
+	}
+
+	resp := synthesizeWith(t, mp3Provider{}, "你好。", "mp3")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Audio-Format") != "mp3" {
+		t.Errorf("mp3: status = %d, format %q", resp.StatusCode, resp.Header.Get("X-Audio-Format"))
+	}
+}
+
+func TestSynthesizeRequiresToken(t *testing.T) {
+	manager := tts.NewTTSManager()
+	manager.RegisterProvider("flaky", flakyProvider{})
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthenticator([]string{"ops-token"})
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"synthesize": auth.Require(TTSSynthesizeHandler(manager, 0)),
+		"preview":    auth.Require(TTSVoicePreviewHandler(manager)),
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/tts/synthesize", strings.NewReader(`{"text":"你好"}`))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without token: status = %d, want 401", name, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tts/synthesize", strings.NewReader(`{"text":"你好","format":"wav"}`))
+	r.Header.Set("Authorization", "Bearer ops-token")
+	w := httptest.NewRecorder()
+	auth.Require(TTSSynthesizeHandler(manager, 0))(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "audio/wav" {
+		t.Errorf("with token: status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/conversation"
+)
+
+// maxImageSize 是设备上传照片的大小上限
+const maxImageSize = 8 << 20 // 8MB
+
+// VisionUploadHandler 返回接收设备照片的 HTTP 处理函数
+// 设备通过 Device-Id 请求头（或 device_id 参数）标识自己，照片关联到该设备当前的 WebSocket 会话。
+// 请求需要携带与 WebSocket 相同的访问令牌；会话记录了 Client-Id 时，请求的 Client-Id 也必须一致。
+// 支持 multipart 表单（file 字段，可选 question 字段）或直接以 image/* 作为请求体上传。
+func VisionUploadHandler(auth *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !auth.Check(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		deviceID := r.Header.Get("Device-Id")
+		if deviceID == "" {
+			deviceID = r.URL.Query().Get("device_id")
+		}
+		if deviceID == "" {
+			http.Error(w, "Device-Id is required", http.StatusBadRequest)
+			return
+		}
+
+		cm := findConversationByDevice(deviceID)
+		if cm == nil {
+			http.Error(w, "No active session for device", http.StatusNotFound)
+			return
+		}
+		if clientID, _ := cm.GetClientInfo(); clientID != "" && r.Header.Get("Client-Id") != clientID {
+			log.Printf("[Vision] Rejected upload for %s: Client-Id does not match the session", deviceID)
+			http.Error(w, "Client-Id does not match the device session", http.StatusForbidden)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImageSize)
+		data, mimeType, question, err := readUploadedImage(r)
+		if err != nil {
+			log.Printf("[Vision] Invalid upload from %s: %v", deviceID, err)
+			http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := cm.AttachImage(data, mimeType, question); err != nil {
+			if errors.Is(err, conversation.ErrVisionUnavailable) {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"bytes":   len(data),
+		})
+	}
+}
+
+// readUploadedImage 从请求中读取图片数据、类型和附带的问题
+func readUploadedImage(r *http.Request) ([]byte, string, string, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	var data []byte
+	question := r.URL.Query().Get("question")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", "", err
+		}
+		defer file.Close()
+
+		if data, err = io.ReadAll(file); err != nil {
+			return nil, "", "", err
+		}
+		if formQuestion := r.FormValue("question"); formQuestion != "" {
+			question = formQuestion
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	if len(data) == 0 {
+		return nil, "", "", errors.New("empty image")
+	}
+
+	// 以实际内容判断类型，设备上报的 Content-Type 不一定可靠
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", "", errors.New("unsupported content type " + mimeType)
+	}
+	return data, mimeType, question, nil
+}
+
+// findConversationByDevice 查找设备当前的会话
+func findConversationByDevice(deviceID string) *conversation.ConversationManager {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	for _, cm := range activeConnections {
+		if _, id := cm.GetClientInfo(); id == deviceID {
+			return cm
+		}
+	}
+	return nil
+}
@@ -30,8 +30,15 @@ var upgrader = websocket.Upgrader{
 }
 
 // WebSocketHandler 返回处理 WebSocket 连接的 HTTP 处理函数
-func WebSocketHandler(mqttClient *mqtt.Client, llmManager *llm.LLMManager, ttsManager *tts.TTSManager) http.HandlerFunc {
+// 配置了访问令牌时，握手请求必须携带有效令牌
+func WebSocketHandler(mqttClient *mqtt.Client, llmManager *llm.LLMManager, ttsManager *tts.TTSManager, options conversation.Options, auth *Authenticator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !auth.Check(r) {
+			log.Printf("[WebSocket] Rejected connection from %s: invalid access token", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		
 		// 升级 HTTP 连接为 WebSocket
 		ws, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -74,7 +81,7 @@ func WebSocketHandler(mqttClient *mqtt.Client, llmManager *llm.LLMManager, ttsMa
 		log.Printf("[WebSocket] Extracted Device-ID: %s, Client-ID: %s", deviceID, clientID)
 
 		// 创建会话管理器
-		cm := conversation.NewConversationManager(ws, llmManager, ttsManager, deviceID, clientID)
+		cm := conversation.NewConversationManager(ws, llmManager, ttsManager, options, deviceID, clientID)
 		
 		// 保存到活跃连接中
 		connectionsMutex.Lock()
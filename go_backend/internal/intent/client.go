@@ -0,0 +1,204 @@
+package intent
+
+import (
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// 定义意图名称常量
+const (
+	IntentContinueChat = "continue_chat" // 交给主模型继续聊天
+	IntentExit         = "exit"          // 结束对话
+	IntentPlayMusic    = "play_music"    // 播放音乐，参数 song_name
+	IntentChangeVolume = "change_volume" // 调整音量，参数 volume（绝对值）或 delta（相对值）
+	IntentChangeRole   = "change_role"   // 切换角色，参数 role
+	IntentChangeVoice  = "change_voice"  // 调整说话声音，参数 speed/speed_delta、pitch/pitch_delta、gender、voice、reset
+)
+
+// Provider 表示意图识别服务提供商接口
+type Provider interface {
+	// Recognize 识别用户最后一句话的意图，history 为之前的对话（可能为空）
+	Recognize(text string, history []llm.Message) (*Result, error)
+
+	// Initialize 初始化意图识别提供商
+	Initialize() error
+
+	// Cleanup 清理资源
+	Cleanup() error
+}
+
+// Result 表示意图识别结果
+type Result struct {
+	Intent    string                 `json:"intent"`              // 意图名称
+	Arguments map[string]interface{} `json:"arguments,omitempty"` // 意图参数
+	Provider  string                 `json:"provider,omitempty"`  // 给出结果的提供商
+
+	// LLMMetadata 是识别过程中各次大模型请求的响应元数据（含令牌用量），供调用方统计用量
+	LLMMetadata []map[string]interface{} `json:"-"`
+}
+
+// IsPassThrough 判断结果是否应交给主模型处理
+func (r *Result) IsPassThrough() bool {
+	return r == nil || r.Intent == "" || r.Intent == IntentContinueChat
+}
+
+// StringArg 返回字符串类型的参数
+func (r *Result) StringArg(name string) string {
+	if r == nil {
+		return ""
+	}
+	value, _ := r.Arguments[name].(string)
+	return value
+}
+
+// IntArg 返回整数类型的参数，兼容 JSON 解码得到的 float64 和正则捕获的字符串
+func (r *Result) IntArg(name string) (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+	switch value := r.Arguments[name].(type) {
+	case int:
+		return value, true
+	case float64:
+		return int(value), true
+	case string:
+		parsed, err := strconv.Atoi(value)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// FloatArg 返回浮点数类型的参数，兼容整数和正则捕获的字符串
+func (r *Result) FloatArg(name string) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	switch value := r.Arguments[name].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case string:
+		parsed, err := strconv.ParseFloat(value, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// BoolArg 返回布尔类型的参数
+func (r *Result) BoolArg(name string) bool {
+	if r == nil {
+		return false
+	}
+	value, _ := r.Arguments[name].(bool)
+	return value
+}
+
+// ContinueChat 返回交给主模型处理的结果
+func ContinueChat(provider string) *Result {
+	return &Result{Intent: IntentContinueChat, Provider: provider}
+}
+
+// IntentManager 按顺序调用多个意图识别提供商
+// 第一个给出明确意图（非 continue_chat）的提供商结果生效，便宜的提供商应排在前面
+type IntentManager struct {
+	providers   map[string]Provider
+	chain       []string
+	mutex       sync.RWMutex
+	initialized bool
+}
+
+// NewIntentManager 创建一个新的意图识别管理器
+func NewIntentManager() *IntentManager {
+	return &IntentManager{
+		providers: make(map[string]Provider),
+	}
+}
+
+// RegisterProvider 注册一个意图识别提供商，并追加到识别链末尾
+func (im *IntentManager) RegisterProvider(name string, provider Provider) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	if _, exists := im.providers[name]; !exists {
+		im.chain = append(im.chain, name)
+	}
+	im.providers[name] = provider
+
+	log.Printf("[Intent] Registered provider: %s", name)
+}
+
+// Initialize 初始化所有意图识别提供商
+func (im *IntentManager) Initialize() error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	for name, provider := range im.providers {
+		if err := provider.Initialize(); err != nil {
+			log.Printf("[Intent] Failed to initialize provider %s: %v", name, err)
+			return err
+		}
+	}
+
+	im.initialized = true
+	return nil
+}
+
+// Recognize 依次调用识别链上的提供商，出错的提供商会被跳过
+func (im *IntentManager) Recognize(text string, history []llm.Message) (*Result, error) {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
+	if !im.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	// 交给下一个提供商的结果也可能调用过大模型，它们的用量合并到最终结果中
+	var spent []map[string]interface{}
+	for _, name := range im.chain {
+		result, err := im.providers[name].Recognize(text, history)
+		if err != nil {
+			log.Printf("[Intent] Provider %s failed: %v", name, err)
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		spent = append(spent, result.LLMMetadata...)
+		if !result.IsPassThrough() {
+			if result.Provider == "" {
+				result.Provider = name
+			}
+			result.LLMMetadata = spent
+			return result, nil
+		}
+	}
+
+	result := ContinueChat("")
+	result.LLMMetadata = spent
+	return result, nil
+}
+
+// 错误定义
+var (
+	ErrProviderNotFound = NewIntentError("intent provider not found")
+	ErrNotInitialized   = NewIntentError("intent manager not initialized")
+)
+
+// IntentError 表示意图识别中的错误
+type IntentError struct {
+	Message string
+}
+
+// NewIntentError 创建一个新的意图识别错误
+func NewIntentError(message string) *IntentError {
+	return &IntentError{Message: message}
+}
+
+// Error 实现 error 接口
+func (e *IntentError) Error() string {
+	return e.Message
+}
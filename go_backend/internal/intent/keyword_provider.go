@@ -0,0 +1,167 @@
+package intent
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// KeywordRule 定义一条关键词/正则意图规则
+// 正则中的命名分组会作为同名参数加入结果，Arguments 中的固定参数优先级更低
+type KeywordRule struct {
+	Intent    string
+	Pattern   *regexp.Regexp
+	Arguments map[string]interface{}
+}
+
+// KeywordProvider 是基于关键词和正则表达式的意图识别提供商，不依赖任何外部服务
+type KeywordProvider struct {
+	rules       []KeywordRule
+	initialized bool
+}
+
+// NewKeywordProvider 创建一个新的关键词意图识别提供商，rules 为空时使用默认规则
+func NewKeywordProvider(rules []KeywordRule) *KeywordProvider {
+	if len(rules) == 0 {
+		rules = DefaultKeywordRules()
+	}
+	return &KeywordProvider{
+		rules: rules,
+	}
+}
+
+// DefaultKeywordRules 返回内置的中文意图规则
+func DefaultKeywordRules() []KeywordRule {
+	return []KeywordRule{
+		{
+			Intent:  IntentExit,
+			Pattern: regexp.MustCompile(`^(退出|关闭|再见|拜拜|结束对话|我们明天再聊吧?)[。！!.]*$`),
+		},
+		{
+			// 音量和语速规则和其他规则一样要求整句匹配，避免“外面太吵了”这类普通句子触发
+			Intent:  IntentChangeVolume,
+			Pattern: regexp.MustCompile(`^(请|你)?把?(音量|声音)(调到|调成|设为|设置为|设置成)\s*(?P<volume>\d{1,3})[%％]?[。！!.]*$`),
+		},
+		{
+			Intent:    IntentChangeVolume,
+			Pattern:   regexp.MustCompile(`^(请|你)?(大声一?点|声音大一?点|音量调高|调大音量|音量大一?点|太小声了?)吧?[。！!.]*$`),
+			Arguments: map[string]interface{}{"delta": 10},
+		},
+		{
+			Intent:    IntentChangeVolume,
+			Pattern:   regexp.MustCompile(`^(请|你)?(小声一?点|声音小一?点|音量调低|调小音量|音量小一?点|太吵了)吧?[。！!.]*$`),
+			Arguments: map[string]interface{}{"delta": -10},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`^(请|你)?((说|讲)(得|的)?慢一?点|慢点(说|讲)|语速(慢|放慢)一?点|说话太快了?)吧?[。！!.]*$`),
+			Arguments: map[string]interface{}{"speed_delta": -0.2},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`^(请|你)?((说|讲)(得|的)?快一?点|快点(说|讲)|语速(快|加快)一?点|说话太慢了?)吧?[。！!.]*$`),
+			Arguments: map[string]interface{}{"speed_delta": 0.2},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(音调|声调)(高|调高)一?点`),
+			Arguments: map[string]interface{}{"pitch_delta": 0.1},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(音调|声调)(低|调低)一?点`),
+			Arguments: map[string]interface{}{"pitch_delta": -0.1},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(换|换成|换个|换一个|用)(个)?(男声|男生的?声音|男孩子?的?声音)`),
+			Arguments: map[string]interface{}{"gender": "male"},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(换|换成|换个|换一个|用)(个)?(女声|女生的?声音|女孩子?的?声音)`),
+			Arguments: map[string]interface{}{"gender": "female"},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(换|换一)(个|种)(声音|音色)`),
+			Arguments: map[string]interface{}{"voice": "next"},
+		},
+		{
+			Intent:    IntentChangeVoice,
+			Pattern:   regexp.MustCompile(`(恢复|用回)(默认|正常|原来)的?(声音|语速|音色)`),
+			Arguments: map[string]interface{}{"reset": true},
+		},
+		{
+			Intent:  IntentPlayMusic,
+			Pattern: regexp.MustCompile(`^(播放|放|来)(一首|首|点)?(歌曲?|音乐)[。！!.]*$`),
+		},
+		{
+			Intent:  IntentPlayMusic,
+			Pattern: regexp.MustCompile(`^(播放一首|播放|放一首|来一首)《(?P<song_name>[^》]+)》[。！!.]*$`),
+		},
+		{
+			Intent:  IntentPlayMusic,
+			Pattern: regexp.MustCompile(`^(播放一首|播放|放一首|来一首)(?P<song_name>[^，,？?]+?)(这首歌|的歌|歌曲|音乐)[。！!.]*$`),
+		},
+		{
+			// 长的前缀放在前面，避免“切换”先匹配使角色名带上“成”“到”
+			Intent:  IntentChangeRole,
+			Pattern: regexp.MustCompile(`^(切换成|切换到|切换为|切换)(?P<role>[^，,？?]+?)(角色|模式)?[。！!.]*$`),
+		},
+		{
+			// “变成”“换成”常出现在普通句子里，必须以“角色”或“模式”结尾
+			Intent:  IntentChangeRole,
+			Pattern: regexp.MustCompile(`^(变成|换成)(?P<role>[^，,？?]+?)(角色|模式)[。！!.]*$`),
+		},
+	}
+}
+
+// Recognize 按顺序匹配规则，未命中时交给主模型
+func (p *KeywordProvider) Recognize(text string, history []llm.Message) (*Result, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	text = strings.TrimSpace(text)
+	for _, rule := range p.rules {
+		match := rule.Pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		arguments := make(map[string]interface{}, len(rule.Arguments))
+		for key, value := range rule.Arguments {
+			arguments[key] = value
+		}
+		for i, name := range rule.Pattern.SubexpNames() {
+			if name != "" && i < len(match) {
+				arguments[name] = strings.TrimSpace(match[i])
+			}
+		}
+
+		log.Printf("[Intent:Keyword] Matched intent %s for text: %s", rule.Intent, text)
+		return &Result{
+			Intent:    rule.Intent,
+			Arguments: arguments,
+			Provider:  "keyword",
+		}, nil
+	}
+
+	return ContinueChat("keyword"), nil
+}
+
+// Initialize 初始化关键词意图识别提供商
+func (p *KeywordProvider) Initialize() error {
+	log.Printf("[Intent:Keyword] Initializing keyword intent provider with %d rules", len(p.rules))
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理关键词意图识别提供商资源
+func (p *KeywordProvider) Cleanup() error {
+	p.initialized = false
+	return nil
+}
@@ -0,0 +1,57 @@
+package intent
+
+import "testing"
+
+func TestKeywordRules(t *testing.T) {
+	provider := NewKeywordProvider(nil)
+	if err := provider.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text   string
+		intent string
+		arg    string // 期望的参数名和值，为空时不检查
+		value  string
+	}{
+		{"切换成英语老师", IntentChangeRole, "role", "英语老师"},
+		{"切换到英语老师模式", IntentChangeRole, "role", "英语老师"},
+		{"切换温柔姐姐", IntentChangeRole, "role", "温柔姐姐"},
+		{"变成猫娘角色", IntentChangeRole, "role", "猫娘"},
+		{"变成一只猫会怎么样", IntentContinueChat, "", ""},
+		{"换成什么比较好？", IntentContinueChat, "", ""},
+		{"换成男声", IntentChangeVoice, "gender", "male"},
+		{"播放音乐", IntentPlayMusic, "", ""},
+		{"来首歌", IntentPlayMusic, "", ""},
+		{"播放《晴天》", IntentPlayMusic, "song_name", "晴天"},
+		{"播放一首周杰伦的歌", IntentPlayMusic, "song_name", "周杰伦"},
+		{"来一首稻香这首歌", IntentPlayMusic, "song_name", "稻香"},
+		{"播放器怎么打开", IntentContinueChat, "", ""},
+		{"来一首诗", IntentContinueChat, "", ""},
+		{"再见", IntentExit, "", ""},
+		{"把音量调到50", IntentChangeVolume, "volume", "50"},
+		{"你太吵了", IntentChangeVolume, "", ""},
+		{"大声一点吧！", IntentChangeVolume, "", ""},
+		{"外面太吵了", IntentContinueChat, "", ""},
+		{"音量调到多少比较合适", IntentContinueChat, "", ""},
+		{"说慢一点", IntentChangeVoice, "", ""},
+		{"你说话太快了", IntentChangeVoice, "", ""},
+		{"老师让他讲慢一点，他不听", IntentContinueChat, "", ""},
+		{"怎么才能跑得快一点", IntentContinueChat, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result, err := provider.Recognize(tt.text, nil)
+			if err != nil {
+				t.Fatalf("Recognize: %v", err)
+			}
+			if result.Intent != tt.intent {
+				t.Fatalf("intent = %s, want %s (args %v)", result.Intent, tt.intent, result.Arguments)
+			}
+			if tt.arg != "" && result.StringArg(tt.arg) != tt.value {
+				t.Errorf("%s = %q, want %q", tt.arg, result.StringArg(tt.arg), tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package intent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// ChatClient 是意图识别所需的最小对话接口，llm.Provider 和 llm.LLMManager 都满足
+type ChatClient interface {
+	Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error)
+}
+
+// intentPrompt 是意图识别模型的系统提示词
+const intentPrompt = `你是一个意图识别助手。请分析用户的最后一句话，判断用户意图属于以下哪一类，只返回纯JSON，不要任何其他内容。
+
+返回格式示例：
+1. 播放音乐意图: {"function_call": {"name": "play_music", "arguments": {"song_name": "音乐名称"}}}
+2. 调整音量意图: {"function_call": {"name": "change_volume", "arguments": {"volume": 60}}} 或 {"function_call": {"name": "change_volume", "arguments": {"delta": -10}}}
+3. 切换角色意图: {"function_call": {"name": "change_role", "arguments": {"role": "角色名称"}}}
+4. 调整说话声音意图: {"function_call": {"name": "change_voice", "arguments": {"speed_delta": -0.2}}} 或 {"function_call": {"name": "change_voice", "arguments": {"gender": "male"}}}
+5. 结束对话意图: {"function_call": {"name": "exit"}}
+6. 继续聊天意图: {"function_call": {"name": "continue_chat"}}
+
+注意:
+- 播放音乐：无歌名时，song_name设为"random"
+- 调整音量：说出具体数值时用 volume（0-100），说"大声点/小声点"时用 delta
+- 调整说话声音：语速用 speed（0.5-2.0）或 speed_delta，音调用 pitch 或 pitch_delta，换男声/女声用 gender（male、female），换个声音用 voice 设为"next"，恢复默认用 reset 设为 true
+- 如果没有明显的意图，应按照继续聊天意图处理`
+
+// intentCacheEntry 是意图缓存条目
+type intentCacheEntry struct {
+	result    *Result
+	timestamp time.Time
+}
+
+// LLMProvider 使用一个小模型进行意图识别
+type LLMProvider struct {
+	client      ChatClient
+	cache       map[string]intentCacheEntry
+	cacheMutex  sync.Mutex
+	cacheExpiry time.Duration
+	cacheSize   int
+	initialized bool
+}
+
+// NewLLMProvider 创建一个新的 LLM 意图识别提供商
+func NewLLMProvider(client ChatClient) *LLMProvider {
+	return &LLMProvider{
+		client:      client,
+		cache:       make(map[string]intentCacheEntry),
+		cacheExpiry: 10 * time.Minute,
+		cacheSize:   100,
+	}
+}
+
+// Recognize 请求模型返回 function_call 格式的意图，解析失败时交给主模型
+func (p *LLMProvider) Recognize(text string, history []llm.Message) (*Result, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	// 只带上最近一轮对话作为上下文，控制成本
+	var context strings.Builder
+	start := len(history) - 2
+	if start < 0 {
+		start = 0
+	}
+	for _, msg := range history[start:] {
+		if msg.Role == "user" || msg.Role == "assistant" {
+			context.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		}
+	}
+	context.WriteString("user: " + text)
+
+	// 以包含上下文的完整输入作为缓存键，同一句话在不同上下文中可能是不同的意图
+	key := context.String()
+	if cached := p.getCached(key); cached != nil {
+		log.Printf("[Intent:LLM] Using cached intent %s for text: %s", cached.Intent, text)
+		// 缓存命中没有产生用量，返回副本以免调用方修改缓存条目
+		return &Result{Intent: cached.Intent, Arguments: cached.Arguments, Provider: cached.Provider}, nil
+	}
+
+	startTime := time.Now()
+	response, err := p.client.Chat([]llm.Message{
+		{Role: "system", Content: intentPrompt},
+		{Role: "user", Content: key},
+	}, &llm.ChatOptions{
+		Temperature: llm.Float(0),
+		MaxTokens:   llm.Int(100),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("intent llm request failed: %w", err)
+	}
+
+	result, err := parseFunctionCall(response.Content)
+	if err != nil {
+		log.Printf("[Intent:LLM] Unparseable intent response %q: %v", response.Content, err)
+		result = ContinueChat("llm")
+		result.LLMMetadata = []map[string]interface{}{response.Metadata}
+		return result, nil
+	}
+
+	log.Printf("[Intent:LLM] Recognized intent %s in %v for text: %s", result.Intent, time.Since(startTime), text)
+	p.putCached(key, result)
+	return &Result{
+		Intent:      result.Intent,
+		Arguments:   result.Arguments,
+		Provider:    result.Provider,
+		LLMMetadata: []map[string]interface{}{response.Metadata},
+	}, nil
+}
+
+// parseFunctionCall 解析模型返回的 function_call JSON，容忍前后的多余文本和代码块标记
+func parseFunctionCall(content string) (*Result, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no json object found")
+	}
+
+	var payload struct {
+		FunctionCall struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		} `json:"function_call"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &payload); err != nil {
+		return nil, err
+	}
+	if payload.FunctionCall.Name == "" {
+		return nil, fmt.Errorf("missing function name")
+	}
+
+	return &Result{
+		Intent:    payload.FunctionCall.Name,
+		Arguments: payload.FunctionCall.Arguments,
+		Provider:  "llm",
+	}, nil
+}
+
+// getCached 返回未过期的缓存结果
+func (p *LLMProvider) getCached(key string) *Result {
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	entry, exists := p.cache[key]
+	if !exists || time.Since(entry.timestamp) > p.cacheExpiry {
+		return nil
+	}
+	return entry.result
+}
+
+// putCached 写入缓存，超过容量时淘汰最旧的条目
+func (p *LLMProvider) putCached(key string, result *Result) {
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	if len(p.cache) >= p.cacheSize {
+		oldestKey := ""
+		var oldest time.Time
+		for key, entry := range p.cache {
+			if oldestKey == "" || entry.timestamp.Before(oldest) {
+				oldestKey = key
+				oldest = entry.timestamp
+			}
+		}
+		delete(p.cache, oldestKey)
+	}
+	p.cache[key] = intentCacheEntry{result: result, timestamp: time.Now()}
+}
+
+// Initialize 初始化 LLM 意图识别提供商
+func (p *LLMProvider) Initialize() error {
+	if p.client == nil {
+		return fmt.Errorf("intent llm client is required")
+	}
+	log.Printf("[Intent:LLM] Initializing LLM intent provider")
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理 LLM 意图识别提供商资源
+func (p *LLMProvider) Cleanup() error {
+	p.initialized = false
+	return nil
+}
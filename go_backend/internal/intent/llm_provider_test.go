@@ -0,0 +1,89 @@
+package intent
+
+import (
+	"testing"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// sequenceClient 依次返回预设的回复，并记录调用次数
+type sequenceClient struct {
+	replies []string
+	calls   int
+}
+
+func (c *sequenceClient) Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error) {
+	reply := c.replies[c.calls%len(c.replies)]
+	c.calls++
+	return &llm.Response{Content: reply}, nil
+}
+
+func TestLLMProviderCacheUsesHistory(t *testing.T) {
+	client := &sequenceClient{replies: []string{
+		`{"function_call": {"name": "play_music", "arguments": {"song_name": "晴天"}}}`,
+		`{"function_call": {"name": "continue_chat"}}`,
+	}}
+	provider := NewLLMProvider(client)
+	if err := provider.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	music := []llm.Message{{Role: "assistant", Content: "要听晴天吗？"}}
+	chat := []llm.Message{{Role: "assistant", Content: "今天过得怎么样？"}}
+
+	first, _ := provider.Recognize("好的", music)
+	second, _ := provider.Recognize("好的", chat)
+	if first.Intent != IntentPlayMusic || second.Intent != IntentContinueChat || client.calls != 2 {
+		t.Fatalf("intents = %s, %s after %d calls; a different context must not reuse the cache",
+			first.Intent, second.Intent, client.calls)
+	}
+
+	// 上下文相同的重复输入命中缓存
+	again, _ := provider.Recognize("好的", music)
+	if again.Intent != IntentPlayMusic || client.calls != 2 {
+		t.Errorf("repeat = %s after %d calls, want a cache hit", again.Intent, client.calls)
+	}
+}
+
+func TestIntentManagerCarriesUsage(t *testing.T) {
+	client := &sequenceClient{replies: []string{`{"function_call": {"name": "continue_chat"}}`}}
+	manager := NewIntentManager()
+	manager.RegisterProvider("keyword", NewKeywordProvider(nil))
+	manager.RegisterProvider("llm", NewLLMProvider(&usageClient{client}))
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 交给主模型的结果同样带上意图模型的用量
+	result, err := manager.Recognize("今天吃什么", nil)
+	if err != nil || !result.IsPassThrough() {
+		t.Fatalf("result = %+v, err %v", result, err)
+	}
+	if len(result.LLMMetadata) != 1 {
+		t.Fatalf("metadata = %v, want one llm call", result.LLMMetadata)
+	}
+	if usage, ok := llm.UsageFromMetadata(result.LLMMetadata[0]); !ok || usage.TotalTokens != 12 {
+		t.Errorf("usage = %+v, %v", usage, ok)
+	}
+
+	// 缓存命中不再计入用量
+	result, _ = manager.Recognize("今天吃什么", nil)
+	if len(result.LLMMetadata) != 0 {
+		t.Errorf("cached result carries usage %v", result.LLMMetadata)
+	}
+}
+
+// usageClient 给回复加上固定的用量元数据
+type usageClient struct {
+	client ChatClient
+}
+
+func (c *usageClient) Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error) {
+	response, err := c.client.Chat(messages, options)
+	if err == nil {
+		response.Metadata = map[string]interface{}{
+			llm.MetadataUsage: llm.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		}
+	}
+	return response, err
+}
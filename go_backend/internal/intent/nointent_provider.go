@@ -0,0 +1,28 @@
+package intent
+
+import (
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// NoIntentProvider 不做意图识别，所有输入都交给主模型
+type NoIntentProvider struct{}
+
+// NewNoIntentProvider 创建一个新的空意图识别提供商
+func NewNoIntentProvider() *NoIntentProvider {
+	return &NoIntentProvider{}
+}
+
+// Recognize 总是返回继续聊天
+func (p *NoIntentProvider) Recognize(text string, history []llm.Message) (*Result, error) {
+	return ContinueChat("nointent"), nil
+}
+
+// Initialize 初始化空意图识别提供商
+func (p *NoIntentProvider) Initialize() error {
+	return nil
+}
+
+// Cleanup 清理空意图识别提供商资源
+func (p *NoIntentProvider) Cleanup() error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package knowledge
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 检索默认值
+const (
+	defaultTopK = 3
+	// candidateFactor 融合前每路检索多取的候选数倍数
+	candidateFactor = 4
+)
+
+// Base 是一个可检索的知识库
+type Base struct {
+	dir        string
+	chunkRunes int
+	embedder   Embedder
+
+	mutex   sync.RWMutex
+	chunks  []Chunk
+	index   *BM25Index
+	vectors [][]float32 // 与 chunks 一一对应，未配置向量化服务时为空
+}
+
+// NewBase 创建一个知识库，embedder 为 nil 时只使用 BM25 检索
+func NewBase(dir string, chunkRunes int, embedder Embedder) *Base {
+	return &Base{
+		dir:        dir,
+		chunkRunes: chunkRunes,
+		embedder:   embedder,
+		index:      NewBM25Index(nil),
+	}
+}
+
+// Load 读取知识库目录并重建索引，可在运行时重复调用以重新加载
+// 向量化失败时退化为只用 BM25 检索
+func (b *Base) Load() error {
+	chunks, err := LoadDir(b.dir, b.chunkRunes)
+	if err != nil {
+		return fmt.Errorf("error loading knowledge dir %s: %w", b.dir, err)
+	}
+
+	index := NewBM25Index(chunks)
+
+	var vectors [][]float32
+	if b.embedder != nil && len(chunks) > 0 {
+		texts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			texts[i] = chunk.Heading + "\n" + chunk.Text
+		}
+		if vectors, err = b.embedder.Embed(texts); err != nil {
+			log.Printf("[Knowledge] Embedding failed, using BM25 only: %v", err)
+			vectors = nil
+		}
+	}
+
+	b.mutex.Lock()
+	b.chunks = chunks
+	b.index = index
+	b.vectors = vectors
+	b.mutex.Unlock()
+
+	log.Printf("[Knowledge] Loaded %d chunks from %s (embeddings: %v)", len(chunks), b.dir, vectors != nil)
+	return nil
+}
+
+// Len 返回知识库中的片段数
+func (b *Base) Len() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return len(b.chunks)
+}
+
+// Retrieve 检索与查询最相关的 k 个片段
+// 配置了向量化服务时，BM25 和向量检索的结果按排名融合
+func (b *Base) Retrieve(query string, k int) []Hit {
+	if k <= 0 {
+		k = defaultTopK
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+
+	b.mutex.RLock()
+	chunks, index, vectors := b.chunks, b.index, b.vectors
+	b.mutex.RUnlock()
+
+	if vectors == nil {
+		return index.Search(query, k)
+	}
+
+	lexical := index.Search(query, k*candidateFactor)
+	semantic, err := b.searchVectors(query, chunks, vectors, k*candidateFactor)
+	if err != nil {
+		log.Printf("[Knowledge] Query embedding failed, using BM25 only: %v", err)
+		if len(lexical) > k {
+			lexical = lexical[:k]
+		}
+		return lexical
+	}
+	return fuse(k, lexical, semantic)
+}
+
+// searchVectors 按余弦相似度检索片段
+func (b *Base) searchVectors(query string, chunks []Chunk, vectors [][]float32, k int) ([]Hit, error) {
+	embedded, err := b.embedder.Embed([]string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != 1 {
+		return nil, fmt.Errorf("expected 1 query vector, got %d", len(embedded))
+	}
+
+	hits := make([]Hit, 0, len(chunks))
+	for i, vector := range vectors {
+		if score := cosine(embedded[0], vector); score > 0 {
+			hits = append(hits, Hit{Chunk: chunks[i], Score: score})
+		}
+	}
+	sort.Slice(hits, func(a, c int) bool {
+		return hits[a].Score > hits[c].Score
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+// FormatContext 把检索结果整理为附加到提示词中的参考资料，每段带 [n] 编号
+func FormatContext(hits []Hit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("以下是与用户问题相关的参考资料，回答时优先依据这些资料；资料中没有的内容不要编造，资料与问题无关时忽略即可：\n")
+	for i, hit := range hits {
+		fmt.Fprintf(&builder, "\n[%d] 出处：%s\n%s\n", i+1, hit.Chunk.Citation(), hit.Chunk.Text)
+	}
+	return builder.String()
+}
+
+// Citations 返回检索结果的出处列表，用于日志记录
+func Citations(hits []Hit) []string {
+	citations := make([]string, 0, len(hits))
+	for i, hit := range hits {
+		citations = append(citations, fmt.Sprintf("[%d] %s (%.3f)", i+1, hit.Chunk.Citation(), hit.Score))
+	}
+	return citations
+}
@@ -0,0 +1,148 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 参数，取常用的默认值
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit 表示一个检索结果
+type Hit struct {
+	Chunk Chunk   `json:"chunk"`
+	Score float64 `json:"score"`
+}
+
+// BM25Index 是一个内存中的 BM25 倒排索引
+type BM25Index struct {
+	chunks    []Chunk
+	postings  map[string]map[int]int // 词 -> 片段序号 -> 词频
+	lengths   []int                  // 每个片段的词数
+	avgLength float64
+}
+
+// NewBM25Index 为一组片段建立索引
+func NewBM25Index(chunks []Chunk) *BM25Index {
+	index := &BM25Index{
+		chunks:   chunks,
+		postings: make(map[string]map[int]int),
+		lengths:  make([]int, len(chunks)),
+	}
+
+	total := 0
+	for i, chunk := range chunks {
+		// 标题也参与检索，用户常用标题里的词提问
+		tokens := Tokenize(chunk.Heading + "\n" + chunk.Text)
+		index.lengths[i] = len(tokens)
+		total += len(tokens)
+		for _, token := range tokens {
+			if index.postings[token] == nil {
+				index.postings[token] = make(map[int]int)
+			}
+			index.postings[token][i]++
+		}
+	}
+	if len(chunks) > 0 {
+		index.avgLength = float64(total) / float64(len(chunks))
+	}
+	return index
+}
+
+// Len 返回索引中的片段数
+func (idx *BM25Index) Len() int {
+	return len(idx.chunks)
+}
+
+// Search 返回与查询最相关的 k 个片段，按得分从高到低排列
+func (idx *BM25Index) Search(query string, k int) []Hit {
+	if len(idx.chunks) == 0 || k <= 0 {
+		return nil
+	}
+
+	scores := make(map[int]float64)
+	n := float64(len(idx.chunks))
+	seen := make(map[string]bool)
+	for _, token := range Tokenize(query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+
+		postings := idx.postings[token]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i, tf := range postings {
+			freq := float64(tf)
+			norm := 1 - bm25B + bm25B*float64(idx.lengths[i])/idx.avgLength
+			scores[i] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for i, score := range scores {
+		hits = append(hits, Hit{Chunk: idx.chunks[i], Score: score})
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].Chunk.ID < hits[b].Chunk.ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// Tokenize 把文本切分为检索用的词
+// 中文没有空格分词，使用相邻两个汉字组成的二元组（单字的句子保留单字）；英文和数字按单词切分并转小写
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
@@ -0,0 +1,177 @@
+// Package knowledge 提供基于本地文档的知识库检索
+//
+// Markdown 和文本文件按标题和段落切分为片段，使用纯 Go 实现的 BM25 建立索引，
+// 可选地结合向量检索。每轮对话检索出的片段会附加到提示词中，并带上出处编号。
+package knowledge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultChunkRunes 是单个片段的目标长度
+const defaultChunkRunes = 400
+
+// supportedExtensions 是可以导入知识库的文件类型
+var supportedExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+// Chunk 表示知识库中的一个片段
+type Chunk struct {
+	ID      int    `json:"id"`
+	Source  string `json:"source"`  // 相对于知识库目录的文件路径
+	Heading string `json:"heading"` // 片段所在的标题路径，如 "安装 > 联网"
+	Text    string `json:"text"`
+}
+
+// Citation 返回片段的出处描述
+func (c Chunk) Citation() string {
+	if c.Heading == "" {
+		return c.Source
+	}
+	return c.Source + "#" + c.Heading
+}
+
+var headingRe = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// LoadDir 读取目录下所有支持的文件并切分为片段
+func LoadDir(dir string, chunkRunes int) ([]Chunk, error) {
+	var chunks []Chunk
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !supportedExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		source, _ := filepath.Rel(dir, path)
+		chunks = append(chunks, SplitDocument(filepath.ToSlash(source), string(content), chunkRunes)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range chunks {
+		chunks[i].ID = i
+	}
+	return chunks, nil
+}
+
+// SplitDocument 按 Markdown 标题和段落切分文档
+// 每个片段不超过 chunkRunes 个字符（单个超长段落除外），并记录所在的标题路径
+func SplitDocument(source, content string, chunkRunes int) []Chunk {
+	if chunkRunes <= 0 {
+		chunkRunes = defaultChunkRunes
+	}
+
+	var (
+		chunks   []Chunk
+		headings []string
+		current  strings.Builder
+	)
+
+	flush := func() {
+		text := strings.TrimSpace(current.String())
+		current.Reset()
+		if text == "" {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			Source:  source,
+			Heading: strings.Join(headings, " > "),
+			Text:    text,
+		})
+	}
+
+	for _, paragraph := range splitParagraphs(content) {
+		// 代码块整体是一个段落，首行是 ``` 而不是标题
+		firstLine := strings.SplitN(paragraph, "\n", 2)[0]
+		if match := headingRe.FindStringSubmatch(firstLine); match != nil {
+			// 新标题开始一个新片段，并更新标题路径
+			flush()
+			level := len(match[1])
+			if level <= len(headings) {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, match[2])
+
+			rest := strings.TrimSpace(strings.TrimPrefix(paragraph, firstLine))
+			if rest == "" {
+				continue
+			}
+			paragraph = rest
+		}
+
+		if current.Len() > 0 && len([]rune(current.String()))+len([]rune(paragraph)) > chunkRunes {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+
+	// 去掉占位用的空标题
+	for i := range chunks {
+		parts := strings.Split(chunks[i].Heading, " > ")
+		kept := parts[:0]
+		for _, part := range parts {
+			if part != "" {
+				kept = append(kept, part)
+			}
+		}
+		chunks[i].Heading = strings.Join(kept, " > ")
+	}
+	return chunks
+}
+
+// splitParagraphs 按空行切分段落，代码块内的空行不切分
+func splitParagraphs(content string) []string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var (
+		paragraphs []string
+		current    []string
+		inFence    bool
+	)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		// 标题单独成段，方便识别
+		if !inFence && headingRe.MatchString(line) && len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, "\n"))
+			current = nil
+		}
+		if trimmed == "" && !inFence {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+	return paragraphs
+}
@@ -0,0 +1,169 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Embedder 表示文本向量化服务接口，用于在 BM25 之外补充语义检索
+type Embedder interface {
+	// Embed 把一组文本转换为向量，返回的向量与输入一一对应
+	Embed(texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbedder 调用 OpenAI 兼容的 /embeddings 接口
+type OpenAIEmbedder struct {
+	apiKey     string
+	endpoint   string
+	model      string
+	batchSize  int
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbedder 创建一个新的向量化服务客户端
+func NewOpenAIEmbedder(apiKey, endpoint, model string) *OpenAIEmbedder {
+	if endpoint == "" {
+		endpoint = "https://api.openai.com/v1/embeddings"
+	}
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+	return &OpenAIEmbedder{
+		apiKey:    apiKey,
+		endpoint:  endpoint,
+		model:     model,
+		batchSize: 64,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// embeddingRequest 表示向量化请求体
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse 表示向量化响应体
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed 分批请求向量，避免单次请求过大
+func (e *OpenAIEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := e.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch 请求一批文本的向量
+func (e *OpenAIEmbedder) embedBatch(texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling embedding request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("embedding API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding embedding response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding API returned %d vectors for %d inputs", len(result.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding API returned out of range index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// cosine 计算两个向量的余弦相似度
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// rrfK 是倒数排名融合的平滑常数
+const rrfK = 60
+
+// fuse 用倒数排名融合合并多路检索结果，只依赖排名，不需要对齐不同检索方式的分数
+func fuse(k int, rankings ...[]Hit) []Hit {
+	scores := make(map[int]float64)
+	chunks := make(map[int]Chunk)
+	for _, ranking := range rankings {
+		for rank, hit := range ranking {
+			scores[hit.Chunk.ID] += 1 / float64(rrfK+rank+1)
+			chunks[hit.Chunk.ID] = hit.Chunk
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Chunk: chunks[id], Score: score})
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].Chunk.ID < hits[b].Chunk.ID
+	})
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
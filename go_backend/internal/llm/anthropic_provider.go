@@ -0,0 +1,728 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Anthropic Messages API 的常量
+const (
+	anthropicDefaultEndpoint    = "https://api.anthropic.com/v1/messages"
+	anthropicDefaultModel       = "claude-3-5-haiku-latest"
+	anthropicAPIVersion         = "2023-06-01"
+	anthropicDefaultMaxTokens   = 2000 // 该接口要求必须填写 max_tokens
+	anthropicMaxOutputTokens    = 8192
+	anthropicMaxTemperature     = 1.0
+	anthropicDefaultTemperature = 0.7
+)
+
+// AnthropicProvider 实现 Anthropic Messages API
+type AnthropicProvider struct {
+	apiKey      string
+	apiEndpoint string
+	model       string
+	httpClient  *http.Client
+	initialized bool
+
+	// 重试与熔断
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	retryDeadline  time.Duration // 包括重试在内的总时长上限，超过后不再发起新的尝试
+	breaker        *CircuitBreaker
+}
+
+// AnthropicRequestBody 表示发送到 Messages API 的请求体
+// 与 OpenAI 兼容接口不同，系统提示是顶层的 system 字段，不在 messages 中
+type AnthropicRequestBody struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolMode `json:"tool_choice,omitempty"`
+	Metadata      *AnthropicMetadata `json:"metadata,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+// AnthropicMessage 表示一条消息，内容总是以内容块数组发送
+type AnthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// AnthropicContentBlock 表示一个内容块（text、image 或 tool_use）
+type AnthropicContentBlock struct {
+	Type     string                `json:"type"`
+	Text     string                `json:"text,omitempty"`
+	Thinking string                `json:"thinking,omitempty"`
+	Source   *AnthropicImageSource `json:"source,omitempty"`
+	ID       string                `json:"id,omitempty"`
+	Name     string                `json:"name,omitempty"`
+	Input    json.RawMessage       `json:"input,omitempty"`
+}
+
+// AnthropicImageSource 表示图片内容块的来源
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // base64 或 url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicTool 表示工具定义，参数模式放在 input_schema 中
+type AnthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+// AnthropicToolMode 表示工具选择方式：auto、any、tool 或 none
+type AnthropicToolMode struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// AnthropicMetadata 表示请求元数据
+type AnthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// AnthropicResponse 表示非流式响应
+type AnthropicResponse struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Role       string                  `json:"role"`
+	Model      string                  `json:"model"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicUsage 表示用量统计，流式响应中输入和输出分别在不同事件中给出
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// toUsage 转换为通用的用量格式
+func (u AnthropicUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// AnthropicStreamEvent 表示 SSE 流中的一个事件
+// 不同事件类型只使用其中一部分字段
+type AnthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *AnthropicResponse     `json:"message,omitempty"`       // message_start
+	ContentBlock *AnthropicContentBlock `json:"content_block,omitempty"` // content_block_start
+	Delta        *AnthropicStreamDelta  `json:"delta,omitempty"`         // content_block_delta、message_delta
+	Usage        *AnthropicUsage        `json:"usage,omitempty"`         // message_delta
+	Error        *AnthropicErrorDetail  `json:"error,omitempty"`         // error
+}
+
+// AnthropicStreamDelta 表示增量内容
+type AnthropicStreamDelta struct {
+	Type        string `json:"type"` // text_delta、input_json_delta、thinking_delta
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"` // message_delta
+}
+
+// AnthropicErrorDetail 表示流中的错误事件
+type AnthropicErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// NewAnthropicProvider 创建一个新的 Anthropic 提供商
+func NewAnthropicProvider(apiKey string, model string) *AnthropicProvider {
+	if model == "" {
+		model = anthropicDefaultModel
+	}
+
+	return &AnthropicProvider{
+		apiKey:      apiKey,
+		apiEndpoint: anthropicDefaultEndpoint,
+		model:       model,
+		httpClient: &http.Client{
+			Timeout: 90 * time.Second,
+		},
+		maxRetries:     2,
+		retryBaseDelay: 500 * time.Millisecond,
+		retryMaxDelay:  8 * time.Second,
+		retryDeadline:  15 * time.Second,
+		breaker:        NewCircuitBreaker("Anthropic", 5, 30*time.Second),
+	}
+}
+
+// SetEndpoint 设置 Messages API 地址，用于代理或兼容服务
+func (p *AnthropicProvider) SetEndpoint(endpoint string) {
+	if endpoint != "" {
+		p.apiEndpoint = endpoint
+	}
+}
+
+// SetRetryDeadline 设置包括重试在内的总时长上限，等待下一次重试会超过上限时直接返回错误，
+// 把剩余的时间留给 LLMManager 切换到备用提供商
+func (p *AnthropicProvider) SetRetryDeadline(deadline time.Duration) {
+	if deadline > 0 {
+		p.retryDeadline = deadline
+	}
+}
+
+// SetCircuitBreaker 设置熔断阈值：连续 failureThreshold 次请求最终失败后打开熔断，
+// openDuration 内直接返回 ErrCircuitOpen，之后放行一个试探请求
+func (p *AnthropicProvider) SetCircuitBreaker(failureThreshold int, openDuration time.Duration) {
+	p.breaker = NewCircuitBreaker("Anthropic", failureThreshold, openDuration)
+}
+
+// SetRetryPolicy 设置重试次数和退避延迟
+func (p *AnthropicProvider) SetRetryPolicy(maxRetries int, baseDelay, maxDelay time.Duration) {
+	if maxRetries >= 0 {
+		p.maxRetries = maxRetries
+	}
+	if baseDelay > 0 {
+		p.retryBaseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		p.retryMaxDelay = maxDelay
+	}
+}
+
+// Chat 实现非流式对话
+func (p *AnthropicProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
+	if err := p.checkRequest(messages, options); err != nil {
+		return nil, err
+	}
+
+	body, err := p.newRequestBody(messages, options, false)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[LLM:Anthropic] Sending Chat request with %d messages", len(messages))
+	resp, err := p.sendRequest(body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var anthropicResp AnthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	result := &Response{
+		FinishReason: anthropicFinishReason(anthropicResp.StopReason),
+		Metadata: map[string]interface{}{
+			"model":       anthropicResp.Model,
+			"id":          anthropicResp.ID,
+			MetadataUsage: anthropicResp.Usage.toUsage(),
+		},
+	}
+
+	var content, thinking strings.Builder
+	for _, block := range anthropicResp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, anthropicToolCall(block.ID, block.Name, string(block.Input)))
+		}
+	}
+	result.Content = content.String()
+	if thinking.Len() > 0 {
+		result.Metadata[MetadataReasoningContent] = thinking.String()
+	}
+
+	return result, nil
+}
+
+// StreamChat 实现流式对话
+// message_start 给出输入用量，content_block_delta 逐段给出文本和工具参数，
+// message_delta 给出结束原因和输出用量，message_stop 时交付最终块
+func (p *AnthropicProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
+	if err := p.checkRequest(messages, options); err != nil {
+		return err
+	}
+
+	body, err := p.newRequestBody(messages, options, true)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[LLM:Anthropic] Sending StreamChat request with %d messages", len(messages))
+	resp, err := p.sendRequest(body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var (
+		usage        AnthropicUsage
+		stopReason   string
+		toolCalls    []ToolCall
+		toolIndex    = make(map[int]int)              // 内容块序号 -> toolCalls 下标
+		toolInput    = make(map[int]*strings.Builder) // 内容块序号 -> 拼接中的参数
+		messageModel string
+	)
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("error reading stream: %w", err)
+		}
+
+		// 事件类型同时出现在 event 行和 data 的 type 字段中，这里只解析 data
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("error parsing stream data: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+				messageModel = event.Message.Model
+			}
+
+		case "content_block_start":
+			if block := event.ContentBlock; block != nil && block.Type == "tool_use" {
+				toolIndex[event.Index] = len(toolCalls)
+				toolInput[event.Index] = &strings.Builder{}
+				toolCalls = append(toolCalls, anthropicToolCall(block.ID, block.Name, ""))
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				if event.Delta.Text == "" {
+					continue
+				}
+				if err := callback(&ResponseChunk{Content: event.Delta.Text}); err != nil {
+					return fmt.Errorf("error in callback: %w", err)
+				}
+			case "thinking_delta":
+				if err := callback(&ResponseChunk{
+					Metadata: map[string]interface{}{MetadataReasoningContent: event.Delta.Thinking},
+				}); err != nil {
+					return fmt.Errorf("error in callback: %w", err)
+				}
+			case "input_json_delta":
+				if input, ok := toolInput[event.Index]; ok {
+					input.WriteString(event.Delta.PartialJSON)
+				}
+			}
+
+		case "content_block_stop":
+			if i, ok := toolIndex[event.Index]; ok {
+				if arguments := toolInput[event.Index].String(); arguments != "" {
+					toolCalls[i].Function.Arguments = arguments
+				}
+			}
+
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+
+		case "message_stop":
+			return p.deliverFinal(callback, stopReason, toolCalls, usage, messageModel)
+
+		case "error":
+			if event.Error != nil {
+				return anthropicStreamError(event.Error)
+			}
+			return fmt.Errorf("anthropic stream error: %s", data)
+		}
+	}
+
+	// 连接提前结束，仍然交付最终块，已经送达的内容不丢弃
+	log.Printf("[LLM:Anthropic] Stream ended without message_stop")
+	return p.deliverFinal(callback, stopReason, toolCalls, usage, messageModel)
+}
+
+// deliverFinal 交付带结束原因、工具调用和用量的最终块
+func (p *AnthropicProvider) deliverFinal(callback StreamCallback, stopReason string, toolCalls []ToolCall, usage AnthropicUsage, model string) error {
+	finalChunk := &ResponseChunk{
+		IsFinal:      true,
+		FinishReason: anthropicFinishReason(stopReason),
+		ToolCalls:    toolCalls,
+		Metadata: map[string]interface{}{
+			MetadataUsage: usage.toUsage(),
+		},
+	}
+	if model != "" {
+		finalChunk.Metadata["model"] = model
+	}
+
+	if err := callback(finalChunk); err != nil {
+		return fmt.Errorf("error in callback (final): %w", err)
+	}
+	return nil
+}
+
+// checkRequest 校验请求参数和消息
+func (p *AnthropicProvider) checkRequest(messages []Message, options *ChatOptions) error {
+	if !p.initialized {
+		return ErrNotInitialized
+	}
+	if err := options.Check("anthropic", p.Capabilities()); err != nil {
+		return err
+	}
+	if options != nil && options.Temperature != nil && *options.Temperature > anthropicMaxTemperature {
+		return &UnsupportedOptionsError{Provider: "anthropic", Options: []string{fmt.Sprintf("temperature above %.0f", anthropicMaxTemperature)}}
+	}
+	return CheckMessages("anthropic", messages, p.Capabilities())
+}
+
+// newRequestBody 根据消息和选项构造请求体
+// 系统消息合并到顶层 system 字段，相邻的同角色消息合并为一条
+func (p *AnthropicProvider) newRequestBody(messages []Message, options *ChatOptions, stream bool) (*AnthropicRequestBody, error) {
+	body := &AnthropicRequestBody{
+		Model:       p.model,
+		MaxTokens:   anthropicDefaultMaxTokens,
+		Temperature: Float(anthropicDefaultTemperature),
+		Stream:      stream,
+	}
+
+	var system []string
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+
+		blocks, err := anthropicContentBlocks(msg)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		role := msg.Role
+		if role != "assistant" {
+			role = "user"
+		}
+		if last := len(body.Messages) - 1; last >= 0 && body.Messages[last].Role == role {
+			body.Messages[last].Content = append(body.Messages[last].Content, blocks...)
+			continue
+		}
+		body.Messages = append(body.Messages, AnthropicMessage{Role: role, Content: blocks})
+	}
+	body.System = strings.Join(system, "\n\n")
+
+	if options == nil {
+		return body, nil
+	}
+
+	if options.Temperature != nil {
+		body.Temperature = options.Temperature
+	}
+	if options.MaxTokens != nil {
+		body.MaxTokens = *options.MaxTokens
+	}
+	body.TopP = options.TopP
+	body.StopSequences = options.Stop
+	if options.User != "" {
+		body.Metadata = &AnthropicMetadata{UserID: options.User}
+	}
+
+	for _, tool := range options.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		body.Tools = append(body.Tools, AnthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	// auto、none 同名；required 对应 any；其余为工具名称
+	switch options.ToolChoice {
+	case "":
+	case "auto", "none":
+		body.ToolChoice = &AnthropicToolMode{Type: options.ToolChoice}
+	case "required":
+		body.ToolChoice = &AnthropicToolMode{Type: "any"}
+	default:
+		body.ToolChoice = &AnthropicToolMode{Type: "tool", Name: options.ToolChoice}
+	}
+
+	return body, nil
+}
+
+// anthropicContentBlocks 把通用消息转换为内容块，图片的 data URL 转换为 base64 来源
+func anthropicContentBlocks(msg Message) ([]AnthropicContentBlock, error) {
+	if len(msg.Parts) == 0 {
+		if msg.Content == "" {
+			return nil, nil
+		}
+		return []AnthropicContentBlock{{Type: "text", Text: msg.Content}}, nil
+	}
+
+	blocks := make([]AnthropicContentBlock, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case ContentPartText:
+			if part.Text != "" {
+				blocks = append(blocks, AnthropicContentBlock{Type: "text", Text: part.Text})
+			}
+		case ContentPartImage:
+			if part.ImageURL == nil {
+				continue
+			}
+			source, err := anthropicImageSource(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, AnthropicContentBlock{Type: "image", Source: source})
+		}
+	}
+	return blocks, nil
+}
+
+// anthropicImageSource 解析图片地址，data URL 转换为 base64 来源，其余作为 url 来源
+func anthropicImageSource(url string) (*AnthropicImageSource, error) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return &AnthropicImageSource{Type: "url", URL: url}, nil
+	}
+
+	header, data, ok := strings.Cut(rest, ",")
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !ok || !isBase64 || mediaType == "" {
+		return nil, fmt.Errorf("unsupported image data url: %s", truncateString(url, 40))
+	}
+	return &AnthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// anthropicToolCall 把 tool_use 内容块转换为通用的工具调用
+func anthropicToolCall(id, name, input string) ToolCall {
+	if input == "" {
+		input = "{}"
+	}
+	return ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: ToolCallFunction{
+			Name:      name,
+			Arguments: input,
+		},
+	}
+}
+
+// anthropicFinishReason 把 stop_reason 映射为 OpenAI 兼容的结束原因
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// anthropicStreamError 把流中的错误事件转换为带类别的 API 错误
+func anthropicStreamError(detail *AnthropicErrorDetail) error {
+	apiErr := &APIError{
+		Provider: "anthropic",
+		Message:  detail.Message,
+		Body:     detail.Type + ": " + detail.Message,
+	}
+	switch detail.Type {
+	case "overloaded_error", "api_error":
+		apiErr.Kind = ErrorKindServer
+	case "rate_limit_error":
+		apiErr.Kind = ErrorKindRateLimit
+	case "authentication_error", "permission_error":
+		apiErr.Kind = ErrorKindAuth
+	case "invalid_request_error":
+		apiErr.Kind = ErrorKindBadRequest
+	default:
+		apiErr.Kind = ErrorKindUnknown
+	}
+	return apiErr
+}
+
+// sendRequest 发送请求并返回状态为 200 的响应，可重试的错误按指数退避重试，总时长不超过 retryDeadline
+func (p *AnthropicProvider) sendRequest(body *AnthropicRequestBody) (*http.Response, error) {
+	if err := p.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		resp, err := p.doRequest(jsonData, body.Stream)
+		if err == nil {
+			p.breaker.RecordSuccess()
+			return resp, nil
+		}
+
+		if !IsRetryableError(err) {
+			p.breaker.RecordSuccess()
+			return nil, err
+		}
+
+		delay := p.backoffDelay(attempt, err)
+		if attempt >= p.maxRetries || time.Since(start)+delay > p.retryDeadline {
+			p.breaker.RecordFailure()
+			return nil, err
+		}
+
+		log.Printf("[LLM:Anthropic] Request failed (attempt %d/%d), retrying in %v: %v", attempt+1, p.maxRetries+1, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// doRequest 执行一次 HTTP 请求
+func (p *AnthropicProvider) doRequest(jsonData []byte, stream bool) (*http.Response, error) {
+	req, err := http.NewRequest("POST", p.apiEndpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, newAPIError("anthropic", resp, bodyBytes)
+	}
+
+	return resp, nil
+}
+
+// backoffDelay 计算第 attempt 次重试前的等待时间，优先使用服务端的 Retry-After
+func (p *AnthropicProvider) backoffDelay(attempt int, err error) time.Duration {
+	if apiErr, ok := err.(*APIError); ok && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > p.retryMaxDelay {
+			return p.retryMaxDelay
+		}
+		return apiErr.RetryAfter
+	}
+
+	delay := p.retryBaseDelay << uint(attempt)
+	if delay > p.retryMaxDelay || delay <= 0 {
+		delay = p.retryMaxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
+// Initialize 初始化 Anthropic 提供商
+func (p *AnthropicProvider) Initialize() error {
+	if p.apiKey == "" {
+		return fmt.Errorf("Anthropic API key is required")
+	}
+
+	log.Printf("[LLM:Anthropic] Initializing Anthropic provider with model: %s", p.model)
+	p.initialized = true
+	return nil
+}
+
+// HealthCheck 通过模型列表接口探测服务是否可用，不消耗令牌
+func (p *AnthropicProvider) HealthCheck() error {
+	modelsEndpoint := strings.TrimSuffix(p.apiEndpoint, "/messages") + "/models"
+
+	req, err := http.NewRequest("GET", modelsEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return newAPIError("anthropic", resp, bodyBytes)
+	}
+
+	return nil
+}
+
+// Cleanup 清理 Anthropic 提供商资源
+func (p *AnthropicProvider) Cleanup() error {
+	log.Printf("[LLM:Anthropic] Cleaning up Anthropic provider")
+	p.initialized = false
+	return nil
+}
+
+// Capabilities 返回 Messages API 支持的请求参数
+// 该接口没有频率和存在惩罚，也没有 JSON 输出模式
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Temperature:     true,
+		TopP:            true,
+		MaxTokens:       true,
+		Stop:            true,
+		Tools:           true,
+		User:            true,
+		Vision:          true,
+		ResponseFormats: []ResponseFormat{ResponseFormatText},
+		MaxOutputTokens: anthropicMaxOutputTokens,
+	}
+}
@@ -0,0 +1,291 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAnthropic 启动一个本地 Messages API，handler 按请求次数返回响应
+func fakeAnthropic(t *testing.T, handler func(w http.ResponseWriter, body AnthropicRequestBody, attempt int)) (*AnthropicProvider, *int32) {
+	t.Helper()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(atomic.AddInt32(&calls, 1))
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Errorf("x-api-key = %q, want test-key", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicAPIVersion {
+			t.Errorf("anthropic-version = %q, want %s", got, anthropicAPIVersion)
+		}
+
+		var body AnthropicRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+		handler(w, body, attempt)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewAnthropicProvider("test-key", "test-model")
+	provider.SetEndpoint(server.URL)
+	provider.SetRetryPolicy(1, time.Millisecond, time.Millisecond)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return provider, &calls
+}
+
+// writeSSE 按 Messages API 的格式写出 SSE 事件
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal([]byte(event), &typed)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+	}
+}
+
+func TestAnthropicStreamChat(t *testing.T) {
+	provider, _ := fakeAnthropic(t, func(w http.ResponseWriter, body AnthropicRequestBody, _ int) {
+		if !body.Stream {
+			t.Error("request is not streaming")
+		}
+		if body.System != "你是助手" {
+			t.Errorf("system = %q, want 你是助手", body.System)
+		}
+		if len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+			t.Errorf("messages = %+v, want one user message", body.Messages)
+		}
+		writeSSE(w,
+			`{"type":"message_start","message":{"id":"msg_1","model":"test-model","usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好，"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"我来查一下。"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"北京\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+
+	var chunks []*ResponseChunk
+	err := provider.StreamChat([]Message{
+		{Role: "system", Content: "你是助手"},
+		{Role: "user", Content: "北京天气怎么样？"},
+	}, nil, func(chunk *ResponseChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 2 text chunks and a final chunk", len(chunks))
+	}
+	if chunks[0].Content != "你好，" || chunks[1].Content != "我来查一下。" {
+		t.Errorf("text chunks = %q, %q", chunks[0].Content, chunks[1].Content)
+	}
+
+	final := chunks[2]
+	if !final.IsFinal || final.FinishReason != "tool_calls" {
+		t.Errorf("final chunk IsFinal=%v FinishReason=%q, want true tool_calls", final.IsFinal, final.FinishReason)
+	}
+	if len(final.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(final.ToolCalls))
+	}
+	call := final.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"北京"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+
+	usage, ok := UsageFromMetadata(final.Metadata)
+	if !ok {
+		t.Fatal("final chunk has no usage")
+	}
+	if usage != (Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19}) {
+		t.Errorf("usage = %+v", usage)
+	}
+	if final.Metadata["model"] != "test-model" {
+		t.Errorf("model = %v, want test-model", final.Metadata["model"])
+	}
+}
+
+func TestAnthropicStreamThinking(t *testing.T) {
+	provider, _ := fakeAnthropic(t, func(w http.ResponseWriter, _ AnthropicRequestBody, _ int) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"usage":{"input_tokens":3}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"想一想"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"好的"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+
+	var chunks []*ResponseChunk
+	err := provider.StreamChat([]Message{{Role: "user", Content: "你好"}}, nil, func(chunk *ResponseChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	if chunks[0].Metadata[MetadataReasoningContent] != "想一想" {
+		t.Errorf("reasoning chunk metadata = %v", chunks[0].Metadata)
+	}
+	if chunks[2].FinishReason != "length" {
+		t.Errorf("FinishReason = %q, want length", chunks[2].FinishReason)
+	}
+}
+
+func TestAnthropicStreamWithoutMessageStop(t *testing.T) {
+	provider, _ := fakeAnthropic(t, func(w http.ResponseWriter, _ AnthropicRequestBody, _ int) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"usage":{"input_tokens":3}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"半句"}}`,
+		)
+	})
+
+	var final *ResponseChunk
+	err := provider.StreamChat([]Message{{Role: "user", Content: "你好"}}, nil, func(chunk *ResponseChunk) error {
+		if chunk.IsFinal {
+			final = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if final == nil || final.FinishReason != "stop" {
+		t.Errorf("final chunk = %+v, want a stop chunk after early EOF", final)
+	}
+}
+
+func TestAnthropicStreamErrorEvent(t *testing.T) {
+	tests := []struct {
+		errorType string
+		kind      ErrorKind
+		retryable bool
+	}{
+		{"overloaded_error", ErrorKindServer, true},
+		{"rate_limit_error", ErrorKindRateLimit, true},
+		{"invalid_request_error", ErrorKindBadRequest, false},
+		{"authentication_error", ErrorKindAuth, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.errorType, func(t *testing.T) {
+			provider, _ := fakeAnthropic(t, func(w http.ResponseWriter, _ AnthropicRequestBody, _ int) {
+				writeSSE(w,
+					`{"type":"message_start","message":{"usage":{"input_tokens":3}}}`,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"开头"}}`,
+					fmt.Sprintf(`{"type":"error","error":{"type":%q,"message":"busy"}}`, tt.errorType),
+				)
+			})
+
+			var texts []string
+			err := provider.StreamChat([]Message{{Role: "user", Content: "你好"}}, nil, func(chunk *ResponseChunk) error {
+				texts = append(texts, chunk.Content)
+				return nil
+			})
+			if err == nil {
+				t.Fatal("StreamChat succeeded, want error")
+			}
+			if kind := ErrorKindOf(err); kind != tt.kind {
+				t.Errorf("ErrorKindOf = %s, want %s", kind, tt.kind)
+			}
+			if IsRetryableError(err) != tt.retryable {
+				t.Errorf("IsRetryableError = %v, want %v", !tt.retryable, tt.retryable)
+			}
+			if strings.Join(texts, "") != "开头" {
+				t.Errorf("delivered text = %q, want 开头 before the error", texts)
+			}
+		})
+	}
+}
+
+func TestAnthropicRetriesOverloaded(t *testing.T) {
+	provider, calls := fakeAnthropic(t, func(w http.ResponseWriter, _ AnthropicRequestBody, attempt int) {
+		if attempt == 1 {
+			w.WriteHeader(529)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"msg_2","model":"test-model","content":[{"type":"text","text":"好了"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":2}}`)
+	})
+
+	resp, err := provider.Chat([]Message{{Role: "user", Content: "你好"}}, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+	if resp.Content != "好了" || resp.FinishReason != "stop" {
+		t.Errorf("response = %q (%s)", resp.Content, resp.FinishReason)
+	}
+}
+
+func TestAnthropicDoesNotRetryBadRequest(t *testing.T) {
+	provider, calls := fakeAnthropic(t, func(w http.ResponseWriter, _ AnthropicRequestBody, _ int) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+	})
+
+	_, err := provider.Chat([]Message{{Role: "user", Content: "你好"}}, nil)
+	if ErrorKindOf(err) != ErrorKindBadRequest {
+		t.Fatalf("err = %v, want bad request", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestAnthropicChatToolUse(t *testing.T) {
+	provider, _ := fakeAnthropic(t, func(w http.ResponseWriter, body AnthropicRequestBody, _ int) {
+		if len(body.Tools) != 1 || body.Tools[0].Name != "take_photo" {
+			t.Errorf("tools = %+v", body.Tools)
+		}
+		if body.ToolChoice == nil || body.ToolChoice.Type != "any" {
+			t.Errorf("tool_choice = %+v, want any", body.ToolChoice)
+		}
+		fmt.Fprint(w, `{"id":"msg_3","model":"test-model","content":[
+			{"type":"text","text":"我看看"},
+			{"type":"tool_use","id":"toolu_9","name":"take_photo","input":{"question":"这是什么"}}
+		],"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":5}}`)
+	})
+
+	resp, err := provider.Chat([]Message{{Role: "user", Content: "这是什么"}}, &ChatOptions{
+		Tools:      []Tool{{Type: "function", Function: ToolFunction{Name: "take_photo"}}},
+		ToolChoice: "required",
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.FinishReason != "tool_calls" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if args := resp.ToolCalls[0].Function.Arguments; args != `{"question":"这是什么"}` {
+		t.Errorf("arguments = %s", args)
+	}
+	usage, _ := UsageFromMetadata(resp.Metadata)
+	if usage.TotalTokens != 25 {
+		t.Errorf("usage = %+v, want 25 total tokens", usage)
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// circuitState 表示熔断器状态
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // 正常放行
+	circuitOpen                         // 拒绝所有请求
+	circuitHalfOpen                     // 放行一个试探请求
+)
+
+// CircuitBreaker 是单个提供商的熔断器
+// 连续失败达到阈值后打开，冷却时间过后放行一个试探请求，成功则关闭
+type CircuitBreaker struct {
+	name             string
+	mu               sync.Mutex
+	state            circuitState
+	failures         int
+	failureThreshold int
+	openDuration     time.Duration
+	openedAt         time.Time
+	probing          bool // 半开状态下是否已有试探请求在进行
+}
+
+// NewCircuitBreaker 创建一个新的熔断器
+func NewCircuitBreaker(name string, failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	if openDuration <= 0 {
+		openDuration = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+	}
+}
+
+// Allow 判断是否放行请求，不放行时返回 ErrCircuitOpen
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.openDuration {
+			return ErrCircuitOpen
+		}
+		cb.state = circuitHalfOpen
+		cb.probing = true
+		log.Printf("[LLM:%s] Circuit breaker half-open, sending probe request", cb.name)
+		return nil
+	case circuitHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	}
+	return nil
+}
+
+// RecordSuccess 记录一次成功请求
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != circuitClosed {
+		log.Printf("[LLM:%s] Circuit breaker closed", cb.name)
+	}
+	cb.state = circuitClosed
+	cb.failures = 0
+	cb.probing = false
+}
+
+// RecordFailure 记录一次失败请求
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == circuitHalfOpen || cb.failures >= cb.failureThreshold {
+		if cb.state != circuitOpen {
+			log.Printf("[LLM:%s] Circuit breaker opened after %d failures", cb.name, cb.failures)
+		}
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+	}
+}
@@ -1,17 +1,21 @@
 package llm
 
 import (
+	"fmt"
 	"log"
 	"sync"
 )
 
 // Provider 表示不同的 LLM (大语言模型) 提供商接口
 type Provider interface {
-	// Chat 发送对话消息并获取响应
-	Chat(messages []Message, options map[string]interface{}) (*Response, error)
+	// Chat 发送对话消息并获取响应，options 为 nil 时使用默认参数
+	Chat(messages []Message, options *ChatOptions) (*Response, error)
 	
 	// StreamChat 发送对话消息并通过流式接口获取响应
-	StreamChat(messages []Message, options map[string]interface{}, callback StreamCallback) error
+	StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error
+	
+	// Capabilities 返回提供商支持的请求参数
+	Capabilities() Capabilities
 	
 	// Initialize 初始化 LLM 服务提供商
 	Initialize() error
@@ -25,12 +29,14 @@ type Message struct {
 	Role    string                 `json:"role"`     // 角色：user, assistant, system
 	Content string                 `json:"content"`  // 消息内容
 	Metadata map[string]interface{} `json:"metadata,omitempty"` // 元数据
+	Parts   []ContentPart          `json:"parts,omitempty"`    // 多模态内容（文字和图片），设置后优先于 Content
 }
 
 // Response 表示 LLM 的响应
 type Response struct {
 	Content     string                 `json:"content"`      // 响应内容
 	FinishReason string                 `json:"finish_reason"` // 结束原因
+	ToolCalls   []ToolCall             `json:"tool_calls,omitempty"` // 模型请求的工具调用
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`  // 元数据
 }
 
@@ -42,21 +48,63 @@ type ResponseChunk struct {
 	Content     string                 `json:"content"`      // 当前块内容
 	FinishReason string                 `json:"finish_reason,omitempty"` // 结束原因（仅在最后一个块中存在）
 	IsFinal     bool                   `json:"is_final"`     // 是否为最后一个块
+	ToolCalls   []ToolCall             `json:"tool_calls,omitempty"` // 完整的工具调用（仅在最后一个块中存在）
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`  // 元数据
 }
 
+// Usage 表示一次请求消耗的令牌数
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens" yaml:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens" yaml:"total_tokens"`
+}
+
+// 响应元数据中的通用键
+const (
+	// MetadataUsage 存放本次请求的 Usage，流式响应中只出现在最后一个数据块
+	MetadataUsage = "usage"
+	
+	// MetadataProvider 存放实际处理请求的提供商名称，由 LLMManager 填写，流式响应中每个数据块都有
+	MetadataProvider = "provider"
+)
+
+// UsageFromMetadata 从响应或数据块的元数据中取出令牌用量
+func UsageFromMetadata(metadata map[string]interface{}) (Usage, bool) {
+	usage, ok := metadata[MetadataUsage].(Usage)
+	return usage, ok
+}
+
+// EstimateUsage 按字符数粗略估算令牌用量
+// 用于模拟模型，以及被调用方提前结束、收不到最后一个数据块的流式请求
+func EstimateUsage(messages []Message, response string) Usage {
+	prompt := 0
+	for _, msg := range messages {
+		prompt += len([]rune(msg.Content))
+	}
+	completion := len([]rune(response))
+	
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
 // LLMManager 管理多个 LLM 提供商
 type LLMManager struct {
 	providers  map[string]Provider
 	mutex      sync.RWMutex
 	initialized bool
 	defaultProvider string
+	fallbackChain []string // 默认提供商失败后依次尝试的备用提供商
+	health *healthTracker
 }
 
 // NewLLMManager 创建一个新的 LLM 管理器
 func NewLLMManager() *LLMManager {
 	return &LLMManager{
 		providers: make(map[string]Provider),
+		health:    newHealthTracker(),
 	}
 }
 
@@ -104,8 +152,8 @@ func (lm *LLMManager) Initialize() error {
 	return nil
 }
 
-// Chat 使用默认提供商进行对话
-func (lm *LLMManager) Chat(messages []Message, options map[string]interface{}) (*Response, error) {
+// Chat 使用默认提供商进行对话，遇到可重试错误或提供商不支持请求参数时按备用链切换提供商
+func (lm *LLMManager) Chat(messages []Message, options *ChatOptions) (*Response, error) {
 	lm.mutex.RLock()
 	defer lm.mutex.RUnlock()
 	
@@ -113,16 +161,40 @@ func (lm *LLMManager) Chat(messages []Message, options map[string]interface{}) (
 		return nil, ErrNotInitialized
 	}
 	
-	provider, exists := lm.providers[lm.defaultProvider]
-	if !exists {
-		return nil, ErrProviderNotFound
+	var lastErr error = ErrProviderNotFound
+	for _, name := range lm.candidateChain() {
+		provider, exists := lm.providers[name]
+		if !exists {
+			continue
+		}
+		
+		response, err := provider.Chat(messages, options)
+		lm.health.record(name, err)
+		if err == nil {
+			if response.Metadata == nil {
+				response.Metadata = make(map[string]interface{})
+			}
+			response.Metadata[MetadataProvider] = name
+			return response, nil
+		}
+		
+		lastErr = err
+		if isUnsupported(err) {
+			log.Printf("[LLM] Provider %s cannot serve this request, trying next: %v", name, err)
+			continue
+		}
+		if !IsRetryableError(err) {
+			return nil, err
+		}
+		log.Printf("[LLM] Provider %s failed with retryable error, trying next: %v", name, err)
 	}
 	
-	return provider.Chat(messages, options)
+	return nil, lastErr
 }
 
 // StreamChat 使用默认提供商进行流式对话
-func (lm *LLMManager) StreamChat(messages []Message, options map[string]interface{}, callback StreamCallback) error {
+// 只有在尚未向回调交付任何数据块时才会切换到备用提供商，避免重复输出
+func (lm *LLMManager) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
 	lm.mutex.RLock()
 	defer lm.mutex.RUnlock()
 	
@@ -130,12 +202,88 @@ func (lm *LLMManager) StreamChat(messages []Message, options map[string]interfac
 		return ErrNotInitialized
 	}
 	
-	provider, exists := lm.providers[lm.defaultProvider]
-	if !exists {
-		return ErrProviderNotFound
+	var lastErr error = ErrProviderNotFound
+	for _, name := range lm.candidateChain() {
+		provider, exists := lm.providers[name]
+		if !exists {
+			continue
+		}
+		
+		delivered := false
+		var callbackErr error
+		err := provider.StreamChat(messages, options, func(chunk *ResponseChunk) error {
+			delivered = true
+			// 每个数据块都标明实际使用的提供商，调用方提前结束流时也能按提供商统计用量
+			if chunk.Metadata == nil {
+				chunk.Metadata = make(map[string]interface{})
+			}
+			chunk.Metadata[MetadataProvider] = name
+			if err := callback(chunk); err != nil {
+				callbackErr = err
+				return err
+			}
+			return nil
+		})
+		
+		// 回调自身返回的错误不是提供商的问题，直接返回
+		if callbackErr != nil {
+			return err
+		}
+		
+		lm.health.record(name, err)
+		if err == nil {
+			return nil
+		}
+		
+		lastErr = err
+		if delivered || !(IsRetryableError(err) || isUnsupported(err)) {
+			return err
+		}
+		log.Printf("[LLM] Provider %s failed before first chunk, trying next: %v", name, err)
 	}
 	
-	return provider.StreamChat(messages, options, callback)
+	return lastErr
+}
+
+// SetFallbackChain 设置备用提供商链，默认提供商失败时按顺序尝试
+func (lm *LLMManager) SetFallbackChain(names ...string) error {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+	
+	for _, name := range names {
+		if _, exists := lm.providers[name]; !exists {
+			return fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+		}
+	}
+	
+	lm.fallbackChain = append([]string{}, names...)
+	log.Printf("[LLM] Fallback chain set: %v", lm.fallbackChain)
+	return nil
+}
+
+// candidateChain 返回本次请求应依次尝试的提供商（调用方需持有读锁）
+// 被健康检查暂时摘除的提供商会被跳过；如果全部被摘除，仍按原顺序尝试，由各提供商的熔断器决定是否直接失败
+func (lm *LLMManager) candidateChain() []string {
+	chain := make([]string, 0, len(lm.fallbackChain)+1)
+	seen := make(map[string]bool)
+	for _, name := range append([]string{lm.defaultProvider}, lm.fallbackChain...) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	
+	available := make([]string, 0, len(chain))
+	for _, name := range chain {
+		if !lm.health.isEjected(name) {
+			available = append(available, name)
+		}
+	}
+	if len(available) == 0 {
+		return chain
+	}
+	return available
 }
 
 // GetProvider 获取指定的 LLM 提供商
@@ -151,6 +299,33 @@ func (lm *LLMManager) GetProvider(name string) (Provider, error) {
 	return provider, nil
 }
 
+// Capabilities 返回默认提供商和备用链上各提供商支持的请求参数的并集
+// 默认提供商不支持的参数会由 Chat/StreamChat 切换到支持它的备用提供商处理
+func (lm *LLMManager) Capabilities() (Capabilities, error) {
+	lm.mutex.RLock()
+	defer lm.mutex.RUnlock()
+	
+	var caps Capabilities
+	found := false
+	seen := make(map[string]bool)
+	for _, name := range append([]string{lm.defaultProvider}, lm.fallbackChain...) {
+		provider, exists := lm.providers[name]
+		if !exists || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !found {
+			caps, found = provider.Capabilities(), true
+			continue
+		}
+		caps = caps.union(provider.Capabilities())
+	}
+	if !found {
+		return Capabilities{}, ErrProviderNotFound
+	}
+	return caps, nil
+}
+
 // 错误定义
 var (
 	ErrProviderNotFound = NewLLMError("llm provider not found")
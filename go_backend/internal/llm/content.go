@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// 多模态内容片段类型
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image_url"
+)
+
+// ContentPart 表示多模态消息中的一个片段（OpenAI 兼容格式）
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 表示图片片段，URL 可以是 http(s) 地址或 data URL
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // low、high 或 auto
+}
+
+// ToolCall 表示模型发起的一次工具调用
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 表示工具调用的函数名和 JSON 格式的参数
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// NewImagePart 把图片数据编码为 data URL 片段
+func NewImagePart(data []byte, mimeType string) ContentPart {
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+	return ContentPart{
+		Type: ContentPartImage,
+		ImageURL: &ImageURL{
+			URL: fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+		},
+	}
+}
+
+// NewImageMessage 创建一条包含文字和图片的用户消息
+// Content 同时保留文字，不支持多模态的地方（如日志、历史记录）仍可使用
+func NewImageMessage(text string, images ...ContentPart) Message {
+	parts := make([]ContentPart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, ContentPart{Type: ContentPartText, Text: text})
+	}
+	parts = append(parts, images...)
+
+	return Message{
+		Role:    "user",
+		Content: text,
+		Parts:   parts,
+	}
+}
+
+// HasImage 判断消息是否包含图片
+func (m Message) HasImage() bool {
+	for _, part := range m.Parts {
+		if part.Type == ContentPartImage {
+			return true
+		}
+	}
+	return false
+}
+
+// TextOnly 返回去掉多模态片段后的消息，用于保存到历史记录，避免每轮都重复发送图片
+func (m Message) TextOnly() Message {
+	m.Parts = nil
+	return m
+}
+
+// CheckMessages 确认提供商支持消息中的内容类型
+func CheckMessages(provider string, messages []Message, caps Capabilities) error {
+	if caps.Vision {
+		return nil
+	}
+	for _, msg := range messages {
+		if msg.HasImage() {
+			return &UnsupportedOptionsError{Provider: provider, Options: []string{"image input"}}
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"strings"
 	"time"
@@ -19,27 +20,84 @@ type DeepseekProvider struct {
 	model       string
 	httpClient  *http.Client
 	initialized bool
+	vision      bool // 模型是否接受图片输入
+	streamUsage bool // 流式请求是否带 stream_options.include_usage，部分兼容服务不接受这个字段
+	
+	// 重试与熔断
+	maxRetries     int           // 失败后的最大重试次数
+	retryBaseDelay time.Duration // 指数退避的基础延迟
+	retryMaxDelay  time.Duration // 单次等待的最大延迟
+	retryDeadline  time.Duration // 包括重试在内的总时长上限，超过后不再发起新的尝试
+	breaker        *CircuitBreaker
 }
 
 // DeepseekRequestBody 表示发送到 Deepseek API 的请求体结构
 type DeepseekRequestBody struct {
-	Model       string                 `json:"model"`
-	Messages    []DeepseekMessage      `json:"messages"`
-	Stream      bool                   `json:"stream"`
-	Temperature float64                `json:"temperature,omitempty"`
-	MaxTokens   int                    `json:"max_tokens,omitempty"`
-	TopP        float64                `json:"top_p,omitempty"`
-	FrequencyP  float64                `json:"frequency_penalty,omitempty"`
-	PresenceP   float64                `json:"presence_penalty,omitempty"`
-	Tools       []interface{}          `json:"tools,omitempty"`
-	ToolChoice  interface{}            `json:"tool_choice,omitempty"`
-	System      string                 `json:"system,omitempty"`
+	Model          string                  `json:"model"`
+	Messages       []DeepseekMessage       `json:"messages"`
+	Stream         bool                    `json:"stream"`
+	Temperature    *float64                `json:"temperature,omitempty"`
+	MaxTokens      *int                    `json:"max_tokens,omitempty"`
+	TopP           *float64                `json:"top_p,omitempty"`
+	FrequencyP     *float64                `json:"frequency_penalty,omitempty"`
+	PresenceP      *float64                `json:"presence_penalty,omitempty"`
+	Stop           []string                `json:"stop,omitempty"`
+	Tools          []Tool                  `json:"tools,omitempty"`
+	ToolChoice     interface{}             `json:"tool_choice,omitempty"`
+	ResponseFormat *DeepseekResponseFormat `json:"response_format,omitempty"`
+	User           string                  `json:"user,omitempty"`
+	StreamOptions  *DeepseekStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// DeepseekResponseFormat 表示请求的输出格式
+type DeepseekResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// deepseekDefaultEndpoint 是 Deepseek 官方的对话接口地址
+const deepseekDefaultEndpoint = "https://api.deepseek.com/v1/chat/completions"
+
+// deepseekMaxOutputTokens 是 Deepseek 单次请求允许的最大输出令牌数
+const deepseekMaxOutputTokens = 8192
+
+// 未指定参数时使用的默认值
+const (
+	deepseekDefaultTemperature = 0.7
+	deepseekDefaultMaxTokens   = 2000
+)
+
+// DeepseekStreamOptions 表示流式请求的附加选项
+type DeepseekStreamOptions struct {
+	// IncludeUsage 要求在 [DONE] 之前额外返回一个只包含用量统计的数据块
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // DeepseekMessage 表示 Deepseek API 消息格式
 type DeepseekMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ReasoningContent 仅出现在推理模型的响应中，API 要求请求消息中不得携带
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// ToolCalls 是模型响应中的工具调用
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Parts 是多模态内容，设置时序列化为 content 数组
+	Parts []ContentPart `json:"-"`
+}
+
+// MarshalJSON 在有多模态内容时把 content 序列化为片段数组，否则保持字符串
+func (m DeepseekMessage) MarshalJSON() ([]byte, error) {
+	type plainMessage DeepseekMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plainMessage(m))
+	}
+	
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{
+		Role:    m.Role,
+		Content: m.Parts,
+	})
 }
 
 // DeepseekResponse 表示 Deepseek API 的响应结构
@@ -66,6 +124,15 @@ type DeepseekUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// toUsage 转换为通用的用量格式
+func (u DeepseekUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
 // DeepseekStreamResponse 表示 Deepseek API 的流式响应
 type DeepseekStreamResponse struct {
 	ID      string         `json:"id"`
@@ -73,6 +140,7 @@ type DeepseekStreamResponse struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []StreamChoice `json:"choices"`
+	Usage   *DeepseekUsage `json:"usage,omitempty"` // 仅出现在 include_usage 的用量数据块中
 }
 
 // StreamChoice 表示 Deepseek API 的流式响应选择
@@ -84,10 +152,24 @@ type StreamChoice struct {
 
 // StreamDelta 表示 Deepseek API 的流式响应增量内容
 type StreamDelta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role             string          `json:"role,omitempty"`
+	Content          string          `json:"content,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"` // 推理模型的思考过程
+	ToolCalls        []ToolCallDelta `json:"tool_calls,omitempty"`
 }
 
+// ToolCallDelta 表示流式响应中的工具调用片段，参数按 Index 分多次送达
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// MetadataReasoningContent 是 ResponseChunk/Response 元数据中存放推理内容的键
+// 推理内容只用于展示和日志，不应被朗读，也不应回传给 API
+const MetadataReasoningContent = "reasoning_content"
+
 // NewDeepseekProvider 创建一个新的 Deepseek 提供商
 func NewDeepseekProvider(apiKey string, model string) *DeepseekProvider {
 	if model == "" {
@@ -96,61 +178,89 @@ func NewDeepseekProvider(apiKey string, model string) *DeepseekProvider {
 	
 	return &DeepseekProvider{
 		apiKey:      apiKey,
-		apiEndpoint: "https://api.deepseek.com/v1/chat/completions",
+		apiEndpoint: deepseekDefaultEndpoint,
+		streamUsage: true,
 		model:       model,
 		httpClient: &http.Client{
 			Timeout: 90 * time.Second,
 		},
+		maxRetries:     2,
+		retryBaseDelay: 500 * time.Millisecond,
+		retryMaxDelay:  8 * time.Second,
+		retryDeadline:  15 * time.Second,
+		breaker:        NewCircuitBreaker("Deepseek", 5, 30*time.Second),
+	}
+}
+
+// SetEndpoint 设置 OpenAI 兼容的对话接口地址，用于接入其他兼容服务（如视觉模型）
+// 不是官方地址时默认不再请求流式用量，需要时用 SetStreamUsage 打开
+func (p *DeepseekProvider) SetEndpoint(endpoint string) {
+	if endpoint != "" && endpoint != p.apiEndpoint {
+		p.apiEndpoint = endpoint
+		p.streamUsage = endpoint == deepseekDefaultEndpoint
+	}
+}
+
+// SetStreamUsage 设置流式请求是否要求服务端在结束前返回用量统计
+func (p *DeepseekProvider) SetStreamUsage(enabled bool) {
+	p.streamUsage = enabled
+}
+
+// EnableVision 声明当前模型接受图片输入
+func (p *DeepseekProvider) EnableVision() {
+	p.vision = true
+}
+
+// SetRetryDeadline 设置包括重试在内的总时长上限，等待下一次重试会超过上限时直接返回错误，
+// 把剩余的时间留给 LLMManager 切换到备用提供商
+func (p *DeepseekProvider) SetRetryDeadline(deadline time.Duration) {
+	if deadline > 0 {
+		p.retryDeadline = deadline
+	}
+}
+
+// SetCircuitBreaker 设置熔断阈值：连续 failureThreshold 次请求最终失败后打开熔断，
+// openDuration 内直接返回 ErrCircuitOpen，之后放行一个试探请求
+func (p *DeepseekProvider) SetCircuitBreaker(failureThreshold int, openDuration time.Duration) {
+	p.breaker = NewCircuitBreaker("Deepseek", failureThreshold, openDuration)
+}
+
+// SetRetryPolicy 设置重试次数和退避延迟
+func (p *DeepseekProvider) SetRetryPolicy(maxRetries int, baseDelay, maxDelay time.Duration) {
+	if maxRetries >= 0 {
+		p.maxRetries = maxRetries
+	}
+	if baseDelay > 0 {
+		p.retryBaseDelay = baseDelay
+	}
+	if maxDelay > 0 {
+		p.retryMaxDelay = maxDelay
 	}
 }
 
 // Chat 实现非流式对话
-func (p *DeepseekProvider) Chat(messages []Message, options map[string]interface{}) (*Response, error) {
+func (p *DeepseekProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
 	if !p.initialized {
 		return nil, ErrNotInitialized
 	}
-	
-	// 准备请求
-	dsMessages := convertToDeepseekMessages(messages)
-	
-	body := DeepseekRequestBody{
-		Model:       p.model,
-		Messages:    dsMessages,
-		Stream:      false,
-		Temperature: 0.7,  // 默认值
-		MaxTokens:   2000, // 默认值
+	if err := options.Check("deepseek", p.Capabilities()); err != nil {
+		return nil, err
 	}
-	
-	// 处理选项
-	p.applyOptions(&body, options)
-	
-	// 发送请求并获取响应
-	jsonData, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("error marshaling request: %w", err)
-	}
-	
-	req, err := http.NewRequest("POST", p.apiEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+	if err := CheckMessages("deepseek", messages, p.Capabilities()); err != nil {
+		return nil, err
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	// 准备请求
+	body := p.newRequestBody(messages, options, false)
 	
+	// 发送请求并获取响应
 	log.Printf("[LLM:Deepseek] Sending Chat request with %d messages", len(messages))
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.sendRequest(&body)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error response from API [%d]: %s", resp.StatusCode, string(bodyBytes))
-	}
-	
 	// 解析响应
 	var deepseekResp DeepseekResponse
 	if err := json.NewDecoder(resp.Body).Decode(&deepseekResp); err != nil {
@@ -166,68 +276,54 @@ func (p *DeepseekProvider) Chat(messages []Message, options map[string]interface
 	result := &Response{
 		Content:     deepseekResp.Choices[0].Message.Content,
 		FinishReason: deepseekResp.Choices[0].FinishReason,
+		ToolCalls:   deepseekResp.Choices[0].Message.ToolCalls,
 		Metadata: map[string]interface{}{
 			"model":       deepseekResp.Model,
-			"usage":       deepseekResp.Usage,
+			MetadataUsage: deepseekResp.Usage.toUsage(),
 			"id":          deepseekResp.ID,
 			"created":     deepseekResp.Created,
 		},
 	}
 	
+	if reasoning := deepseekResp.Choices[0].Message.ReasoningContent; reasoning != "" {
+		result.Metadata[MetadataReasoningContent] = reasoning
+	}
+	
 	return result, nil
 }
 
 // StreamChat 实现流式对话
-func (p *DeepseekProvider) StreamChat(messages []Message, options map[string]interface{}, callback StreamCallback) error {
+func (p *DeepseekProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
 	if !p.initialized {
 		return ErrNotInitialized
 	}
-	
-	// 准备请求
-	dsMessages := convertToDeepseekMessages(messages)
-	
-	body := DeepseekRequestBody{
-		Model:       p.model,
-		Messages:    dsMessages,
-		Stream:      true,
-		Temperature: 0.7,  // 默认值
-		MaxTokens:   2000, // 默认值
+	if err := options.Check("deepseek", p.Capabilities()); err != nil {
+		return err
 	}
-	
-	// 处理选项
-	p.applyOptions(&body, options)
-	
-	// 发送请求
-	jsonData, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("error marshaling request: %w", err)
+	if err := CheckMessages("deepseek", messages, p.Capabilities()); err != nil {
+		return err
 	}
 	
-	req, err := http.NewRequest("POST", p.apiEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+	// 准备请求
+	body := p.newRequestBody(messages, options, true)
+	if p.streamUsage {
+		body.StreamOptions = &DeepseekStreamOptions{IncludeUsage: true}
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-	req.Header.Set("Accept", "text/event-stream")
-	
+	// 发送请求（重试只发生在收到首个数据块之前）
 	log.Printf("[LLM:Deepseek] Sending StreamChat request with %d messages", len(messages))
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.sendRequest(&body)
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 	
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("error response from API [%d]: %s", resp.StatusCode, string(bodyBytes))
-	}
-	
 	// 处理 Server-Sent Events (SSE) 流
+	// 带结束原因的数据块会暂缓交付，等用量数据块到达后合并为最终块
 	reader := bufio.NewReader(resp.Body)
-	contentBuilder := strings.Builder{}
+	var finalChunk *ResponseChunk
+	var usage *DeepseekUsage
+	var toolCalls []ToolCall // 按 index 拼接的工具调用
 	
 	for {
 		line, err := reader.ReadString('\n')
@@ -251,17 +347,6 @@ func (p *DeepseekProvider) StreamChat(messages []Message, options map[string]int
 			
 			// 检查是否是流结束标记
 			if data == "[DONE]" {
-				// 创建最终响应块
-				finalChunk := &ResponseChunk{
-					Content:     contentBuilder.String(),
-					IsFinal:     true,
-					FinishReason: "stop", // 假设正常结束
-				}
-				
-				// 调用回调处理最终块
-				if err := callback(finalChunk); err != nil {
-					return fmt.Errorf("error in callback (final): %w", err)
-				}
 				break
 			}
 			
@@ -271,27 +356,43 @@ func (p *DeepseekProvider) StreamChat(messages []Message, options map[string]int
 				return fmt.Errorf("error parsing stream data: %w", err)
 			}
 			
+			// 用量数据块没有选择，只记录用量
+			if streamResp.Usage != nil {
+				usage = streamResp.Usage
+			}
+			
 			// 确保有选择
 			if len(streamResp.Choices) == 0 {
 				continue
 			}
 			
 			choice := streamResp.Choices[0]
-			content := choice.Delta.Content
+			toolCalls = mergeToolCallDeltas(toolCalls, choice.Delta.ToolCalls)
 			
-			// 添加到内容构建器
-			contentBuilder.WriteString(content)
+			// 只有工具调用片段的数据块不交给回调
+			if len(choice.Delta.ToolCalls) > 0 && choice.Delta.Content == "" && choice.Delta.ReasoningContent == "" && choice.FinishReason == "" {
+				continue
+			}
 			
 			// 创建响应块
 			chunk := &ResponseChunk{
-				Content: content,
+				Content: choice.Delta.Content,
 				IsFinal: false,
 			}
 			
-			// 如果有结束原因，设置相应字段
+			// 推理模型的思考内容放在元数据中，不进入 Content
+			if choice.Delta.ReasoningContent != "" {
+				chunk.Metadata = map[string]interface{}{
+					MetadataReasoningContent: choice.Delta.ReasoningContent,
+				}
+			}
+			
+			// 如果有结束原因，暂缓交付
 			if choice.FinishReason != "" {
 				chunk.FinishReason = choice.FinishReason
 				chunk.IsFinal = true
+				finalChunk = chunk
+				continue
 			}
 			
 			// 调用回调处理块
@@ -301,9 +402,112 @@ func (p *DeepseekProvider) StreamChat(messages []Message, options map[string]int
 		}
 	}
 	
+	// 上游没有给出结束原因时补发最终响应块（内容已全部通过增量块交付）
+	if finalChunk == nil {
+		finalChunk = &ResponseChunk{
+			IsFinal:     true,
+			FinishReason: "stop", // 假设正常结束
+		}
+	}
+	finalChunk.ToolCalls = toolCalls
+	if usage != nil {
+		if finalChunk.Metadata == nil {
+			finalChunk.Metadata = make(map[string]interface{})
+		}
+		finalChunk.Metadata[MetadataUsage] = usage.toUsage()
+	}
+	
+	// 调用回调处理最终块
+	if err := callback(finalChunk); err != nil {
+		return fmt.Errorf("error in callback (final): %w", err)
+	}
+	
 	return nil
 }
 
+// sendRequest 发送请求并返回状态为 200 的响应
+// 超时、连接失败、限流和服务端错误会按指数退避重试，总时长不超过 retryDeadline，其余错误立即返回
+func (p *DeepseekProvider) sendRequest(body *DeepseekRequestBody) (*http.Response, error) {
+	if err := p.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+	
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		resp, err := p.doRequest(jsonData, body.Stream)
+		if err == nil {
+			p.breaker.RecordSuccess()
+			return resp, nil
+		}
+		
+		if !IsRetryableError(err) {
+			// 服务端正常响应了请求，只是请求本身有问题，不计入熔断
+			p.breaker.RecordSuccess()
+			return nil, err
+		}
+		
+		delay := p.backoffDelay(attempt, err)
+		if attempt >= p.maxRetries || time.Since(start)+delay > p.retryDeadline {
+			p.breaker.RecordFailure()
+			return nil, err
+		}
+		
+		log.Printf("[LLM:Deepseek] Request failed (attempt %d/%d), retrying in %v: %v", attempt+1, p.maxRetries+1, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// doRequest 执行一次 HTTP 请求
+func (p *DeepseekProvider) doRequest(jsonData []byte, stream bool) (*http.Response, error) {
+	req, err := http.NewRequest("POST", p.apiEndpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, newAPIError("deepseek", resp, bodyBytes)
+	}
+	
+	return resp, nil
+}
+
+// backoffDelay 计算第 attempt 次重试前的等待时间，优先使用服务端的 Retry-After
+func (p *DeepseekProvider) backoffDelay(attempt int, err error) time.Duration {
+	if apiErr, ok := err.(*APIError); ok && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > p.retryMaxDelay {
+			return p.retryMaxDelay
+		}
+		return apiErr.RetryAfter
+	}
+	
+	delay := p.retryBaseDelay << uint(attempt)
+	if delay > p.retryMaxDelay || delay <= 0 {
+		delay = p.retryMaxDelay
+	}
+	// 加入最多 50% 的随机抖动，避免多个设备同时重试
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
 // Initialize 初始化 Deepseek 提供商
 func (p *DeepseekProvider) Initialize() error {
 	if p.apiKey == "" {
@@ -315,6 +519,31 @@ func (p *DeepseekProvider) Initialize() error {
 	return nil
 }
 
+// HealthCheck 通过模型列表接口探测 Deepseek 服务是否可用，不消耗令牌
+func (p *DeepseekProvider) HealthCheck() error {
+	modelsEndpoint := strings.TrimSuffix(p.apiEndpoint, "/chat/completions") + "/models"
+	
+	req, err := http.NewRequest("GET", modelsEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return newAPIError("deepseek", resp, bodyBytes)
+	}
+	
+	return nil
+}
+
 // Cleanup 清理 Deepseek 提供商资源
 func (p *DeepseekProvider) Cleanup() error {
 	log.Printf("[LLM:Deepseek] Cleaning up Deepseek provider")
@@ -322,57 +551,90 @@ func (p *DeepseekProvider) Cleanup() error {
 	return nil
 }
 
-// applyOptions 应用选项到请求体
-func (p *DeepseekProvider) applyOptions(body *DeepseekRequestBody, options map[string]interface{}) {
-	// 应用温度
-	if temp, ok := options["temperature"].(float64); ok {
-		body.Temperature = temp
+// Capabilities 返回 Deepseek 支持的请求参数
+func (p *DeepseekProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Temperature:      true,
+		TopP:             true,
+		MaxTokens:        true,
+		Penalties:        true,
+		Stop:             true,
+		Tools:            true,
+		User:             true,
+		Vision:           p.vision,
+		ResponseFormats:  []ResponseFormat{ResponseFormatText, ResponseFormatJSON},
+		MaxStopSequences: 16,
+		MaxOutputTokens:  deepseekMaxOutputTokens,
 	}
-	
-	// 应用最大令牌数
-	if maxTokens, ok := options["max_tokens"].(int); ok {
-		body.MaxTokens = maxTokens
+}
+
+// newRequestBody 根据消息和选项构造请求体，未设置的参数使用默认值
+// 系统消息保持在 messages 中，这是 OpenAI 兼容接口的约定
+func (p *DeepseekProvider) newRequestBody(messages []Message, options *ChatOptions, stream bool) DeepseekRequestBody {
+	body := DeepseekRequestBody{
+		Model:       p.model,
+		Messages:    convertToDeepseekMessages(messages),
+		Stream:      stream,
+		Temperature: Float(deepseekDefaultTemperature),
+		MaxTokens:   Int(deepseekDefaultMaxTokens),
 	}
-	
-	// 应用 top_p
-	if topP, ok := options["top_p"].(float64); ok {
-		body.TopP = topP
+	if options == nil {
+		return body
 	}
 	
-	// 应用频率惩罚
-	if freqP, ok := options["frequency_penalty"].(float64); ok {
-		body.FrequencyP = freqP
+	if options.Temperature != nil {
+		body.Temperature = options.Temperature
 	}
-	
-	// 应用存在惩罚
-	if presP, ok := options["presence_penalty"].(float64); ok {
-		body.PresenceP = presP
+	if options.MaxTokens != nil {
+		body.MaxTokens = options.MaxTokens
 	}
-	
-	// 应用系统提示
-	if system, ok := options["system"].(string); ok {
-		body.System = system
-	} else {
-		// 查找并提取系统消息
-		for _, msg := range body.Messages {
-			if msg.Role == "system" {
-				body.System = msg.Content
-				break
-			}
+	body.TopP = options.TopP
+	body.FrequencyP = options.FrequencyPenalty
+	body.PresenceP = options.PresencePenalty
+	body.Stop = options.Stop
+	body.Tools = options.Tools
+	body.User = options.User
+	
+	// tool_choice 为工具名称时需要转换为对象形式
+	switch options.ToolChoice {
+	case "":
+	case "auto", "none", "required":
+		body.ToolChoice = options.ToolChoice
+	default:
+		body.ToolChoice = map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": options.ToolChoice},
 		}
 	}
 	
-	// 过滤掉系统消息（因为现在用 system 字段）
-	filteredMessages := make([]DeepseekMessage, 0, len(body.Messages))
-	for _, msg := range body.Messages {
-		if msg.Role != "system" {
-			filteredMessages = append(filteredMessages, msg)
+	if options.ResponseFormat != "" {
+		body.ResponseFormat = &DeepseekResponseFormat{Type: string(options.ResponseFormat)}
+	}
+	
+	return body
+}
+
+// mergeToolCallDeltas 把流式工具调用片段拼接到已有的工具调用上
+func mergeToolCallDeltas(calls []ToolCall, deltas []ToolCallDelta) []ToolCall {
+	for _, delta := range deltas {
+		for len(calls) <= delta.Index {
+			calls = append(calls, ToolCall{Type: "function"})
+		}
+		call := &calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
 		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
 	}
-	body.Messages = filteredMessages
+	return calls
 }
 
 // convertToDeepseekMessages 将通用消息格式转换为 Deepseek 消息格式
+// 只转换角色、内容和多模态片段，元数据（包括推理内容）不会回传给 API
 func convertToDeepseekMessages(messages []Message) []DeepseekMessage {
 	result := make([]DeepseekMessage, 0, len(messages))
 	
@@ -380,6 +642,7 @@ func convertToDeepseekMessages(messages []Message) []DeepseekMessage {
 		result = append(result, DeepseekMessage{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Parts:   msg.Parts,
 		})
 	}
 	
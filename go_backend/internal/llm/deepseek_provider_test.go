@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingDeepseek 启动一个总是返回 503 的接口，返回提供商和请求计数
+func failingDeepseek(t *testing.T) (*DeepseekProvider, *int32) {
+	t.Helper()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"message":"overloaded"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewDeepseekProvider("test-key", "")
+	provider.SetEndpoint(server.URL)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return provider, &calls
+}
+
+func TestDeepseekRetryDeadline(t *testing.T) {
+	provider, calls := failingDeepseek(t)
+	provider.SetRetryPolicy(20, 40*time.Millisecond, 40*time.Millisecond)
+	provider.SetRetryDeadline(100 * time.Millisecond)
+
+	start := time.Now()
+	_, err := provider.Chat([]Message{{Role: "user", Content: "你好"}}, nil)
+	if !IsRetryableError(err) {
+		t.Fatalf("err = %v, want a retryable error", err)
+	}
+	// 每次等待 20~40ms，100ms 内最多发起六次请求，远少于重试次数上限
+	if n := atomic.LoadInt32(calls); n < 2 || n > 6 {
+		t.Errorf("sent %d requests, want retries bounded by the deadline", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %v despite the 100ms deadline", elapsed)
+	}
+}
+
+func TestManagerEjectsFailingProvider(t *testing.T) {
+	provider, calls := failingDeepseek(t)
+	provider.SetRetryPolicy(0, time.Millisecond, time.Millisecond)
+
+	manager := NewLLMManager()
+	manager.RegisterProvider("deepseek", provider)
+	manager.RegisterProvider("mock", NewMockProvider("备用"))
+	if err := manager.SetFallbackChain("mock"); err != nil {
+		t.Fatal(err)
+	}
+	manager.SetEjectionPolicy(2, time.Minute)
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []Message{{Role: "user", Content: "你好"}}
+	for i := 0; i < 3; i++ {
+		response, err := manager.Chat(messages, nil)
+		if err != nil || response.Metadata[MetadataProvider] != "mock" {
+			t.Fatalf("turn %d: response = %+v, err %v", i, response, err)
+		}
+	}
+	// 连续失败两次后被摘除，第三轮直接使用备用提供商
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("failing provider received %d requests, want 2", n)
+	}
+	if statuses := manager.ProviderStatuses(); statuses[0].Name != "deepseek" || statuses[0].Healthy {
+		t.Errorf("statuses = %+v, want deepseek ejected", statuses)
+	}
+}
+
+func TestDeepseekStreamUsageOption(t *testing.T) {
+	var streamOptions []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		_, present := body["stream_options"]
+		streamOptions = append(streamOptions, present)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"你好\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewDeepseekProvider("test-key", "")
+	if !provider.streamUsage {
+		t.Fatal("official endpoint should request stream usage by default")
+	}
+	provider.SetEndpoint(server.URL)
+	if err := provider.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := func() {
+		messages := []Message{{Role: "user", Content: "你好"}}
+		if err := provider.StreamChat(messages, nil, func(*ResponseChunk) error { return nil }); err != nil {
+			t.Fatalf("StreamChat: %v", err)
+		}
+	}
+	stream()
+	provider.SetStreamUsage(true)
+	stream()
+
+	if len(streamOptions) != 2 || streamOptions[0] || !streamOptions[1] {
+		t.Errorf("stream_options present = %v, want omitted for a custom endpoint until enabled", streamOptions)
+	}
+}
+
+func TestDeepseekCircuitBreakerSingleProvider(t *testing.T) {
+	var calls, healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"message":"overloaded"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"恢复了"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewDeepseekProvider("test-key", "")
+	provider.SetEndpoint(server.URL)
+	provider.SetRetryPolicy(0, time.Millisecond, time.Millisecond)
+	provider.SetCircuitBreaker(2, 50*time.Millisecond)
+
+	// 只配置了一个提供商，被摘除后 LLMManager 仍会把请求交给它
+	manager := NewLLMManager()
+	manager.RegisterProvider("deepseek", provider)
+	manager.SetEjectionPolicy(1, time.Minute)
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []Message{{Role: "user", Content: "你好"}}
+	for i := 0; i < 2; i++ {
+		if _, err := manager.Chat(messages, nil); err == nil {
+			t.Fatalf("turn %d succeeded against a failing server", i)
+		}
+	}
+
+	// 熔断打开后不再发出请求，立即失败
+	start := time.Now()
+	if _, err := manager.Chat(messages, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("open circuit took %v to fail", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("server received %d requests, want 2", n)
+	}
+
+	// 冷却后放行一个试探请求，试探失败则重新打开
+	time.Sleep(60 * time.Millisecond)
+	if _, err := manager.Chat(messages, nil); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe err = %v, want the server error", err)
+	}
+	if _, err := manager.Chat(messages, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err after failed probe = %v, want ErrCircuitOpen", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("server received %d requests, want one probe", n)
+	}
+
+	// 试探成功后关闭
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		response, err := manager.Chat(messages, nil)
+		if err != nil || response.Content != "恢复了" {
+			t.Fatalf("after recovery: response = %+v, err %v", response, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind 表示提供商错误的类别
+type ErrorKind string
+
+// 定义提供商错误类别常量
+const (
+	ErrorKindAuth          ErrorKind = "auth"           // 认证失败或无权限
+	ErrorKindRateLimit     ErrorKind = "rate_limit"     // 请求被限流
+	ErrorKindServer        ErrorKind = "server"         // 服务端错误
+	ErrorKindBadRequest    ErrorKind = "bad_request"    // 请求参数错误
+	ErrorKindContextLength ErrorKind = "context_length" // 超出模型上下文长度
+	ErrorKindUnknown       ErrorKind = "unknown"        // 其他错误
+)
+
+// ErrCircuitOpen 表示提供商的熔断器处于打开状态，请求被直接拒绝
+var ErrCircuitOpen = NewLLMError("llm provider circuit breaker is open")
+
+// APIError 表示提供商 HTTP API 返回的非成功状态
+type APIError struct {
+	Provider   string
+	Kind       ErrorKind
+	StatusCode int
+	Message    string        // 从响应体中解析出的错误描述
+	Body       string        // 原始响应体
+	RetryAfter time.Duration // 限流时服务端建议的等待时间
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error response from API [%d] (%s): %s", e.StatusCode, e.Kind, e.Body)
+}
+
+// newAPIError 根据 HTTP 响应构造带类别的 API 错误
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Message:    extractErrorMessage(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		apiErr.Kind = ErrorKindAuth
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrorKindRateLimit
+	case resp.StatusCode >= 500:
+		apiErr.Kind = ErrorKindServer
+	case isContextLengthMessage(apiErr.Message):
+		apiErr.Kind = ErrorKindContextLength
+	case resp.StatusCode >= 400:
+		apiErr.Kind = ErrorKindBadRequest
+	default:
+		apiErr.Kind = ErrorKindUnknown
+	}
+
+	return apiErr
+}
+
+// extractErrorMessage 从 OpenAI 兼容格式的错误响应中提取错误描述
+func extractErrorMessage(body []byte) string {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error.Message != "" {
+		return payload.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// isContextLengthMessage 判断错误描述是否表示超出上下文长度
+func isContextLengthMessage(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "context length") ||
+		strings.Contains(message, "context_length") ||
+		strings.Contains(message, "maximum context")
+}
+
+// parseRetryAfter 解析 Retry-After 头（秒数或 HTTP 日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// IsRetryableError 判断错误是否值得重试或换一个提供商重试
+// 超时、连接失败、限流、服务端错误以及熔断打开都视为可重试
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind == ErrorKindRateLimit || apiErr.Kind == ErrorKindServer
+	}
+
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// ErrorKindOf 返回错误的类别，非 APIError 返回 ErrorKindUnknown
+func ErrorKindOf(err error) ErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	return ErrorKindUnknown
+}
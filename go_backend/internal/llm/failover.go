@@ -0,0 +1,221 @@
+package llm
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthChecker 是提供商可选实现的健康探测接口
+type HealthChecker interface {
+	// HealthCheck 以尽量低的成本确认提供商当前可用
+	HealthCheck() error
+}
+
+// ProviderStatus 表示一个 LLM 提供商的健康状态
+type ProviderStatus struct {
+	Name                string     `json:"name"`
+	Healthy             bool       `json:"healthy"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	EjectedUntil        *time.Time `json:"ejected_until,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`
+}
+
+// providerHealth 记录单个提供商的健康数据
+type providerHealth struct {
+	consecutiveFailures int
+	ejectedUntil        time.Time
+	lastError           string
+	lastCheck           time.Time
+}
+
+// healthTracker 跟踪各提供商的失败情况，并在连续失败后暂时摘除提供商
+type healthTracker struct {
+	mu               sync.Mutex
+	providers        map[string]*providerHealth
+	failureThreshold int           // 连续失败多少次后摘除
+	ejectDuration    time.Duration // 摘除持续时间
+	stop             chan struct{}
+}
+
+// newHealthTracker 创建一个新的健康跟踪器
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		providers:        make(map[string]*providerHealth),
+		failureThreshold: 3,
+		ejectDuration:    30 * time.Second,
+	}
+}
+
+// get 返回提供商的健康记录（调用方需持有锁）
+func (h *healthTracker) get(name string) *providerHealth {
+	ph, exists := h.providers[name]
+	if !exists {
+		ph = &providerHealth{}
+		h.providers[name] = ph
+	}
+	return ph
+}
+
+// record 根据一次请求的结果更新提供商健康状态
+// 只有可重试错误（超时、限流、服务端错误）才计入失败，请求参数错误不影响健康
+func (h *healthTracker) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph := h.get(name)
+	if err == nil {
+		ph.consecutiveFailures = 0
+		ph.lastError = ""
+		return
+	}
+	if !IsRetryableError(err) {
+		return
+	}
+
+	ph.consecutiveFailures++
+	ph.lastError = err.Error()
+	if ph.consecutiveFailures >= h.failureThreshold {
+		ph.ejectedUntil = time.Now().Add(h.ejectDuration)
+		log.Printf("[LLM] Provider %s ejected for %v after %d consecutive failures", name, h.ejectDuration, ph.consecutiveFailures)
+	}
+}
+
+// recordProbe 记录一次健康探测的结果
+func (h *healthTracker) recordProbe(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph := h.get(name)
+	ph.lastCheck = time.Now()
+	if err == nil {
+		if !ph.ejectedUntil.IsZero() {
+			log.Printf("[LLM] Provider %s passed health check, restored", name)
+		}
+		ph.consecutiveFailures = 0
+		ph.ejectedUntil = time.Time{}
+		ph.lastError = ""
+		return
+	}
+
+	ph.lastError = err.Error()
+	ph.ejectedUntil = time.Now().Add(h.ejectDuration)
+	log.Printf("[LLM] Provider %s failed health check, ejected for %v: %v", name, h.ejectDuration, err)
+}
+
+// isEjected 判断提供商当前是否被摘除
+func (h *healthTracker) isEjected(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph, exists := h.providers[name]
+	return exists && time.Now().Before(ph.ejectedUntil)
+}
+
+// SetEjectionPolicy 设置连续失败阈值和摘除时长
+func (lm *LLMManager) SetEjectionPolicy(failureThreshold int, ejectDuration time.Duration) {
+	lm.health.mu.Lock()
+	defer lm.health.mu.Unlock()
+
+	if failureThreshold > 0 {
+		lm.health.failureThreshold = failureThreshold
+	}
+	if ejectDuration > 0 {
+		lm.health.ejectDuration = ejectDuration
+	}
+}
+
+// StartHealthChecks 启动后台健康探测，周期性检查实现了 HealthChecker 的提供商
+func (lm *LLMManager) StartHealthChecks(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	lm.health.mu.Lock()
+	if lm.health.stop != nil {
+		lm.health.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	lm.health.stop = stop
+	lm.health.mu.Unlock()
+
+	log.Printf("[LLM] Starting health checks every %v", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				lm.runHealthChecks()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopHealthChecks 停止后台健康探测
+func (lm *LLMManager) StopHealthChecks() {
+	lm.health.mu.Lock()
+	defer lm.health.mu.Unlock()
+
+	if lm.health.stop != nil {
+		close(lm.health.stop)
+		lm.health.stop = nil
+	}
+}
+
+// runHealthChecks 对所有支持探测的提供商执行一次健康检查
+func (lm *LLMManager) runHealthChecks() {
+	lm.mutex.RLock()
+	checkers := make(map[string]HealthChecker)
+	for name, provider := range lm.providers {
+		if checker, ok := provider.(HealthChecker); ok {
+			checkers[name] = checker
+		}
+	}
+	lm.mutex.RUnlock()
+
+	for name, checker := range checkers {
+		lm.health.recordProbe(name, checker.HealthCheck())
+	}
+}
+
+// ProviderStatuses 返回所有已注册提供商的健康状态，按名称排序
+func (lm *LLMManager) ProviderStatuses() []ProviderStatus {
+	lm.mutex.RLock()
+	names := make([]string, 0, len(lm.providers))
+	for name := range lm.providers {
+		names = append(names, name)
+	}
+	lm.mutex.RUnlock()
+	sort.Strings(names)
+
+	lm.health.mu.Lock()
+	defer lm.health.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]ProviderStatus, 0, len(names))
+	for _, name := range names {
+		status := ProviderStatus{Name: name, Healthy: true}
+		if ph, exists := lm.health.providers[name]; exists {
+			status.ConsecutiveFailures = ph.consecutiveFailures
+			status.LastError = ph.lastError
+			if !ph.lastCheck.IsZero() {
+				lastCheck := ph.lastCheck
+				status.LastCheck = &lastCheck
+			}
+			if now.Before(ph.ejectedUntil) {
+				ejectedUntil := ph.ejectedUntil
+				status.Healthy = false
+				status.EjectedUntil = &ejectedUntil
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -24,10 +25,13 @@ func NewMockProvider(name string) *MockProvider {
 }
 
 // Chat 模拟非流式对话处理
-func (p *MockProvider) Chat(messages []Message, options map[string]interface{}) (*Response, error) {
+func (p *MockProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
 	if !p.initialized {
 		return nil, ErrNotInitialized
 	}
+	if err := options.Check("mock", p.Capabilities()); err != nil {
+		return nil, err
+	}
 	
 	// 记录收到的消息
 	log.Printf("[LLM:Mock] Received Chat request with %d messages", len(messages))
@@ -36,14 +40,26 @@ func (p *MockProvider) Chat(messages []Message, options map[string]interface{})
 	}
 	
 	// 选择最后一条用户消息
-	userMessage := ""
+	var userMessage Message
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
-			userMessage = messages[i].Content
+			userMessage = messages[i]
 			break
 		}
 	}
 	
+	// 需要看图时模拟调用拍照工具
+	if toolCall := mockToolCall(userMessage, options); toolCall != nil {
+		return &Response{
+			FinishReason: "tool_calls",
+			ToolCalls:    []ToolCall{*toolCall},
+			Metadata: map[string]interface{}{
+				"model":       p.name,
+				MetadataUsage: EstimateUsage(messages, ""),
+			},
+		}, nil
+	}
+	
 	// 生成模拟响应
 	responseContent := generateMockResponse(userMessage)
 	
@@ -54,28 +70,44 @@ func (p *MockProvider) Chat(messages []Message, options map[string]interface{})
 		Metadata: map[string]interface{}{
 			"model":        p.name,
 			"response_time": time.Now().Unix(),
+			MetadataUsage:   EstimateUsage(messages, responseContent),
 		},
 	}, nil
 }
 
 // StreamChat 模拟流式对话处理
-func (p *MockProvider) StreamChat(messages []Message, options map[string]interface{}, callback StreamCallback) error {
+func (p *MockProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
 	if !p.initialized {
 		return ErrNotInitialized
 	}
+	if err := options.Check("mock", p.Capabilities()); err != nil {
+		return err
+	}
 	
 	// 记录收到的消息
 	log.Printf("[LLM:Mock] Received StreamChat request with %d messages", len(messages))
 	
 	// 选择最后一条用户消息
-	userMessage := ""
+	var userMessage Message
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
-			userMessage = messages[i].Content
+			userMessage = messages[i]
 			break
 		}
 	}
 	
+	// 需要看图时模拟调用拍照工具
+	if toolCall := mockToolCall(userMessage, options); toolCall != nil {
+		return callback(&ResponseChunk{
+			IsFinal:      true,
+			FinishReason: "tool_calls",
+			ToolCalls:    []ToolCall{*toolCall},
+			Metadata: map[string]interface{}{
+				MetadataUsage: EstimateUsage(messages, ""),
+			},
+		})
+	}
+	
 	// 生成模拟响应
 	responseContent := generateMockResponse(userMessage)
 	
@@ -93,6 +125,9 @@ func (p *MockProvider) StreamChat(messages []Message, options map[string]interfa
 		
 		if isFinal {
 			responseChunk.FinishReason = "stop"
+			responseChunk.Metadata = map[string]interface{}{
+				MetadataUsage: EstimateUsage(messages, responseContent),
+			}
 		}
 		
 		// 调用回调函数处理块
@@ -107,6 +142,21 @@ func (p *MockProvider) StreamChat(messages []Message, options map[string]interfa
 	return nil
 }
 
+// Capabilities 返回模拟提供商支持的参数，接受所有参数但不会影响输出
+func (p *MockProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Temperature:     true,
+		TopP:            true,
+		MaxTokens:       true,
+		Penalties:       true,
+		Stop:            true,
+		Tools:           true,
+		User:            true,
+		Vision:          true,
+		ResponseFormats: []ResponseFormat{ResponseFormatText, ResponseFormatJSON},
+	}
+}
+
 // Initialize 初始化模拟 LLM 提供商
 func (p *MockProvider) Initialize() error {
 	log.Printf("[LLM:Mock] Initializing mock LLM provider: %s", p.name)
@@ -123,10 +173,48 @@ func (p *MockProvider) Cleanup() error {
 
 // 辅助函数
 
+// mockToolCall 在提供了 take_photo 工具且用户想让助手看东西时返回模拟的工具调用
+func mockToolCall(message Message, options *ChatOptions) *ToolCall {
+	if options == nil || message.HasImage() {
+		return nil
+	}
+	
+	hasTool := false
+	for _, tool := range options.Tools {
+		if tool.Function.Name == "take_photo" {
+			hasTool = true
+			break
+		}
+	}
+	if !hasTool {
+		return nil
+	}
+	
+	for _, keyword := range []string{"拍照", "看看", "看一下", "这是什么"} {
+		if strings.Contains(message.Content, keyword) {
+			arguments, _ := json.Marshal(map[string]string{"question": message.Content})
+			return &ToolCall{
+				ID:   fmt.Sprintf("call_mock_%d", time.Now().UnixNano()),
+				Type: "function",
+				Function: ToolCallFunction{
+					Name:      "take_photo",
+					Arguments: string(arguments),
+				},
+			}
+		}
+	}
+	return nil
+}
+
 // generateMockResponse 根据用户消息生成模拟响应
-func generateMockResponse(userMessage string) string {
+func generateMockResponse(message Message) string {
+	// 带图片的消息返回模拟的图片描述
+	if message.HasImage() {
+		return "我看到了你拍的照片。这是一个模拟的图片描述，实际部署时会由视觉模型识别图片内容。"
+	}
+	
 	// 如果消息中包含问候，返回问候
-	userMessage = strings.ToLower(userMessage)
+	userMessage := strings.ToLower(message.Content)
 	if strings.Contains(userMessage, "你好") || strings.Contains(userMessage, "hello") || strings.Contains(userMessage, "hi") {
 		return "你好！我是一个模拟的语音助手模型。我可以帮助你回答问题、提供信息或者与你聊天。有什么我可以帮助你的吗？"
 	}
@@ -175,6 +263,7 @@ func splitIntoChunks(text string, chunkSize int) []string {
 	return chunks
 }
 
+
 // truncateString 截断字符串，超过最大长度时添加省略号
 func truncateString(s string, maxLength int) string {
 	runes := []rune(s)
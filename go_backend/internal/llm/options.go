@@ -0,0 +1,270 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResponseFormat 表示模型输出格式
+type ResponseFormat string
+
+// 支持的输出格式
+const (
+	ResponseFormatText ResponseFormat = "text"
+	ResponseFormatJSON ResponseFormat = "json_object"
+)
+
+// Tool 表示可供模型调用的工具（OpenAI 兼容格式）
+type Tool struct {
+	Type     string       `json:"type"` // 目前只有 function
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 描述一个函数工具
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // JSON Schema
+}
+
+// ChatOptions 表示一次对话请求的可选参数
+// 指针字段为 nil 表示使用提供商的默认值，这样才能区分"未设置"和显式的 0
+type ChatOptions struct {
+	Temperature      *float64       `json:"temperature,omitempty"`       // 采样温度，0-2
+	TopP             *float64       `json:"top_p,omitempty"`             // 核采样概率，0-1
+	MaxTokens        *int           `json:"max_tokens,omitempty"`        // 最大输出令牌数
+	FrequencyPenalty *float64       `json:"frequency_penalty,omitempty"` // 频率惩罚，-2 到 2
+	PresencePenalty  *float64       `json:"presence_penalty,omitempty"`  // 存在惩罚，-2 到 2
+	Stop             []string       `json:"stop,omitempty"`              // 停止序列
+	Tools            []Tool         `json:"tools,omitempty"`             // 可调用的工具
+	ToolChoice       string         `json:"tool_choice,omitempty"`       // auto、none、required 或工具名称
+	ResponseFormat   ResponseFormat `json:"response_format,omitempty"`   // 输出格式
+	User             string         `json:"user,omitempty"`              // 终端用户标识，用于提供商侧的滥用监控
+}
+
+// Float 返回 v 的指针，便于设置 ChatOptions 的可选字段
+func Float(v float64) *float64 {
+	return &v
+}
+
+// Int 返回 v 的指针，便于设置 ChatOptions 的可选字段
+func Int(v int) *int {
+	return &v
+}
+
+// Validate 检查参数取值是否合法
+func (o *ChatOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	var problems []string
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		problems = append(problems, fmt.Sprintf("temperature %.2f out of range [0, 2]", *o.Temperature))
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		problems = append(problems, fmt.Sprintf("top_p %.2f out of range (0, 1]", *o.TopP))
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		problems = append(problems, fmt.Sprintf("max_tokens %d must be positive", *o.MaxTokens))
+	}
+	if o.FrequencyPenalty != nil && (*o.FrequencyPenalty < -2 || *o.FrequencyPenalty > 2) {
+		problems = append(problems, fmt.Sprintf("frequency_penalty %.2f out of range [-2, 2]", *o.FrequencyPenalty))
+	}
+	if o.PresencePenalty != nil && (*o.PresencePenalty < -2 || *o.PresencePenalty > 2) {
+		problems = append(problems, fmt.Sprintf("presence_penalty %.2f out of range [-2, 2]", *o.PresencePenalty))
+	}
+	for _, stop := range o.Stop {
+		if stop == "" {
+			problems = append(problems, "stop sequences must not be empty")
+			break
+		}
+	}
+
+	toolNames := make(map[string]bool)
+	for _, tool := range o.Tools {
+		if tool.Type != "function" {
+			problems = append(problems, fmt.Sprintf("unsupported tool type %q", tool.Type))
+		}
+		if tool.Function.Name == "" {
+			problems = append(problems, "tool function name is required")
+		}
+		toolNames[tool.Function.Name] = true
+	}
+	switch o.ToolChoice {
+	case "", "auto", "none":
+	case "required":
+		if len(o.Tools) == 0 {
+			problems = append(problems, "tool_choice required without tools")
+		}
+	default:
+		if !toolNames[o.ToolChoice] {
+			problems = append(problems, fmt.Sprintf("tool_choice %q does not match any tool", o.ToolChoice))
+		}
+	}
+
+	switch o.ResponseFormat {
+	case "", ResponseFormatText, ResponseFormatJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown response_format %q", o.ResponseFormat))
+	}
+
+	if len(problems) > 0 {
+		return &OptionsError{Problems: problems}
+	}
+	return nil
+}
+
+// Capabilities 描述提供商支持哪些请求参数
+type Capabilities struct {
+	Temperature      bool             `json:"temperature"`
+	TopP             bool             `json:"top_p"`
+	MaxTokens        bool             `json:"max_tokens"`
+	Penalties        bool             `json:"penalties"` // frequency_penalty 和 presence_penalty
+	Stop             bool             `json:"stop"`
+	Tools            bool             `json:"tools"`
+	User             bool             `json:"user"`
+	Vision           bool             `json:"vision"` // 是否接受图片输入
+	ResponseFormats  []ResponseFormat `json:"response_formats,omitempty"`
+	MaxStopSequences int              `json:"max_stop_sequences,omitempty"` // 0 表示不限制
+	MaxOutputTokens  int              `json:"max_output_tokens,omitempty"`  // 0 表示不限制
+}
+
+// union 返回两组能力的并集，数量上限取较宽松的一方（0 表示不限制）
+func (c Capabilities) union(other Capabilities) Capabilities {
+	result := Capabilities{
+		Temperature:      c.Temperature || other.Temperature,
+		TopP:             c.TopP || other.TopP,
+		MaxTokens:        c.MaxTokens || other.MaxTokens,
+		Penalties:        c.Penalties || other.Penalties,
+		Stop:             c.Stop || other.Stop,
+		Tools:            c.Tools || other.Tools,
+		User:             c.User || other.User,
+		Vision:           c.Vision || other.Vision,
+		MaxStopSequences: looserLimit(c.MaxStopSequences, other.MaxStopSequences),
+		MaxOutputTokens:  looserLimit(c.MaxOutputTokens, other.MaxOutputTokens),
+	}
+	seen := make(map[ResponseFormat]bool)
+	for _, format := range append(append([]ResponseFormat{}, c.ResponseFormats...), other.ResponseFormats...) {
+		if !seen[format] {
+			seen[format] = true
+			result.ResponseFormats = append(result.ResponseFormats, format)
+		}
+	}
+	return result
+}
+
+// looserLimit 返回两个上限中较宽松的一个，0 表示不限制
+func looserLimit(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Check 校验参数并确认提供商支持所有已设置的参数
+// 不支持的参数会作为错误返回，而不是被静默丢弃
+func (o *ChatOptions) Check(provider string, caps Capabilities) error {
+	if o == nil {
+		return nil
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	var unsupported []string
+	if o.Temperature != nil && !caps.Temperature {
+		unsupported = append(unsupported, "temperature")
+	}
+	if o.TopP != nil && !caps.TopP {
+		unsupported = append(unsupported, "top_p")
+	}
+	if o.MaxTokens != nil {
+		if !caps.MaxTokens {
+			unsupported = append(unsupported, "max_tokens")
+		} else if caps.MaxOutputTokens > 0 && *o.MaxTokens > caps.MaxOutputTokens {
+			unsupported = append(unsupported, fmt.Sprintf("max_tokens above %d", caps.MaxOutputTokens))
+		}
+	}
+	if (o.FrequencyPenalty != nil || o.PresencePenalty != nil) && !caps.Penalties {
+		unsupported = append(unsupported, "penalties")
+	}
+	if len(o.Stop) > 0 {
+		if !caps.Stop {
+			unsupported = append(unsupported, "stop")
+		} else if caps.MaxStopSequences > 0 && len(o.Stop) > caps.MaxStopSequences {
+			unsupported = append(unsupported, fmt.Sprintf("more than %d stop sequences", caps.MaxStopSequences))
+		}
+	}
+	if (len(o.Tools) > 0 || o.ToolChoice != "") && !caps.Tools {
+		unsupported = append(unsupported, "tools")
+	}
+	if o.User != "" && !caps.User {
+		unsupported = append(unsupported, "user")
+	}
+	if o.ResponseFormat != "" && o.ResponseFormat != ResponseFormatText {
+		supported := false
+		for _, format := range caps.ResponseFormats {
+			if format == o.ResponseFormat {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			unsupported = append(unsupported, "response_format "+string(o.ResponseFormat))
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return &UnsupportedOptionsError{Provider: provider, Options: unsupported}
+	}
+	return nil
+}
+
+// ParseChatOptions 从 JSON 解析对话参数，未知的键会报错而不是被忽略
+func ParseChatOptions(data []byte) (*ChatOptions, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var options ChatOptions
+	if err := decoder.Decode(&options); err != nil {
+		return nil, &OptionsError{Problems: []string{err.Error()}}
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// OptionsError 表示参数取值不合法
+type OptionsError struct {
+	Problems []string
+}
+
+// Error 实现 error 接口
+func (e *OptionsError) Error() string {
+	return "invalid chat options: " + strings.Join(e.Problems, "; ")
+}
+
+// UnsupportedOptionsError 表示提供商不支持某些已设置的参数
+type UnsupportedOptionsError struct {
+	Provider string
+	Options  []string
+}
+
+// Error 实现 error 接口
+func (e *UnsupportedOptionsError) Error() string {
+	return fmt.Sprintf("provider %s does not support: %s", e.Provider, strings.Join(e.Options, ", "))
+}
+
+// isUnsupported 判断错误是否表示提供商不支持请求中的参数，此时可以换一个提供商
+func isUnsupported(err error) bool {
+	var unsupported *UnsupportedOptionsError
+	return errors.As(err, &unsupported)
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// textOnlyProvider 是只支持基本参数的模拟提供商
+type textOnlyProvider struct {
+	*MockProvider
+	calls int
+}
+
+func (p *textOnlyProvider) Capabilities() Capabilities {
+	return Capabilities{Temperature: true, MaxTokens: true, Stop: true, MaxStopSequences: 4, ResponseFormats: []ResponseFormat{ResponseFormatText}}
+}
+
+func (p *textOnlyProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
+	p.calls++
+	if err := options.Check("text-only", p.Capabilities()); err != nil {
+		return nil, err
+	}
+	return p.MockProvider.Chat(messages, options)
+}
+
+func (p *textOnlyProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
+	p.calls++
+	if err := options.Check("text-only", p.Capabilities()); err != nil {
+		return err
+	}
+	return p.MockProvider.StreamChat(messages, options, callback)
+}
+
+func newTextOnlyManager(t *testing.T, fallback bool) (*LLMManager, *textOnlyProvider) {
+	t.Helper()
+
+	primary := &textOnlyProvider{MockProvider: NewMockProvider("主模型")}
+	manager := NewLLMManager()
+	manager.RegisterProvider("text-only", primary)
+	if fallback {
+		manager.RegisterProvider("mock", NewMockProvider("备用"))
+		if err := manager.SetFallbackChain("mock"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return manager, primary
+}
+
+func TestManagerCapabilitiesCoverChain(t *testing.T) {
+	manager, _ := newTextOnlyManager(t, false)
+	caps, err := manager.Capabilities()
+	if err != nil || caps.Tools || caps.MaxStopSequences != 4 {
+		t.Fatalf("single provider caps = %+v, err %v", caps, err)
+	}
+
+	manager, _ = newTextOnlyManager(t, true)
+	caps, err = manager.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Tools || !caps.Vision || !caps.TopP || caps.MaxStopSequences != 0 {
+		t.Errorf("chain caps = %+v, want the union with the mock provider", caps)
+	}
+	if !reflect.DeepEqual(caps.ResponseFormats, []ResponseFormat{ResponseFormatText, ResponseFormatJSON}) {
+		t.Errorf("response formats = %v", caps.ResponseFormats)
+	}
+}
+
+func TestManagerSkipsProviderWithoutSupport(t *testing.T) {
+	tools := &ChatOptions{Tools: []Tool{{Type: "function", Function: ToolFunction{Name: "take_photo"}}}}
+	messages := []Message{{Role: "user", Content: "你好"}}
+
+	manager, primary := newTextOnlyManager(t, true)
+	response, err := manager.Chat(messages, tools)
+	if err != nil || response.Metadata[MetadataProvider] != "mock" {
+		t.Fatalf("response = %+v, err %v, want the fallback to serve tools", response, err)
+	}
+
+	var provider interface{}
+	err = manager.StreamChat(messages, tools, func(chunk *ResponseChunk) error {
+		if chunk.IsFinal {
+			provider = chunk.Metadata[MetadataProvider]
+		}
+		return nil
+	})
+	if err != nil || provider != "mock" {
+		t.Errorf("stream provider = %v, err %v", provider, err)
+	}
+
+	// 不支持参数不算故障，不会导致提供商被摘除
+	if _, err := manager.Chat(messages, nil); err != nil || primary.calls != 3 {
+		t.Errorf("plain request: err %v, primary calls %d, want the primary provider", err, primary.calls)
+	}
+
+	// 整条链都不支持时返回不支持的错误
+	manager, _ = newTextOnlyManager(t, false)
+	_, err = manager.Chat(messages, tools)
+	var unsupported *UnsupportedOptionsError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("err = %v, want UnsupportedOptionsError", err)
+	}
+}
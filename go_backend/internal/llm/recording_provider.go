@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingProvider 包装一个真实的提供商，把每次请求和响应录制到脚本文件
+// 录制的文件可以直接交给 ScriptedProvider 离线回放
+type RecordingProvider struct {
+	name     string
+	provider Provider
+	path     string
+	mutex    sync.Mutex
+	script   *Script
+}
+
+// NewRecordingProvider 创建一个录制提供商，文件已存在时在原有内容后追加
+// 文件存在但无法读取或解析时返回错误，避免录制时覆盖已有的脚本
+func NewRecordingProvider(name string, provider Provider, path string) (*RecordingProvider, error) {
+	script, err := LoadScript(path)
+	if os.IsNotExist(err) {
+		script, err = &Script{Name: "recorded from " + name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading fixture %s: %w", path, err)
+	}
+	return &RecordingProvider{
+		name:     name,
+		provider: provider,
+		path:     path,
+		script:   script,
+	}, nil
+}
+
+// Chat 转发请求并录制完整响应
+func (p *RecordingProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
+	start := time.Now()
+	response, err := p.provider.Chat(messages, options)
+
+	reply := ScriptReply{FirstChunkDelayMs: int(time.Since(start) / time.Millisecond)}
+	if err != nil {
+		reply.Error = recordError(err)
+	} else {
+		reply.Response = response.Content
+		reply.FinishReason = response.FinishReason
+		reply.ToolCalls = recordToolCalls(response.ToolCalls)
+		if usage, ok := UsageFromMetadata(response.Metadata); ok {
+			reply.Usage = &usage
+		}
+		if reasoning, ok := response.Metadata[MetadataReasoningContent].(string); ok {
+			reply.Reasoning = reasoning
+		}
+	}
+	p.record(messages, reply)
+	return response, err
+}
+
+// StreamChat 转发流式请求，录制分块内容和时间间隔
+func (p *RecordingProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
+	var (
+		reply     ScriptReply
+		reasoning strings.Builder
+		start     = time.Now()
+		last      time.Time
+		gaps      time.Duration
+	)
+
+	err := p.provider.StreamChat(messages, options, func(chunk *ResponseChunk) error {
+		if text, ok := chunk.Metadata[MetadataReasoningContent].(string); ok {
+			reasoning.WriteString(text)
+		}
+		if chunk.Content != "" {
+			now := time.Now()
+			if last.IsZero() {
+				reply.FirstChunkDelayMs = int(now.Sub(start) / time.Millisecond)
+			} else {
+				gaps += now.Sub(last)
+			}
+			last = now
+			reply.Chunks = append(reply.Chunks, chunk.Content)
+		}
+		if chunk.IsFinal {
+			reply.FinishReason = chunk.FinishReason
+			reply.ToolCalls = append(reply.ToolCalls, recordToolCalls(chunk.ToolCalls)...)
+			if usage, ok := UsageFromMetadata(chunk.Metadata); ok {
+				reply.Usage = &usage
+			}
+		}
+		return callback(chunk)
+	})
+
+	if len(reply.Chunks) > 1 {
+		reply.ChunkDelayMs = int(gaps / time.Duration(len(reply.Chunks)-1) / time.Millisecond)
+	}
+	reply.Reasoning = reasoning.String()
+	if err != nil {
+		reply.Error = recordError(err)
+		reply.Error.AfterChunks = len(reply.Chunks)
+	}
+	p.record(messages, reply)
+	return err
+}
+
+// record 把一次请求和响应追加到脚本并写入文件
+// 同一问题录制多次时每条规则只命中一次，回放时按录制顺序依次返回
+func (p *RecordingProvider) record(messages []Message, reply ScriptReply) {
+	var userText string
+	request := make([]ScriptMessage, 0, len(messages))
+	for _, message := range messages {
+		text := message.TextOnly().Content
+		request = append(request, ScriptMessage{Role: message.Role, Content: text})
+		if message.Role == "user" {
+			userText = text
+		}
+	}
+
+	if reply.Error != nil {
+		// 错误只回放一次，下一次录制的同一问题会返回正常结果
+		reply.Error.Times = 1
+	}
+	rule := ScriptRule{
+		Match:       "^" + regexp.QuoteMeta(userText) + "$",
+		Times:       1,
+		ScriptReply: reply,
+		Request:     request,
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.script.Rules = append(p.script.Rules, rule)
+	if err := p.script.Save(p.path); err != nil {
+		log.Printf("[LLM:Recording] Error saving fixture %s: %v", p.path, err)
+		return
+	}
+	log.Printf("[LLM:Recording] Recorded exchange %d from %s to %s", len(p.script.Rules), p.name, p.path)
+}
+
+// recordError 把提供商错误转换为可回放的脚本错误
+func recordError(err error) *ScriptError {
+	scriptErr := &ScriptError{
+		Kind:    ErrorKindOf(err),
+		Message: err.Error(),
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		scriptErr.Status = apiErr.StatusCode
+	} else if IsRetryableError(err) {
+		scriptErr.Kind = ErrorKindTimeout
+	}
+	return scriptErr
+}
+
+// recordToolCalls 把工具调用转换为脚本格式
+func recordToolCalls(calls []ToolCall) []ScriptToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	recorded := make([]ScriptToolCall, 0, len(calls))
+	for _, call := range calls {
+		recorded = append(recorded, ScriptToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return recorded
+}
+
+// HealthCheck 转发被录制提供商的健康探测
+func (p *RecordingProvider) HealthCheck() error {
+	if checker, ok := p.provider.(HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Capabilities 返回被录制提供商支持的参数
+func (p *RecordingProvider) Capabilities() Capabilities {
+	return p.provider.Capabilities()
+}
+
+// Initialize 初始化被录制的提供商
+func (p *RecordingProvider) Initialize() error {
+	if p.provider == nil {
+		return fmt.Errorf("recorded provider is required")
+	}
+	log.Printf("[LLM:Recording] Recording %s to %s", p.name, p.path)
+	return p.provider.Initialize()
+}
+
+// Cleanup 清理被录制的提供商资源
+func (p *RecordingProvider) Cleanup() error {
+	return p.provider.Cleanup()
+}
@@ -0,0 +1,447 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Script 表示一组脚本化的对话，用于可复现的会话测试
+// 可以手写，也可以由 RecordingProvider 录制真实提供商的请求和响应生成
+type Script struct {
+	Name    string       `json:"name,omitempty" yaml:"name,omitempty"`
+	Rules   []ScriptRule `json:"rules" yaml:"rules"`
+	Default *ScriptReply `json:"default,omitempty" yaml:"default,omitempty"` // 没有规则命中时的回复，为空时返回错误
+}
+
+// ScriptRule 表示一条脚本规则：最后一条用户消息匹配 Match 时返回 Reply
+type ScriptRule struct {
+	Match  string `json:"match" yaml:"match"`                       // 匹配最后一条用户消息的正则
+	System string `json:"system,omitempty" yaml:"system,omitempty"` // 可选，匹配第一条系统消息的正则，用于区分主对话和意图识别等请求
+	Times  int    `json:"times,omitempty" yaml:"times,omitempty"`   // 规则最多命中的次数，0 表示不限
+
+	ScriptReply `yaml:",inline"`
+
+	// Request 是录制时的原始请求，仅供查阅，回放时不使用
+	Request []ScriptMessage `json:"request,omitempty" yaml:"request,omitempty"`
+
+	matchRe  *regexp.Regexp
+	systemRe *regexp.Regexp
+}
+
+// ScriptReply 表示脚本规则的回复
+type ScriptReply struct {
+	Response          string           `json:"response,omitempty" yaml:"response,omitempty"`
+	Chunks            []string         `json:"chunks,omitempty" yaml:"chunks,omitempty"`         // 流式输出的分块，为空时按 ChunkSize 切分 Response
+	ChunkSize         int              `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty"` // 自动切分的每块字数，默认 10
+	FirstChunkDelayMs int              `json:"first_chunk_delay_ms,omitempty" yaml:"first_chunk_delay_ms,omitempty"`
+	ChunkDelayMs      int              `json:"chunk_delay_ms,omitempty" yaml:"chunk_delay_ms,omitempty"`
+	Reasoning         string           `json:"reasoning,omitempty" yaml:"reasoning,omitempty"` // 推理内容，在正文之前作为元数据输出
+	ToolCalls         []ScriptToolCall `json:"tool_calls,omitempty" yaml:"tool_calls,omitempty"`
+	FinishReason      string           `json:"finish_reason,omitempty" yaml:"finish_reason,omitempty"`
+	Usage             *Usage           `json:"usage,omitempty" yaml:"usage,omitempty"` // 为空时按字数估算
+	Error             *ScriptError     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ScriptToolCall 表示脚本中的一个工具调用，Arguments 可以是 JSON 字符串或对象
+type ScriptToolCall struct {
+	ID        string      `json:"id,omitempty" yaml:"id,omitempty"`
+	Name      string      `json:"name" yaml:"name"`
+	Arguments interface{} `json:"arguments,omitempty" yaml:"arguments,omitempty"`
+}
+
+// ScriptError 表示注入的错误
+type ScriptError struct {
+	Kind        ErrorKind `json:"kind" yaml:"kind"`                                     // 错误类别，另支持 timeout
+	Status      int       `json:"status,omitempty" yaml:"status,omitempty"`             // HTTP 状态码，为空时按类别推断
+	Message     string    `json:"message,omitempty" yaml:"message,omitempty"`           // 错误描述
+	Times       int       `json:"times,omitempty" yaml:"times,omitempty"`               // 前几次命中时返回错误，之后正常回复；0 表示总是返回错误
+	AfterChunks int       `json:"after_chunks,omitempty" yaml:"after_chunks,omitempty"` // 流式输出若干块之后再返回错误
+}
+
+// ScriptMessage 表示录制的一条请求消息
+type ScriptMessage struct {
+	Role    string `json:"role" yaml:"role"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// ErrorKindTimeout 是脚本中表示请求超时的错误类别
+const ErrorKindTimeout ErrorKind = "timeout"
+
+// LoadScript 从 YAML 或 JSON 文件加载脚本，按扩展名区分格式
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script Script
+	if isYAMLPath(path) {
+		err = yaml.Unmarshal(data, &script)
+	} else {
+		err = json.Unmarshal(data, &script)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing script %s: %w", path, err)
+	}
+	if err := script.compile(); err != nil {
+		return nil, fmt.Errorf("invalid script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// Save 把脚本写入文件，先写临时文件再重命名
+func (s *Script) Save(path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isYAMLPath(path) {
+		data, err = yaml.Marshal(s)
+	} else {
+		data, err = json.MarshalIndent(s, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// compile 编译所有规则的正则
+func (s *Script) compile() error {
+	for i := range s.Rules {
+		rule := &s.Rules[i]
+		var err error
+		if rule.matchRe, err = regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		if rule.System != "" {
+			if rule.systemRe, err = regexp.Compile(rule.System); err != nil {
+				return fmt.Errorf("rule %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isYAMLPath 判断文件是否为 YAML 格式
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// ScriptedProvider 按脚本回复的 LLM 提供商，输出完全确定，用于会话测试和离线回放
+type ScriptedProvider struct {
+	script      *Script
+	mutex       sync.Mutex
+	hits        map[int]int // 规则序号 -> 已命中次数
+	initialized bool
+}
+
+// NewScriptedProvider 创建一个新的脚本化 LLM 提供商
+func NewScriptedProvider(script *Script) *ScriptedProvider {
+	return &ScriptedProvider{
+		script: script,
+		hits:   make(map[int]int),
+	}
+}
+
+// Chat 返回命中规则的完整回复
+func (p *ScriptedProvider) Chat(messages []Message, options *ChatOptions) (*Response, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+	if err := options.Check("scripted", p.Capabilities()); err != nil {
+		return nil, err
+	}
+
+	reply, hit, err := p.match(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	time.Sleep(time.Duration(reply.FirstChunkDelayMs) * time.Millisecond)
+	if reply.Error != nil && reply.Error.shouldFail(hit) {
+		return nil, reply.Error.err()
+	}
+
+	content := reply.content()
+	response := &Response{
+		Content:      content,
+		FinishReason: reply.finishReason(),
+		ToolCalls:    reply.toolCalls(),
+		Metadata: map[string]interface{}{
+			MetadataUsage: reply.usage(messages, content),
+		},
+	}
+	if reply.Reasoning != "" {
+		response.Metadata[MetadataReasoningContent] = reply.Reasoning
+	}
+	return response, nil
+}
+
+// StreamChat 按脚本的分块和延迟流式输出回复
+func (p *ScriptedProvider) StreamChat(messages []Message, options *ChatOptions, callback StreamCallback) error {
+	if !p.initialized {
+		return ErrNotInitialized
+	}
+	if err := options.Check("scripted", p.Capabilities()); err != nil {
+		return err
+	}
+
+	reply, hit, err := p.match(messages)
+	if err != nil {
+		return err
+	}
+	failing := reply.Error != nil && reply.Error.shouldFail(hit)
+
+	time.Sleep(time.Duration(reply.FirstChunkDelayMs) * time.Millisecond)
+	if failing && reply.Error.AfterChunks == 0 {
+		return reply.Error.err()
+	}
+
+	if reply.Reasoning != "" {
+		if err := callback(&ResponseChunk{
+			Metadata: map[string]interface{}{MetadataReasoningContent: reply.Reasoning},
+		}); err != nil {
+			return fmt.Errorf("error in callback: %w", err)
+		}
+	}
+
+	chunks := reply.chunks()
+	for i, chunk := range chunks {
+		if failing && i == reply.Error.AfterChunks {
+			return reply.Error.err()
+		}
+		if i > 0 {
+			time.Sleep(time.Duration(reply.ChunkDelayMs) * time.Millisecond)
+		}
+		if err := callback(&ResponseChunk{Content: chunk}); err != nil {
+			return fmt.Errorf("error in callback: %w", err)
+		}
+	}
+	if failing {
+		return reply.Error.err()
+	}
+
+	return callback(&ResponseChunk{
+		IsFinal:      true,
+		FinishReason: reply.finishReason(),
+		ToolCalls:    reply.toolCalls(),
+		Metadata: map[string]interface{}{
+			MetadataUsage: reply.usage(messages, strings.Join(chunks, "")),
+		},
+	})
+}
+
+// match 找到第一条命中的规则，返回回复和该规则已命中的次数（含本次）
+func (p *ScriptedProvider) match(messages []Message) (*ScriptReply, int, error) {
+	var userText, systemText string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			userText = messages[i].Content
+			break
+		}
+	}
+	for _, message := range messages {
+		if message.Role == "system" {
+			systemText = message.Content
+			break
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i := range p.script.Rules {
+		rule := &p.script.Rules[i]
+		if rule.Times > 0 && p.hits[i] >= rule.Times {
+			continue
+		}
+		if !rule.matchRe.MatchString(userText) {
+			continue
+		}
+		if rule.systemRe != nil && !rule.systemRe.MatchString(systemText) {
+			continue
+		}
+		p.hits[i]++
+		log.Printf("[LLM:Scripted] Rule %d matched: %s", i, truncateString(userText, 50))
+		return &rule.ScriptReply, p.hits[i], nil
+	}
+
+	if p.script.Default != nil {
+		p.hits[-1]++
+		return p.script.Default, p.hits[-1], nil
+	}
+	return nil, 0, NewLLMError(fmt.Sprintf("no scripted response matches %q", userText))
+}
+
+// content 返回回复的完整文本
+func (r *ScriptReply) content() string {
+	if len(r.Chunks) > 0 {
+		return strings.Join(r.Chunks, "")
+	}
+	return r.Response
+}
+
+// chunks 返回流式输出的分块
+func (r *ScriptReply) chunks() []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	if r.Response == "" {
+		return nil
+	}
+	size := r.ChunkSize
+	if size <= 0 {
+		size = 10
+	}
+	return splitIntoChunks(r.Response, size)
+}
+
+// finishReason 返回结束原因，有工具调用时默认为 tool_calls
+func (r *ScriptReply) finishReason() string {
+	switch {
+	case r.FinishReason != "":
+		return r.FinishReason
+	case len(r.ToolCalls) > 0:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// toolCalls 把脚本中的工具调用转换为 ToolCall
+func (r *ScriptReply) toolCalls() []ToolCall {
+	if len(r.ToolCalls) == 0 {
+		return nil
+	}
+	calls := make([]ToolCall, 0, len(r.ToolCalls))
+	for i, call := range r.ToolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_scripted_%d", i)
+		}
+		arguments, ok := call.Arguments.(string)
+		if !ok && call.Arguments != nil {
+			data, _ := json.Marshal(call.Arguments)
+			arguments = string(data)
+		}
+		calls = append(calls, ToolCall{
+			ID:   id,
+			Type: "function",
+			Function: ToolCallFunction{
+				Name:      call.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return calls
+}
+
+// usage 返回脚本指定的用量，未指定时按字数估算
+func (r *ScriptReply) usage(messages []Message, content string) Usage {
+	if r.Usage != nil {
+		return *r.Usage
+	}
+	return EstimateUsage(messages, content)
+}
+
+// shouldFail 判断第 hit 次命中时是否返回错误
+func (e *ScriptError) shouldFail(hit int) bool {
+	return e.Times == 0 || hit <= e.Times
+}
+
+// err 构造注入的错误，类别与真实提供商返回的错误一致，便于测试重试和切换
+func (e *ScriptError) err() error {
+	message := e.Message
+	if message == "" {
+		message = "scripted " + string(e.Kind) + " error"
+	}
+	if e.Kind == ErrorKindTimeout {
+		return fmt.Errorf("%s: %w", message, context.DeadlineExceeded)
+	}
+
+	status := e.Status
+	if status == 0 {
+		switch e.Kind {
+		case ErrorKindAuth:
+			status = 401
+		case ErrorKindRateLimit:
+			status = 429
+		case ErrorKindServer:
+			status = 500
+		default:
+			status = 400
+		}
+	}
+	kind := e.Kind
+	if kind == "" {
+		kind = ErrorKindUnknown
+	}
+	return &APIError{
+		Provider:   "scripted",
+		Kind:       kind,
+		StatusCode: status,
+		Message:    message,
+		Body:       message,
+	}
+}
+
+// Capabilities 返回脚本化提供商支持的参数，接受所有参数但不会影响输出
+func (p *ScriptedProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Temperature:     true,
+		TopP:            true,
+		MaxTokens:       true,
+		Penalties:       true,
+		Stop:            true,
+		Tools:           true,
+		User:            true,
+		Vision:          true,
+		ResponseFormats: []ResponseFormat{ResponseFormatText, ResponseFormatJSON},
+	}
+}
+
+// Initialize 初始化脚本化提供商，并重置规则的命中次数
+func (p *ScriptedProvider) Initialize() error {
+	if p.script == nil {
+		return fmt.Errorf("script is required")
+	}
+	if err := p.script.compile(); err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	p.hits = make(map[int]int)
+	p.mutex.Unlock()
+
+	log.Printf("[LLM:Scripted] Initializing scripted LLM provider with %d rules", len(p.script.Rules))
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理脚本化提供商资源
+func (p *ScriptedProvider) Cleanup() error {
+	p.initialized = false
+	return nil
+}
@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newScriptedManager 用脚本文件创建一个已初始化的 LLMManager，backup 不为空时作为备用提供商
+func newScriptedManager(t *testing.T, path string, backup *Script) *LLMManager {
+	t.Helper()
+
+	script, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("LoadScript: %v", err)
+	}
+	manager := NewLLMManager()
+	manager.RegisterProvider("scripted", NewScriptedProvider(script))
+	if backup != nil {
+		manager.RegisterProvider("backup", NewScriptedProvider(backup))
+		if err := manager.SetFallbackChain("backup"); err != nil {
+			t.Fatalf("SetFallbackChain: %v", err)
+		}
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return manager
+}
+
+// exchange 是一次流式对话的结果
+type exchange struct {
+	chunks    []string
+	toolCalls []ToolCall
+	finish    string
+	usage     Usage
+	provider  interface{}
+	err       error
+}
+
+// streamTurn 以流式方式发送一轮对话并收集结果
+func streamTurn(manager *LLMManager, system, user string) exchange {
+	messages := []Message{{Role: "system", Content: system}, {Role: "user", Content: user}}
+
+	var result exchange
+	result.err = manager.StreamChat(messages, nil, func(chunk *ResponseChunk) error {
+		if chunk.Content != "" {
+			result.chunks = append(result.chunks, chunk.Content)
+		}
+		if chunk.IsFinal {
+			result.toolCalls = chunk.ToolCalls
+			result.finish = chunk.FinishReason
+			result.usage, _ = UsageFromMetadata(chunk.Metadata)
+			result.provider = chunk.Metadata[MetadataProvider]
+		}
+		return nil
+	})
+	return result
+}
+
+func TestScriptedConversation(t *testing.T) {
+	backup := &Script{Rules: []ScriptRule{{Match: ".", ScriptReply: ScriptReply{Response: "备用回答"}}}}
+	manager := newScriptedManager(t, "testdata/weather.yaml", backup)
+
+	// 意图识别请求按系统提示区分，不会命中主对话的规则
+	intent, err := manager.Chat([]Message{
+		{Role: "system", Content: "你是意图识别助手"},
+		{Role: "user", Content: "北京天气怎么样"},
+	}, nil)
+	if err != nil || intent.Content != `{"intent":"none"}` {
+		t.Fatalf("intent reply = %+v, %v", intent, err)
+	}
+
+	turn := streamTurn(manager, "你是语音助手", "北京今天天气怎么样")
+	if turn.err != nil {
+		t.Fatalf("StreamChat: %v", turn.err)
+	}
+	if !reflect.DeepEqual(turn.chunks, []string{"北京今天晴，", "气温二十五度。"}) {
+		t.Errorf("chunks = %q", turn.chunks)
+	}
+	if turn.usage != (Usage{PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}) {
+		t.Errorf("usage = %+v", turn.usage)
+	}
+	if turn.provider != "scripted" || turn.finish != "stop" {
+		t.Errorf("provider = %v finish = %q", turn.provider, turn.finish)
+	}
+
+	turn = streamTurn(manager, "你是语音助手", "这是什么")
+	if turn.finish != "tool_calls" || len(turn.toolCalls) != 1 {
+		t.Fatalf("tool call turn = %+v", turn)
+	}
+	if call := turn.toolCalls[0].Function; call.Name != "take_photo" || call.Arguments != `{"question":"这是什么"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+
+	// 脚本注入的服务端错误只出现一次：第一次切换到备用提供商，第二次由脚本正常回答
+	turn = streamTurn(manager, "你是语音助手", "再说一遍")
+	if turn.err != nil || turn.provider != "backup" || strings.Join(turn.chunks, "") != "备用回答" {
+		t.Errorf("failover turn = %+v", turn)
+	}
+	turn = streamTurn(manager, "你是语音助手", "再说一遍")
+	if turn.err != nil || turn.provider != "scripted" || strings.Join(turn.chunks, "") != "好的，北京今天晴。" {
+		t.Errorf("recovered turn = %+v", turn)
+	}
+
+	turn = streamTurn(manager, "你是语音助手", "讲个笑话")
+	if strings.Join(turn.chunks, "") != "我不太明白。" {
+		t.Errorf("default reply = %q", turn.chunks)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	source, err := LoadScript("testdata/weather.yaml")
+	if err != nil {
+		t.Fatalf("LoadScript: %v", err)
+	}
+	fixture := filepath.Join(t.TempDir(), "recorded.yaml")
+	recorder, err := NewRecordingProvider("scripted", NewScriptedProvider(source), fixture)
+	if err != nil {
+		t.Fatalf("NewRecordingProvider: %v", err)
+	}
+
+	recording := NewLLMManager()
+	recording.RegisterProvider("recorder", recorder)
+	if err := recording.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	questions := []string{"北京今天天气怎么样", "这是什么", "再说一遍", "再说一遍"}
+	var recorded []exchange
+	for _, question := range questions {
+		recorded = append(recorded, streamTurn(recording, "你是语音助手", question))
+	}
+	if recorded[2].err == nil || recorded[3].err != nil {
+		t.Fatalf("expected the injected error once, got %v then %v", recorded[2].err, recorded[3].err)
+	}
+
+	replay := newScriptedManager(t, fixture, nil)
+	for i, question := range questions {
+		got := streamTurn(replay, "你是语音助手", question)
+		want := recorded[i]
+		if (got.err == nil) != (want.err == nil) {
+			t.Errorf("%s: replay err = %v, recorded err = %v", question, got.err, want.err)
+			continue
+		}
+		if ErrorKindOf(got.err) != ErrorKindOf(want.err) {
+			t.Errorf("%s: replay error kind = %s, recorded %s", question, ErrorKindOf(got.err), ErrorKindOf(want.err))
+		}
+		if !reflect.DeepEqual(got.chunks, want.chunks) || got.finish != want.finish || got.usage != want.usage {
+			t.Errorf("%s: replay = %+v, recorded = %+v", question, got, want)
+		}
+		if !reflect.DeepEqual(got.toolCalls, want.toolCalls) {
+			t.Errorf("%s: replay tool calls = %+v, recorded %+v", question, got.toolCalls, want.toolCalls)
+		}
+	}
+}
+
+func TestRecordingProviderFixtureErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// 文件不存在时从空脚本开始
+	recorder, err := NewRecordingProvider("mock", NewMockProvider(""), filepath.Join(dir, "new.yaml"))
+	if err != nil || len(recorder.script.Rules) != 0 {
+		t.Fatalf("missing fixture: recorder = %+v, err = %v", recorder, err)
+	}
+
+	// 已有文件损坏时返回错误，且不改动文件
+	corrupt := filepath.Join(dir, "corrupt.yaml")
+	content := []byte("rules: [this is: not: valid")
+	if err := os.WriteFile(corrupt, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRecordingProvider("mock", NewMockProvider(""), corrupt); err == nil {
+		t.Fatal("corrupt fixture accepted, want error")
+	}
+	if data, _ := os.ReadFile(corrupt); string(data) != string(content) {
+		t.Errorf("corrupt fixture was modified: %q", data)
+	}
+
+	// 无法读取的路径（这里是目录）同样返回错误
+	if _, err := NewRecordingProvider("mock", NewMockProvider(""), dir); err == nil {
+		t.Error("unreadable fixture accepted, want error")
+	}
+}
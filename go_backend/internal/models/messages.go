@@ -14,6 +14,8 @@ const (
 	TypeText           MessageType = "text"
 	TypeTTS            MessageType = "tts"
 	TypeError          MessageType = "error"
+	TypeIoT            MessageType = "iot"
+	TypeLLM            MessageType = "llm"
 )
 
 // ListenState 定义会话中的状态类型
@@ -89,4 +91,30 @@ type TTSMessage struct {
 // SimpleMessage 定义了没有附加数据的简单消息
 type SimpleMessage struct {
 	Type MessageType `json:"type"`
+}
+
+// EmotionMessage 定义了控制设备表情显示的消息
+type EmotionMessage struct {
+	Type    MessageType `json:"type"`
+	Text    string      `json:"text"`    // 代表表情符号
+	Emotion string      `json:"emotion"` // 固件支持的情绪名称
+}
+
+// IoTCommand 定义了发送给设备的 IoT 控制命令
+type IoTCommand struct {
+	Name       string                 `json:"name"`                 // 设备上的物件名称，如 Speaker
+	Method     string                 `json:"method"`               // 方法名称，如 SetVolume
+	Parameters map[string]interface{} `json:"parameters,omitempty"` // 方法参数
+}
+
+// IoTCommandMessage 定义了服务器下发的 IoT 命令消息
+type IoTCommandMessage struct {
+	Type     MessageType  `json:"type"`
+	Commands []IoTCommand `json:"commands"`
+}
+
+// IoTState 定义了设备上报的单个物件状态
+type IoTState struct {
+	Name  string                 `json:"name"`
+	State map[string]interface{} `json:"state"`
 } 
\ No newline at end of file
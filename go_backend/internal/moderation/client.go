@@ -0,0 +1,208 @@
+// Package moderation 对用户输入和模型输出做内容安全检查
+//
+// 很多设备由儿童使用，识别出的用户文本在交给模型之前、模型的回复在送入 TTS 之前
+// 都会经过审核链。命中的内容按角色的策略拦截、改写或替换，并上报到 MQTT 供家长查看。
+package moderation
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Category 表示违规内容的类别
+type Category string
+
+// 定义内容类别常量
+const (
+	CategoryViolence  Category = "violence"  // 暴力、血腥
+	CategorySexual    Category = "sexual"    // 色情、性暗示
+	CategorySelfHarm  Category = "self_harm" // 自残、自杀
+	CategoryHate      Category = "hate"      // 歧视、仇恨
+	CategoryDrugs     Category = "drugs"     // 毒品、烟酒赌博
+	CategoryProfanity Category = "profanity" // 脏话、辱骂
+	CategoryPrivacy   Category = "privacy"   // 索要住址、电话等个人信息
+)
+
+// Verdict 表示一次审核结果
+type Verdict struct {
+	Flagged    bool       `json:"flagged"`
+	Categories []Category `json:"categories,omitempty"`
+	Matches    []string   `json:"matches,omitempty"` // 命中的原文片段，可用于改写
+	Provider   string     `json:"provider,omitempty"`
+
+	// LLMMetadata 是审核过程中各次大模型请求的响应元数据（含令牌用量），供调用方统计用量
+	LLMMetadata []map[string]interface{} `json:"-"`
+}
+
+// Provider 表示内容审核服务提供商接口
+type Provider interface {
+	// Check 检查一段文本，未命中时返回 Flagged 为 false 的结果
+	Check(text string) (*Verdict, error)
+
+	// Initialize 初始化内容审核提供商
+	Initialize() error
+
+	// Cleanup 清理资源
+	Cleanup() error
+}
+
+// Publisher 是上报审核事件所需的最小发布接口，mqtt.Client 满足该接口
+type Publisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) error
+}
+
+// Event 表示一次被拦截或改写的审核事件
+type Event struct {
+	DeviceID   string     `json:"device_id"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Direction  string     `json:"direction"` // input 表示用户输入，output 表示模型输出
+	Persona    string     `json:"persona,omitempty"`
+	Action     Action     `json:"action"`
+	Categories []Category `json:"categories,omitempty"`
+	Provider   string     `json:"provider,omitempty"`
+	Text       string     `json:"text"`
+	Time       time.Time  `json:"time"`
+}
+
+// 审核方向
+const (
+	DirectionInput  = "input"
+	DirectionOutput = "output"
+)
+
+// ModerationManager 按顺序调用多个审核提供商
+// 第一个判定违规的提供商结果生效，便宜的提供商应排在前面
+type ModerationManager struct {
+	providers   map[string]Provider
+	chain       []string
+	mutex       sync.RWMutex
+	initialized bool
+	publisher   Publisher
+	topic       string
+}
+
+// NewModerationManager 创建一个新的内容审核管理器
+func NewModerationManager() *ModerationManager {
+	return &ModerationManager{
+		providers: make(map[string]Provider),
+	}
+}
+
+// RegisterProvider 注册一个审核提供商，并追加到审核链末尾
+func (mm *ModerationManager) RegisterProvider(name string, provider Provider) {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	if _, exists := mm.providers[name]; !exists {
+		mm.chain = append(mm.chain, name)
+	}
+	mm.providers[name] = provider
+
+	log.Printf("[Moderation] Registered provider: %s", name)
+}
+
+// SetPublisher 设置审核事件的上报目标，事件发布到 topic/<设备ID>
+func (mm *ModerationManager) SetPublisher(publisher Publisher, topic string) {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	mm.publisher = publisher
+	mm.topic = topic
+}
+
+// Initialize 初始化所有审核提供商
+func (mm *ModerationManager) Initialize() error {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	for name, provider := range mm.providers {
+		if err := provider.Initialize(); err != nil {
+			log.Printf("[Moderation] Failed to initialize provider %s: %v", name, err)
+			return err
+		}
+	}
+
+	mm.initialized = true
+	return nil
+}
+
+// Check 依次调用审核链上的提供商，出错的提供商会被跳过（宽松失败，避免审核服务故障导致设备无法使用）
+func (mm *ModerationManager) Check(text string) (*Verdict, error) {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
+	if !mm.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	// 放行的提供商也可能调用过大模型，它们的用量合并到最终结果中
+	var spent []map[string]interface{}
+	for _, name := range mm.chain {
+		verdict, err := mm.providers[name].Check(text)
+		if err != nil {
+			log.Printf("[Moderation] Provider %s failed: %v", name, err)
+			continue
+		}
+		if verdict == nil {
+			continue
+		}
+		spent = append(spent, verdict.LLMMetadata...)
+		if verdict.Flagged {
+			if verdict.Provider == "" {
+				verdict.Provider = name
+			}
+			verdict.LLMMetadata = spent
+			return verdict, nil
+		}
+	}
+
+	return &Verdict{LLMMetadata: spent}, nil
+}
+
+// Report 记录审核事件并发布到 MQTT
+func (mm *ModerationManager) Report(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	log.Printf("[Moderation] %s %s content from %s (%v via %s)", event.Action, event.Direction, event.DeviceID, event.Categories, event.Provider)
+
+	mm.mutex.RLock()
+	publisher, topic := mm.publisher, mm.topic
+	mm.mutex.RUnlock()
+
+	if publisher == nil || topic == "" {
+		return
+	}
+
+	deviceID := event.DeviceID
+	if deviceID == "" {
+		deviceID = "unknown"
+	}
+
+	payload, _ := json.Marshal(event)
+	if err := publisher.Publish(topic+"/"+deviceID, 1, false, payload); err != nil {
+		log.Printf("[Moderation] Error publishing event: %v", err)
+	}
+}
+
+// 错误定义
+var (
+	ErrNotInitialized = NewModerationError("moderation manager not initialized")
+)
+
+// ModerationError 表示内容审核中的错误
+type ModerationError struct {
+	Message string
+}
+
+// NewModerationError 创建一个新的内容审核错误
+func NewModerationError(message string) *ModerationError {
+	return &ModerationError{Message: message}
+}
+
+// Error 实现 error 接口
+func (e *ModerationError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,170 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule 定义一条屏蔽规则
+type Rule struct {
+	Category Category
+	Pattern  *regexp.Regexp
+	// Exceptions 是包含屏蔽词的常用词，如“大麻烦”包含“大麻”，命中片段落在这些词中时不算命中
+	// RE2 不支持否定环视，只能在匹配后排除
+	Exceptions []string
+}
+
+// KeywordProvider 是基于本地关键词和正则屏蔽词表的审核提供商，不依赖任何外部服务
+type KeywordProvider struct {
+	rules       []Rule
+	initialized bool
+}
+
+// NewKeywordProvider 创建一个新的关键词审核提供商，rules 为空时使用默认规则
+func NewKeywordProvider(rules []Rule) *KeywordProvider {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &KeywordProvider{
+		rules: rules,
+	}
+}
+
+// DefaultRules 返回内置的面向儿童的中文屏蔽规则
+func DefaultRules() []Rule {
+	keywords := map[Category][]string{
+		CategoryViolence:  {"杀人", "砍死", "枪杀", "爆炸物", "制作炸弹", "血腥", "虐待", "分尸"},
+		CategorySexual:    {"色情", "黄片", "裸照", "做爱", "性交", "一夜情", "约炮"},
+		CategorySelfHarm:  {"自杀", "割腕", "跳楼", "不想活了", "结束生命", "自残"},
+		CategoryHate:      {"种族歧视", "低等民族", "支那"},
+		CategoryDrugs:     {"吸毒", "冰毒", "海洛因", "大麻", "摇头丸", "赌博", "网赌"},
+		CategoryProfanity: {"傻逼", "他妈的", "操你", "滚蛋", "去死", "王八蛋", "贱人"},
+	}
+
+	exceptions := map[Category][]string{
+		CategorySelfHarm: {"跳楼价", "跳楼甩卖", "跳楼大甩卖"},
+		CategoryDrugs:    {"大麻烦"},
+	}
+
+	var rules []Rule
+	for category, words := range keywords {
+		rules = append(rules, Rule{Category: category, Pattern: keywordPattern(words), Exceptions: exceptions[category]})
+	}
+
+	// 向孩子索要个人信息
+	rules = append(rules, Rule{
+		Category: CategoryPrivacy,
+		Pattern:  regexp.MustCompile(`(告诉我|说出|发给我).{0,6}(家庭住址|家住哪|手机号|电话号码|身份证号|密码)`),
+	})
+	return rules
+}
+
+// LoadRules 从屏蔽词文件加载规则
+// 每行一条规则，格式为 "类别:关键词" 或 "类别:re:正则表达式"，以 # 开头的行为注释
+func LoadRules(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		category, pattern, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(pattern) == "" {
+			return nil, fmt.Errorf("%s:%d: expected category:keyword", path, lineNo)
+		}
+
+		var re *regexp.Regexp
+		if expr, isRegexp := strings.CutPrefix(pattern, "re:"); isRegexp {
+			if re, err = regexp.Compile(expr); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+		} else {
+			re = keywordPattern([]string{strings.TrimSpace(pattern)})
+		}
+
+		rules = append(rules, Rule{Category: Category(strings.TrimSpace(category)), Pattern: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// keywordPattern 把一组关键词编译为一个忽略大小写的正则
+func keywordPattern(words []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	return regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+}
+
+// Check 用所有规则匹配文本，汇总命中的类别和片段
+func (p *KeywordProvider) Check(text string) (*Verdict, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	verdict := &Verdict{Provider: "keyword"}
+	seen := make(map[Category]bool)
+	for _, rule := range p.rules {
+		var matches []string
+		for _, loc := range rule.Pattern.FindAllStringIndex(text, -1) {
+			if !excepted(text, loc[0], loc[1], rule.Exceptions) {
+				matches = append(matches, text[loc[0]:loc[1]])
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		verdict.Flagged = true
+		verdict.Matches = append(verdict.Matches, matches...)
+		if !seen[rule.Category] {
+			seen[rule.Category] = true
+			verdict.Categories = append(verdict.Categories, rule.Category)
+		}
+	}
+	return verdict, nil
+}
+
+// excepted 判断 text[start:end] 是否落在某个例外词的出现位置中
+func excepted(text string, start, end int, exceptions []string) bool {
+	for _, word := range exceptions {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], word)
+			if i < 0 {
+				break
+			}
+			i += offset
+			if i <= start && end <= i+len(word) {
+				return true
+			}
+			offset = i + 1
+		}
+	}
+	return false
+}
+
+// Initialize 初始化关键词审核提供商
+func (p *KeywordProvider) Initialize() error {
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理关键词审核提供商资源
+func (p *KeywordProvider) Cleanup() error {
+	p.initialized = false
+	return nil
+}
@@ -0,0 +1,35 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywordProviderExceptions(t *testing.T) {
+	provider := NewKeywordProvider(nil)
+	if err := provider.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text    string
+		matches []string
+	}{
+		{"这下可惹上大麻烦了", nil},
+		{"商场在搞跳楼大甩卖", nil},
+		{"大麻是毒品", []string{"大麻"}},
+		{"大麻会带来大麻烦", []string{"大麻"}},
+		{"我不想活了", []string{"不想活了"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			verdict, err := provider.Check(tt.text)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if verdict.Flagged != (tt.matches != nil) || !reflect.DeepEqual(verdict.Matches, tt.matches) {
+				t.Errorf("verdict = %+v, want matches %q", verdict, tt.matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// ChatClient 是审核分类所需的最小对话接口，llm.Provider 和 llm.LLMManager 都满足
+type ChatClient interface {
+	Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error)
+}
+
+// moderationPrompt 是审核分类模型的系统提示词
+const moderationPrompt = `你是一个儿童内容安全审核员。判断用户给出的文本是否适合儿童听到，只返回纯JSON，不要任何其他内容。
+
+返回格式：{"flagged": true或false, "categories": ["类别"], "matches": ["文本中违规的原词"]}
+
+可用类别：violence（暴力血腥）、sexual（色情）、self_harm（自残自杀）、hate（歧视仇恨）、drugs（毒品烟酒赌博）、profanity（脏话辱骂）、privacy（索要个人信息）
+
+注意：
+- 正常的安全教育、医学常识、历史事件的客观描述不算违规
+- matches 只填写文本中实际出现的词语，无法定位时留空`
+
+// LLMProvider 使用一个模型对文本做内容安全分类
+type LLMProvider struct {
+	client      ChatClient
+	initialized bool
+}
+
+// NewLLMProvider 创建一个新的 LLM 审核提供商
+func NewLLMProvider(client ChatClient) *LLMProvider {
+	return &LLMProvider{
+		client: client,
+	}
+}
+
+// Check 请求模型返回 JSON 格式的审核结果
+func (p *LLMProvider) Check(text string) (*Verdict, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	response, err := p.client.Chat([]llm.Message{
+		{Role: "system", Content: moderationPrompt},
+		{Role: "user", Content: text},
+	}, &llm.ChatOptions{
+		Temperature: llm.Float(0),
+		MaxTokens:   llm.Int(100),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("moderation llm request failed: %w", err)
+	}
+
+	verdict, err := parseVerdict(response.Content)
+	if err != nil {
+		return nil, fmt.Errorf("unparseable moderation response %q: %w", response.Content, err)
+	}
+	verdict.LLMMetadata = []map[string]interface{}{response.Metadata}
+	if verdict.Flagged {
+		log.Printf("[Moderation:LLM] Flagged %v in text: %s", verdict.Categories, text)
+	}
+	return verdict, nil
+}
+
+// parseVerdict 解析模型返回的 JSON，容忍前后的多余文本和代码块标记
+func parseVerdict(content string) (*Verdict, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no json object found")
+	}
+
+	var verdict Verdict
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return nil, err
+	}
+	verdict.Provider = "llm"
+	return &verdict, nil
+}
+
+// Initialize 初始化 LLM 审核提供商
+func (p *LLMProvider) Initialize() error {
+	if p.client == nil {
+		return fmt.Errorf("moderation llm client is required")
+	}
+	log.Printf("[Moderation:LLM] Initializing LLM moderation provider")
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理 LLM 审核提供商资源
+func (p *LLMProvider) Cleanup() error {
+	p.initialized = false
+	return nil
+}
@@ -0,0 +1,50 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// replyClient 返回固定的回复和用量
+type replyClient struct {
+	reply string
+}
+
+func (c replyClient) Chat(messages []llm.Message, options *llm.ChatOptions) (*llm.Response, error) {
+	return &llm.Response{
+		Content: c.reply,
+		Metadata: map[string]interface{}{
+			llm.MetadataProvider: "deepseek",
+			llm.MetadataUsage:    llm.Usage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35},
+		},
+	}, nil
+}
+
+func TestModerationManagerCarriesUsage(t *testing.T) {
+	for _, tt := range []struct {
+		reply   string
+		flagged bool
+	}{
+		{`{"flagged": false}`, false},
+		{`{"flagged": true, "categories": ["violence"]}`, true},
+	} {
+		manager := NewModerationManager()
+		manager.RegisterProvider("keyword", NewKeywordProvider(nil))
+		manager.RegisterProvider("llm", NewLLMProvider(replyClient{tt.reply}))
+		if err := manager.Initialize(); err != nil {
+			t.Fatal(err)
+		}
+
+		verdict, err := manager.Check("今天天气不错")
+		if err != nil || verdict.Flagged != tt.flagged {
+			t.Fatalf("%s: verdict = %+v, err %v", tt.reply, verdict, err)
+		}
+		if len(verdict.LLMMetadata) != 1 {
+			t.Fatalf("%s: metadata = %v, want one llm call", tt.reply, verdict.LLMMetadata)
+		}
+		if usage, ok := llm.UsageFromMetadata(verdict.LLMMetadata[0]); !ok || usage.TotalTokens != 35 {
+			t.Errorf("%s: usage = %+v, %v", tt.reply, usage, ok)
+		}
+	}
+}
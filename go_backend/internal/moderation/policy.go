@@ -0,0 +1,103 @@
+package moderation
+
+import (
+	"strings"
+)
+
+// Action 表示命中违规内容后的处理方式
+type Action string
+
+// 定义处理方式常量
+const (
+	// ActionBlock 拦截：输入不交给模型，输出在违规处终止，并播放兜底回复
+	ActionBlock Action = "block"
+	// ActionRewrite 改写：把命中的词替换为 *，无法定位具体词语时退化为替换
+	ActionRewrite Action = "rewrite"
+	// ActionReplace 替换：把违规的句子整体换成兜底回复，后续内容照常播放
+	ActionReplace Action = "replace"
+	// ActionAllow 放行：只记录不处理
+	ActionAllow Action = "allow"
+)
+
+// Policy 表示一个角色的内容安全策略
+type Policy struct {
+	Action     Action     // 处理方式
+	Fallback   string     // 拦截或替换时使用的兜底回复
+	Categories []Category // 生效的类别，为空表示全部类别
+}
+
+// defaultFallback 是没有配置兜底回复时使用的文本
+const defaultFallback = "这个话题我们换一个吧，我们聊点别的好不好？"
+
+// Applies 判断策略是否对该审核结果生效
+func (p Policy) Applies(verdict *Verdict) bool {
+	if verdict == nil || !verdict.Flagged || p.Action == ActionAllow {
+		return false
+	}
+	if len(p.Categories) == 0 || len(verdict.Categories) == 0 {
+		return true
+	}
+	for _, category := range verdict.Categories {
+		for _, enabled := range p.Categories {
+			if category == enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FallbackText 返回兜底回复
+func (p Policy) FallbackText() string {
+	if p.Fallback == "" {
+		return defaultFallback
+	}
+	return p.Fallback
+}
+
+// Apply 按策略处理文本，返回处理后的文本和实际采用的处理方式
+// 对于 ActionBlock 和 ActionReplace，返回兜底回复
+func (p Policy) Apply(text string, verdict *Verdict) (string, Action) {
+	if !p.Applies(verdict) {
+		return text, ActionAllow
+	}
+
+	switch p.Action {
+	case ActionRewrite:
+		if rewritten, ok := mask(text, verdict.Matches); ok {
+			return rewritten, ActionRewrite
+		}
+		return p.FallbackText(), ActionReplace
+	case ActionReplace:
+		return p.FallbackText(), ActionReplace
+	default:
+		return p.FallbackText(), ActionBlock
+	}
+}
+
+// mask 把命中的片段替换为等长的 *，没有可替换的片段时返回 false
+func mask(text string, matches []string) (string, bool) {
+	masked := false
+	for _, match := range matches {
+		if match == "" || !strings.Contains(text, match) {
+			continue
+		}
+		text = strings.ReplaceAll(text, match, strings.Repeat("*", len([]rune(match))))
+		masked = true
+	}
+	return text, masked
+}
+
+// ParseAction 解析处理方式，无法识别时返回 ActionBlock
+func ParseAction(value string) Action {
+	switch Action(strings.ToLower(strings.TrimSpace(value))) {
+	case ActionRewrite:
+		return ActionRewrite
+	case ActionReplace:
+		return ActionReplace
+	case ActionAllow:
+		return ActionAllow
+	default:
+		return ActionBlock
+	}
+}
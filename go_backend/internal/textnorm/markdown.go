@@ -0,0 +1,101 @@
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	codeFenceRe     = regexp.MustCompile("(?s)```[^\\n]*\\n?.*?(```|$)")
+	imageRe         = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRe          = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	inlineCodeRe    = regexp.MustCompile("`([^`\\n]*)`")
+	boldRe          = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicRe        = regexp.MustCompile(`\*([^*\n]+)\*`)
+	strikeRe        = regexp.MustCompile(`~~(.+?)~~`)
+	headingRe       = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	blockquoteRe    = regexp.MustCompile(`(?m)^\s*>\s?`)
+	listMarkerRe    = regexp.MustCompile(`(?m)^\s*([-*+]|\d{1,2}[.)、])\s+`)
+	horizontalRe    = regexp.MustCompile(`(?m)^\s*([-*_]\s*){3,}$`)
+	tableDividerRe  = regexp.MustCompile(`(?m)^[ \t]*\|?([ \t]*:?-{2,}:?[ \t]*\|)+[ \t]*:?-*:?[ \t]*\|?[ \t]*$`)
+	tableEdgePipeRe = regexp.MustCompile(`(?m)^[ \t]*\||\|[ \t]*$`)
+)
+
+// StripMarkdown 去掉 Markdown 标记，只保留可读的文字
+// 代码块整体移除，因为代码读出来没有意义
+func StripMarkdown(text string) string {
+	text = codeFenceRe.ReplaceAllString(text, "")
+	text = imageRe.ReplaceAllString(text, "$1")
+	text = linkRe.ReplaceAllString(text, "$1")
+	text = inlineCodeRe.ReplaceAllString(text, "$1")
+	text = boldRe.ReplaceAllString(text, "$1$2")
+	text = strikeRe.ReplaceAllString(text, "$1")
+	text = italicRe.ReplaceAllString(text, "$1")
+	text = tableDividerRe.ReplaceAllString(text, "")
+	text = tableEdgePipeRe.ReplaceAllString(text, "")
+	text = horizontalRe.ReplaceAllString(text, "")
+	text = headingRe.ReplaceAllString(text, "")
+	text = blockquoteRe.ReplaceAllString(text, "")
+	text = listMarkerRe.ReplaceAllString(text, "")
+	return strings.ReplaceAll(text, "|", "，")
+}
+
+// StripEmoji 移除表情符号，返回剩余文本和按出现顺序排列的表情
+func StripEmoji(text string) (string, []string) {
+	var builder strings.Builder
+	var emojis []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			emojis = append(emojis, string(current))
+			current = current[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case (r == 0x200D || r == 0xFE0F || isSkinTone(r)) && len(current) > 0:
+			// 零宽连接符、变体选择符和肤色修饰符属于前一个表情
+			current = append(current, r)
+		case isEmoji(r):
+			current = append(current, r)
+		case r == 0xFE0F || r == 0x200D:
+			// 孤立的修饰字符直接丢弃
+		default:
+			flush()
+			builder.WriteRune(r)
+		}
+	}
+	flush()
+
+	return builder.String(), emojis
+}
+
+// isEmoji 判断字符是否属于常见的表情符号区段
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F300 && r <= 0x1F5FF: // 杂项符号和象形文字
+		return true
+	case r >= 0x1F600 && r <= 0x1F64F: // 表情
+		return true
+	case r >= 0x1F680 && r <= 0x1F6FF: // 交通和地图
+		return true
+	case r >= 0x1F900 && r <= 0x1FAFF: // 补充符号和象形文字
+		return true
+	case r >= 0x1F1E6 && r <= 0x1F1FF: // 区域指示符（国旗）
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号和装饰符号
+		return true
+	case r >= 0x1F000 && r <= 0x1F2FF: // 麻将、扑克等
+		return true
+	case r == 0x2B50 || r == 0x2B55 || r == 0x231A || r == 0x231B || r == 0x23F0 || r == 0x23F3:
+		return true
+	}
+	return false
+}
+
+// isSkinTone 判断是否为肤色修饰符
+func isSkinTone(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
@@ -0,0 +1,116 @@
+// Package textnorm 将大模型的回复整理成适合语音合成的文本
+//
+// LLM 输出中常见的 Markdown 标记、表情符号、链接和阿拉伯数字会被 TTS 原样读出，
+// 这里在送入 TTSManager 之前去掉标记，把数字、日期、时间、百分比和单位展开为中文读法。
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Result 表示一段文本的规范化结果
+type Result struct {
+	Display string   // 去掉 Markdown、表情和链接后用于屏幕显示的文本
+	Speech  string   // 进一步展开数字和符号后用于语音合成的文本
+	Emojis  []string // 从文本中移除的表情符号，可作为情绪提示
+}
+
+var (
+	urlRe        = regexp.MustCompile(`(https?://|www\.)[^\s<>"'，。！？、）)\]]+`)
+	whitespaceRe = regexp.MustCompile(`[ \t]+`)
+	newlinesRe   = regexp.MustCompile(`\s*\n+\s*`)
+	pauseRe      = regexp.MustCompile(`\s*([，。！？、；：])\s*`)
+)
+
+// Normalize 规范化一段文本（通常是一句话）
+func Normalize(text string) Result {
+	display := StripMarkdown(text)
+	display = urlRe.ReplaceAllString(display, "")
+	display, emojis := StripEmoji(display)
+	display = cleanupWhitespace(display)
+
+	speech := ExpandNumbers(display)
+	speech = normalizeSymbols(speech)
+	speech = spaceMixedScript(speech)
+	speech = cleanupWhitespace(speech)
+
+	return Result{
+		Display: display,
+		Speech:  speech,
+		Emojis:  emojis,
+	}
+}
+
+// IsSpeakable 判断文本中是否有可朗读的字符
+func IsSpeakable(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolReplacer 把 TTS 会读错或读出符号名的字符替换为中文读法或停顿
+var symbolReplacer = strings.NewReplacer(
+	"&", "和",
+	"→", "到",
+	"←", "",
+	"…", "，",
+	"——", "，",
+	"/", "、",
+	"\\", "",
+	"|", "，",
+	"*", "",
+	"#", "",
+	"`", "",
+	"<", "",
+	">", "",
+	"\"", "",
+	"“", "",
+	"”", "",
+)
+
+// normalizeSymbols 替换朗读时没有意义的符号
+func normalizeSymbols(text string) string {
+	return symbolReplacer.Replace(text)
+}
+
+// spaceMixedScript 在汉字与英文单词之间插入空格，帮助 TTS 正确切换中英文发音
+func spaceMixedScript(text string) string {
+	runes := []rune(text)
+	var builder strings.Builder
+	builder.Grow(len(text) + 8)
+
+	for i, r := range runes {
+		if i > 0 {
+			prev := runes[i-1]
+			if (isHan(prev) && isLatin(r)) || (isLatin(prev) && isHan(r)) {
+				builder.WriteRune(' ')
+			}
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// isHan 判断是否为汉字
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// isLatin 判断是否为拉丁字母
+func isLatin(r rune) bool {
+	return r < unicode.MaxASCII && unicode.IsLetter(r)
+}
+
+// cleanupWhitespace 合并多余空白，换行转为逗号停顿
+func cleanupWhitespace(text string) string {
+	text = newlinesRe.ReplaceAllString(strings.TrimSpace(text), "，")
+	text = whitespaceRe.ReplaceAllString(text, " ")
+	text = pauseRe.ReplaceAllString(text, "$1")
+	text = strings.ReplaceAll(text, "，，", "，")
+	return strings.Trim(text, " ，,")
+}
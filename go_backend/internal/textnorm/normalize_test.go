@@ -0,0 +1,97 @@
+package textnorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		display string
+		speech  string
+		emojis  []string
+	}{
+		{
+			name:    "markdown and units",
+			in:      "**注意**：明天气温25°C，降水概率30%。",
+			display: "注意：明天气温25°C，降水概率30%。",
+			speech:  "注意：明天气温二十五摄氏度，降水概率百分之三十。",
+		},
+		{
+			name:    "link and emoji",
+			in:      "访问 https://example.com 了解详情😀",
+			display: "访问 了解详情",
+			speech:  "访问 了解详情",
+			emojis:  []string{"😀"},
+		},
+		{
+			name:    "mixed chinese and english",
+			in:      "我用Python写了3个脚本",
+			display: "我用Python写了3个脚本",
+			speech:  "我用 Python 写了三个脚本",
+		},
+		{
+			name:    "hotline",
+			in:      "客服电话：400-123-4567",
+			display: "客服电话：400-123-4567",
+			speech:  "客服电话：四零零，幺二三，四五六七",
+		},
+		{
+			name:    "equation",
+			in:      "5 - 3 = 2 对吗？",
+			display: "5 - 3 = 2 对吗？",
+			speech:  "五减三等于二 对吗？",
+		},
+		{
+			name:    "date and time",
+			in:      "会议时间是2024-05-01 14:30",
+			display: "会议时间是2024-05-01 14:30",
+			speech:  "会议时间是二零二四年五月一日 十四点三十分",
+		},
+		{
+			name:    "amount",
+			in:      "这套房子卖1500000元。",
+			display: "这套房子卖1500000元。",
+			speech:  "这套房子卖一百五十万元。",
+		},
+		{
+			name:    "list and code block",
+			in:      "1. 安装\n```\ngo build\n```\n2. 运行",
+			display: "安装，运行",
+			speech:  "安装，运行",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(tt.in)
+			if got.Display != tt.display {
+				t.Errorf("Display = %q, want %q", got.Display, tt.display)
+			}
+			if got.Speech != tt.speech {
+				t.Errorf("Speech = %q, want %q", got.Speech, tt.speech)
+			}
+			if !reflect.DeepEqual(got.Emojis, tt.emojis) {
+				t.Errorf("Emojis = %v, want %v", got.Emojis, tt.emojis)
+			}
+		})
+	}
+}
+
+func TestIsSpeakable(t *testing.T) {
+	tests := map[string]bool{
+		"你好":   true,
+		"OK":   true,
+		"42":   true,
+		"，。！":  false,
+		"":     false,
+		"  - ": false,
+	}
+	for in, want := range tests {
+		if got := IsSpeakable(in); got != want {
+			t.Errorf("IsSpeakable(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package textnorm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	chineseDigits  = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	sectionUnits   = []string{"", "万", "亿", "万亿"}
+	thousandsRe    = regexp.MustCompile(`\d{1,3}(,\d{3})+(\.\d+)?`)
+	isoDateRe      = regexp.MustCompile(`(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`)
+	yearRe         = regexp.MustCompile(`(\d{4})年`)
+	timeRe         = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?`)
+	percentRe      = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s*[%％]`)
+	unitRe         = regexp.MustCompile(`(-?\d+(?:\.\d+)?)\s*(°C|℃|°F|℉|km/h|m/s|kWh|km²|m²|m³|km|kg|cm|mm|ml|mL|min|GB|MB|KB|kW|m|g|L|W|h|s)([^A-Za-z]|$)`)
+	rangeRe        = regexp.MustCompile(`(\d+(?:\.\d+)?)(\s*)([~～-])(\s*)(\d+(?:\.\d+)?)`)
+	equationRe     = regexp.MustCompile(`-?\d+(?:\.\d+)?(?:\s*[-+×÷*/]\s*\d+(?:\.\d+)?)+\s*=\s*-?\d+(?:\.\d+)?`)
+	operatorRe     = regexp.MustCompile(`(\d)\s*([-+×÷*/=])\s*(-?\d)`)
+	phoneRe        = regexp.MustCompile(`(^|[^\d.])((?:\+?86[-\s]?)?1[3-9]\d[-\s]?\d{4}[-\s]?\d{4}|0\d{2,3}-\d{7,8}|[48]00-\d{3}-\d{4})($|[^\d.])`)
+	digitsCueRe    = regexp.MustCompile(`(电话|手机|号码|编号|尾号|卡号|账号|验证码|邮编|工号|房间号|QQ)[是为：:\s]*$`)
+	negativeRe     = regexp.MustCompile(`(^|[^\dA-Za-z])-(\d)`)
+	decimalRe      = regexp.MustCompile(`\d+\.\d+`)
+	integerRe      = regexp.MustCompile(`\d+`)
+	twoClassifiers = "个只位本条次件张台辆双对种名口棵颗岁天"
+)
+
+// unitReadings 是常见单位的中文读法
+var unitReadings = map[string]string{
+	"°C":   "摄氏度",
+	"℃":    "摄氏度",
+	"°F":   "华氏度",
+	"℉":    "华氏度",
+	"km/h": "公里每小时",
+	"m/s":  "米每秒",
+	"kWh":  "千瓦时",
+	"km²":  "平方公里",
+	"m²":   "平方米",
+	"m³":   "立方米",
+	"km":   "公里",
+	"kg":   "千克",
+	"cm":   "厘米",
+	"mm":   "毫米",
+	"ml":   "毫升",
+	"mL":   "毫升",
+	"min":  "分钟",
+	"GB":   "G B",
+	"MB":   "M B",
+	"KB":   "K B",
+	"kW":   "千瓦",
+	"m":    "米",
+	"g":    "克",
+	"L":    "升",
+	"W":    "瓦",
+	"h":    "小时",
+	"s":    "秒",
+}
+
+// operatorReadings 是算式中运算符的读法
+var operatorReadings = map[string]string{
+	"+": "加",
+	"-": "减",
+	"×": "乘",
+	"*": "乘",
+	"÷": "除以",
+	"/": "除以",
+	"=": "等于",
+}
+
+// ExpandNumbers 把文本中的数字、日期、时间、百分比和单位展开为中文读法
+// 展开顺序很重要：先处理带结构的格式，最后才处理单独的整数
+func ExpandNumbers(text string) string {
+	// 带千分位分隔符的一定是数量，直接按数值读
+	text = thousandsRe.ReplaceAllStringFunc(text, func(s string) string {
+		return readAmount(strings.ReplaceAll(s, ",", ""))
+	})
+
+	// 138-1234-5678 → 幺三八，幺二三四，五六七八
+	// 要在日期和范围之前处理，否则分隔符会被当成日期或范围
+	text = phoneRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := phoneRe.FindStringSubmatch(s)
+		return m[1] + readPhone(m[2]) + m[3]
+	})
+
+	// 5 - 3 = 2 → 五减三等于二，算式中的减号不是范围也不是负号
+	text = equationRe.ReplaceAllStringFunc(text, func(s string) string {
+		for operatorRe.MatchString(s) {
+			s = operatorRe.ReplaceAllStringFunc(s, func(op string) string {
+				m := operatorRe.FindStringSubmatch(op)
+				return m[1] + operatorReadings[m[2]] + m[3]
+			})
+		}
+		return s
+	})
+
+	// 2024-05-01 → 二零二四年五月一日
+	text = isoDateRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := isoDateRe.FindStringSubmatch(s)
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		return ReadDigits(m[1]) + "年" + ReadInt(int64(month)) + "月" + ReadInt(int64(day)) + "日"
+	})
+
+	// 2024年 → 二零二四年
+	text = yearRe.ReplaceAllStringFunc(text, func(s string) string {
+		return ReadDigits(yearRe.FindStringSubmatch(s)[1]) + "年"
+	})
+
+	// 10:05 → 十点零五分
+	text = timeRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := timeRe.FindStringSubmatch(s)
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour > 24 || minute > 59 {
+			return s
+		}
+		result := ReadInt(int64(hour)) + "点"
+		switch {
+		case minute == 0 && m[3] == "":
+			result += "整"
+		case minute < 10:
+			result += "零" + ReadInt(int64(minute)) + "分"
+		default:
+			result += ReadInt(int64(minute)) + "分"
+		}
+		if m[3] != "" {
+			seconds, _ := strconv.Atoi(m[3])
+			result += ReadInt(int64(seconds)) + "秒"
+		}
+		return result
+	})
+
+	// 25.5% → 百分之二十五点五
+	text = percentRe.ReplaceAllStringFunc(text, func(s string) string {
+		number := percentRe.FindStringSubmatch(s)[1]
+		if strings.HasPrefix(number, "-") {
+			return "负百分之" + ReadNumber(number[1:])
+		}
+		return "百分之" + ReadNumber(number)
+	})
+
+	// 25°C → 二十五摄氏度
+	text = unitRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := unitRe.FindStringSubmatch(s)
+		return ReadNumber(m[1]) + unitReadings[m[2]] + m[3]
+	})
+
+	// 3~5 → 三到五；两边带空格的减号更像算式，不按范围读
+	text = rangeRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := rangeRe.FindStringSubmatch(s)
+		if m[3] == "-" && (m[2] != "" || m[4] != "") {
+			return s
+		}
+		return ReadNumber(m[1]) + "到" + ReadNumber(m[5])
+	})
+
+	// -5 → 负5（数字部分随后展开）
+	text = negativeRe.ReplaceAllString(text, "${1}负${2}")
+
+	// 3.14 → 三点一四
+	text = decimalRe.ReplaceAllStringFunc(text, ReadNumber)
+
+	// 剩余的整数
+	return replaceIntegers(text)
+}
+
+// replaceIntegers 展开整数，数量词前的“二”读作“两”
+func replaceIntegers(text string) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range integerRe.FindAllStringIndex(text, -1) {
+		builder.WriteString(text[last:loc[0]])
+		digits := text[loc[0]:loc[1]]
+		reading := readIntString(digits)
+		if digitsCueRe.MatchString(text[:loc[0]]) {
+			reading = readPhone(digits)
+		}
+
+		if digits == "2" && loc[1] < len(text) && !strings.HasSuffix(text[:loc[0]], "第") {
+			next := []rune(text[loc[1]:])[0]
+			if strings.ContainsRune(twoClassifiers, next) {
+				reading = "两"
+			}
+		}
+
+		builder.WriteString(reading)
+		last = loc[1]
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// readIntString 读一个整数字符串；以 0 开头、像手机号或超出读法范围的数字逐位读出，其余按数值读
+func readIntString(digits string) string {
+	switch {
+	case len(digits) > 1 && digits[0] == '0', len(digits) > 16:
+		return ReadDigits(digits)
+	case len(digits) == 11 && digits[0] == '1' && digits[1] >= '3':
+		return readPhone(digits)
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return ReadDigits(digits)
+	}
+	return ReadInt(n)
+}
+
+// readPhone 按电话号码的习惯逐位读出，1 读作“幺”，分隔符变成短停顿
+func readPhone(number string) string {
+	var builder strings.Builder
+	for _, ch := range number {
+		switch {
+		case ch == '1':
+			builder.WriteString("幺")
+		case ch >= '0' && ch <= '9':
+			builder.WriteString(chineseDigits[ch-'0'])
+		case ch == '+':
+			builder.WriteString("加")
+		case ch == '-' || ch == ' ':
+			builder.WriteString("，")
+		}
+	}
+	return builder.String()
+}
+
+// readAmount 按数值读整数或小数，不论整数部分多长
+func readAmount(number string) string {
+	intPart, fracPart, hasFrac := strings.Cut(number, ".")
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return ReadNumber(number)
+	}
+	result := ReadInt(n)
+	if hasFrac && fracPart != "" {
+		result += "点" + ReadDigits(fracPart)
+	}
+	return result
+}
+
+// ReadNumber 读一个整数或小数字符串，小数部分逐位读出
+func ReadNumber(number string) string {
+	intPart, fracPart, hasFrac := strings.Cut(number, ".")
+	result := readIntString(intPart)
+	if intPart == "" {
+		result = "零"
+	}
+	if hasFrac && fracPart != "" {
+		result += "点" + ReadDigits(fracPart)
+	}
+	return result
+}
+
+// ReadDigits 逐位读出数字串，例如 2024 → 二零二四
+func ReadDigits(digits string) string {
+	var builder strings.Builder
+	for _, ch := range digits {
+		if ch >= '0' && ch <= '9' {
+			builder.WriteString(chineseDigits[ch-'0'])
+		}
+	}
+	return builder.String()
+}
+
+// ReadInt 按中文读法读整数，例如 10010 → 一万零一十
+func ReadInt(n int64) string {
+	if n == 0 {
+		return "零"
+	}
+	if n < 0 {
+		return "负" + ReadInt(-n)
+	}
+
+	// 按四位一节从低到高拆分
+	var sections []int
+	for n > 0 {
+		sections = append(sections, int(n%10000))
+		n /= 10000
+	}
+	if len(sections) > len(sectionUnits) {
+		return ""
+	}
+
+	var builder strings.Builder
+	zeroPending := false
+	for i := len(sections) - 1; i >= 0; i-- {
+		section := sections[i]
+		if section == 0 {
+			zeroPending = builder.Len() > 0
+			continue
+		}
+		if builder.Len() > 0 && (zeroPending || section < 1000) {
+			builder.WriteString("零")
+		}
+		builder.WriteString(readSection(section, builder.Len() == 0))
+		builder.WriteString(sectionUnits[i])
+		zeroPending = false
+	}
+	return builder.String()
+}
+
+// readSection 读一个四位以内的节；leading 表示这是整个数字的开头（“十五”而不是“一十五”）
+func readSection(section int, leading bool) string {
+	units := []string{"千", "百", "十", ""}
+	divisors := []int{1000, 100, 10, 1}
+
+	var builder strings.Builder
+	started := false
+	zero := false
+	for i, divisor := range divisors {
+		digit := section / divisor % 10
+		if digit == 0 {
+			if started {
+				zero = true
+			}
+			continue
+		}
+		if zero {
+			builder.WriteString("零")
+			zero = false
+		}
+		if !(digit == 1 && divisor == 10 && !started && leading) {
+			builder.WriteString(chineseDigits[digit])
+		}
+		builder.WriteString(units[i])
+		started = true
+	}
+	return builder.String()
+}
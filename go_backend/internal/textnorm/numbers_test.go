@@ -0,0 +1,93 @@
+package textnorm
+
+import "testing"
+
+func TestExpandNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// 日期和时间
+		{"iso date", "2024-05-01", "二零二四年五月一日"},
+		{"slash date", "2024/5/1", "二零二四年五月一日"},
+		{"chinese date", "2024年5月1日", "二零二四年五月一日"},
+		{"time", "10:05", "十点零五分"},
+		{"time on the hour", "8:00", "八点整"},
+		{"time with seconds", "12:30:15", "十二点三十分十五秒"},
+		{"invalid time", "25:99", "二十五:九十九"},
+
+		// 百分比和单位
+		{"percent", "25.5%", "百分之二十五点五"},
+		{"negative percent", "-3%", "负百分之三"},
+		{"full width percent", "30％", "百分之三十"},
+		{"celsius", "25°C", "二十五摄氏度"},
+		{"speed", "100km/h", "一百公里每小时"},
+		{"weight", "5kg", "五千克"},
+		{"unit with space", "3 m", "三米"},
+		{"unit inside word", "5mins", "五mins"},
+
+		// 数量
+		{"integer", "10010", "一万零一十"},
+		{"decimal", "3.14", "三点一四"},
+		{"negative", "-5度", "负五度"},
+		{"thousands separator", "12,345.6元", "一万二千三百四十五点六元"},
+		{"large amount", "1500000元", "一百五十万元"},
+		{"ten billion", "10000000000元", "一百亿元"},
+		{"two before classifier", "2个人", "两个人"},
+		{"ordinal two", "第2名", "第二名"},
+		{"leading zero", "007", "零零七"},
+		{"too long for cardinal", "12345678901234567890", "一二三四五六七八九零一二三四五六七八九零"},
+
+		// 范围和算式
+		{"tilde range", "3~5天", "三到五天"},
+		{"dash range", "3-5个", "三到五个"},
+		{"equation", "5 - 3 = 2", "五减三等于二"},
+		{"spaced minus", "5 - 3", "五 - 三"},
+		{"addition", "1+2+3=6", "一加二加三等于六"},
+
+		// 电话和编号
+		{"mobile", "13812345678", "幺三八幺二三四五六七八"},
+		{"mobile with dashes", "138-1234-5678", "幺三八，幺二三四，五六七八"},
+		{"mobile with country code", "+86 138 1234 5678", "加八六，幺三八，幺二三四，五六七八"},
+		{"landline", "010-12345678", "零幺零，幺二三四五六七八"},
+		{"hotline", "400-123-4567", "四零零，幺二三，四五六七"},
+		{"phone cue", "电话是12345678", "电话是幺二三四五六七八"},
+		{"code cue", "验证码：0420", "验证码：零四二零"},
+
+		// 中英文混排
+		{"digits after word", "iPhone15发布", "iPhone十五发布"},
+		{"no digits", "我用Python写代码", "我用Python写代码"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandNumbers(tt.in); got != tt.want {
+				t.Errorf("ExpandNumbers(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInt(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "零"},
+		{10, "十"},
+		{15, "十五"},
+		{110, "一百一十"},
+		{1001, "一千零一"},
+		{10010, "一万零一十"},
+		{100000, "十万"},
+		{1000001, "一百万零一"},
+		{-42, "负四十二"},
+	}
+
+	for _, tt := range tests {
+		if got := ReadInt(tt.in); got != tt.want {
+			t.Errorf("ReadInt(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
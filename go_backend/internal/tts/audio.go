@@ -0,0 +1,124 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// pcmFormat 描述 PCM 音频的采样参数
+type pcmFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// wrapPCM 给原始 PCM 数据加上 44 字节的 WAV 头
+func wrapPCM(pcm []byte, format pcmFormat) []byte {
+	blockAlign := format.Channels * format.BitsPerSample / 8
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+len(pcm)))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:36], uint16(format.BitsPerSample))
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(pcm)))
+	return append(header, pcm...)
+}
+
+// isWAV 判断数据是否以 RIFF/WAVE 头开始
+func isWAV(data []byte) bool {
+	return len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE"))
+}
+
+// unwrapWAV 从 WAV 数据中取出 PCM 数据和采样参数
+// 流式输出的 WAV 头里的长度字段常常不准确，data 块以实际剩余数据为准
+func unwrapWAV(data []byte) ([]byte, pcmFormat, error) {
+	var format pcmFormat
+	if !isWAV(data) {
+		return nil, format, fmt.Errorf("not a wav stream")
+	}
+
+	offset := 12
+	for offset+8 <= len(data) {
+		chunkID := string(data[offset : offset+4])
+		rawSize := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
+		size := int(rawSize)
+		body := data[offset+8:]
+
+		switch chunkID {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, format, fmt.Errorf("wav fmt chunk truncated")
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			format.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			if rawSize == 0 || rawSize == 0xFFFFFFFF || size < 0 || size > len(body) {
+				size = len(body)
+			}
+			return body[:size], format, nil
+		}
+		if size < 0 {
+			break
+		}
+
+		// 块按偶数字节对齐
+		offset += 8 + size + size%2
+	}
+	return nil, format, fmt.Errorf("wav data chunk not found")
+}
+
+// detectAudioFormat 根据 Content-Type 和文件头判断音频格式，无法识别时返回空字符串
+func detectAudioFormat(contentType string, data []byte) string {
+	switch {
+	case isWAV(data):
+		return "wav"
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "mp3"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "ogg_opus"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "flac"
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/ogg", "audio/opus":
+		return "ogg_opus"
+	case "audio/pcm", "audio/l16", "application/octet-stream":
+		return "pcm"
+	}
+	if strings.HasPrefix(mediaType, "audio/") {
+		return strings.TrimPrefix(mediaType, "audio/")
+	}
+	return ""
+}
+
+// AudioContentType 根据文件头返回音频数据的 MIME 类型
+func AudioContentType(data []byte) string {
+	switch detectAudioFormat("", data) {
+	case "mp3":
+		return "audio/mpeg"
+	case "wav":
+		return "audio/wav"
+	case "ogg_opus":
+		return "audio/ogg"
+	case "flac":
+		return "audio/flac"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,121 @@
+package tts
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AudioCache 是按总字节数限制容量的 LRU 音频缓存
+// 相同文本和选项的合成结果直接复用，避免重复调用提供商
+type AudioCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+	hits     int64
+	misses   int64
+}
+
+// cacheEntry 是缓存中的一条音频
+type cacheEntry struct {
+	key   string
+	audio []byte
+}
+
+// CacheStats 表示缓存的使用情况
+type CacheStats struct {
+	Entries  int   `json:"entries"`
+	Bytes    int   `json:"bytes"`
+	MaxBytes int   `json:"max_bytes"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
+
+// NewAudioCache 创建一个最多保存 maxBytes 字节音频的缓存
+func NewAudioCache(maxBytes int) *AudioCache {
+	return &AudioCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// cacheKey 由文本和排序后的选项组成缓存键
+func cacheKey(text string, options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(options[k])
+		b.WriteByte('\x00')
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// Get 查找缓存的音频
+func (c *AudioCache) Get(text string, options map[string]string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[cacheKey(text, options)]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).audio, true
+}
+
+// Put 保存合成结果，超出容量时淘汰最久未使用的音频，超过总容量的单条音频不缓存
+func (c *AudioCache) Put(text string, options map[string]string, audioData []byte) {
+	if len(audioData) == 0 || len(audioData) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(text, options)
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*cacheEntry)
+		c.size += len(audioData) - len(entry.audio)
+		entry.audio = audioData
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, audio: audioData})
+		c.size += len(audioData)
+	}
+
+	for c.size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.audio)
+	}
+}
+
+// Stats 返回缓存的使用情况
+func (c *AudioCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Entries:  len(c.entries),
+		Bytes:    c.size,
+		MaxBytes: c.maxBytes,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+}
@@ -0,0 +1,154 @@
+package tts
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// VoiceFilter 是查询音色目录时的过滤条件，为空的字段不过滤
+type VoiceFilter struct {
+	Language string // 语言前缀，如 zh 匹配 zh-CN
+	Gender   string // male、female
+	Tag      string // 标签名，或 名称=取值
+}
+
+// Match 判断音色是否满足过滤条件
+func (f VoiceFilter) Match(voice Voice) bool {
+	if f.Language != "" && !strings.HasPrefix(strings.ToLower(voice.Language), strings.ToLower(f.Language)) {
+		return false
+	}
+	if f.Gender != "" && !strings.EqualFold(voice.Gender, f.Gender) {
+		return false
+	}
+	if f.Tag != "" {
+		key, value, hasValue := strings.Cut(f.Tag, "=")
+		tagValue, exists := voice.Tags[key]
+		if !exists || (hasValue && !strings.EqualFold(tagValue, value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// QualifyVoiceID 返回带提供商前缀的音色ID，格式为 提供商:音色
+func QualifyVoiceID(provider, voice string) string {
+	return provider + ":" + voice
+}
+
+// splitVoiceID 拆分带提供商前缀的音色ID（调用方需持有读锁）
+// 前缀不是已注册的提供商时视为默认提供商的音色，返回空的提供商名
+func (tm *TTSManager) splitVoiceID(id string) (string, string) {
+	provider, voice, ok := strings.Cut(id, ":")
+	if !ok {
+		return "", id
+	}
+	if _, exists := tm.providers[provider]; !exists {
+		return "", id
+	}
+	return provider, voice
+}
+
+// Voices 返回所有提供商的音色目录，音色ID带提供商前缀
+// 默认提供商的音色排在前面，其余按提供商名称排序；获取失败的提供商会被跳过
+func (tm *TTSManager) Voices(filter VoiceFilter) ([]Voice, error) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	if !tm.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	names := make([]string, 0, len(tm.providers))
+	for name := range tm.providers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == tm.defaultProvider) != (names[j] == tm.defaultProvider) {
+			return names[i] == tm.defaultProvider
+		}
+		return names[i] < names[j]
+	})
+
+	catalog := []Voice{}
+	for _, name := range names {
+		voices, err := tm.providers[name].GetVoices()
+		if err != nil {
+			log.Printf("[TTS] Failed to get voices from provider %s: %v", name, err)
+			continue
+		}
+		for _, voice := range voices {
+			voice.ID = QualifyVoiceID(name, voice.ID)
+			voice.Provider = name
+			if filter.Match(voice) {
+				catalog = append(catalog, voice)
+			}
+		}
+	}
+	return catalog, nil
+}
+
+// Voice 按ID查找音色，ID 不带提供商前缀时在默认提供商中查找
+func (tm *TTSManager) Voice(id string) (Voice, error) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	if !tm.initialized {
+		return Voice{}, ErrNotInitialized
+	}
+
+	name, voiceID := tm.splitVoiceID(id)
+	if name == "" {
+		name = tm.defaultProvider
+	}
+	provider, exists := tm.providers[name]
+	if !exists {
+		return Voice{}, ErrProviderNotFound
+	}
+
+	voices, err := provider.GetVoices()
+	if err != nil {
+		return Voice{}, err
+	}
+	for _, voice := range voices {
+		if voice.ID == voiceID {
+			voice.ID = QualifyVoiceID(name, voice.ID)
+			voice.Provider = name
+			return voice, nil
+		}
+	}
+	return Voice{}, fmt.Errorf("%w: %s", ErrVoiceNotFound, id)
+}
+
+// PreviewVoice 只使用音色所属的提供商合成一段示例语音，不切换备用提供商
+func (tm *TTSManager) PreviewVoice(id, text string) ([]byte, error) {
+	voice, err := tm.Voice(id)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		text = previewText(voice.Language)
+	}
+
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	provider, exists := tm.providers[voice.Provider]
+	if !exists {
+		return nil, ErrProviderNotFound
+	}
+	_, voiceID := tm.splitVoiceID(voice.ID)
+	return synthesizeWithTimeout(provider, text, map[string]string{
+		"voice_id": voiceID,
+		"format":   "mp3",
+	}, tm.timeout)
+}
+
+// previewText 返回试听使用的示例句子
+func previewText(language string) string {
+	if strings.HasPrefix(strings.ToLower(language), "en") {
+		return "Hello, I'm Xiaozhi. Nice to meet you!"
+	}
+	return "你好，我是小智，很高兴认识你！"
+}
@@ -1,8 +1,10 @@
 package tts
 
 import (
+	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 // Provider 表示不同的TTS供应商接口
@@ -27,6 +29,7 @@ type Voice struct {
 	Gender   string            `json:"gender"`
 	Language string            `json:"language"`
 	Tags     map[string]string `json:"tags,omitempty"`
+	Provider string            `json:"provider,omitempty"` // 音色目录中标明所属的提供商
 }
 
 // TTSManager 管理多个TTS提供商
@@ -35,12 +38,18 @@ type TTSManager struct {
 	mutex      sync.RWMutex
 	initialized bool
 	defaultProvider string
+	fallback   []string                     // 备用提供商链，按顺序尝试
+	timeout    time.Duration                // 单句合成超时
+	voiceMap   map[string]map[string]string // 音色 -> 备用提供商 -> 对应音色
+	cache      *AudioCache                  // 合成结果缓存，为 nil 时不缓存
 }
 
 // NewTTSManager 创建一个新的TTS管理器
 func NewTTSManager() *TTSManager {
 	return &TTSManager{
 		providers: make(map[string]Provider),
+		timeout:   defaultSynthesisTimeout,
+		voiceMap:  make(map[string]map[string]string),
 	}
 }
 
@@ -73,21 +82,74 @@ func (tm *TTSManager) SetDefaultProvider(name string) error {
 }
 
 // Initialize 初始化所有TTS提供商
+// 初始化失败的提供商会从备用链中移除，默认提供商失败时由第一个可用的备用提供商接替
 func (tm *TTSManager) Initialize() error {
 	tm.mutex.Lock()
 	defer tm.mutex.Unlock()
 	
+	var firstErr error
 	for name, provider := range tm.providers {
 		if err := provider.Initialize(); err != nil {
 			log.Printf("[TTS] Failed to initialize provider %s: %v", name, err)
-			return err
+			delete(tm.providers, name)
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
 	
+	var fallback []string
+	for _, name := range tm.fallback {
+		if _, exists := tm.providers[name]; exists {
+			fallback = append(fallback, name)
+		}
+	}
+	tm.fallback = fallback
+	
+	if _, exists := tm.providers[tm.defaultProvider]; !exists {
+		if len(tm.fallback) == 0 {
+			return firstErr
+		}
+		log.Printf("[TTS] Default provider %s unavailable, using %s", tm.defaultProvider, tm.fallback[0])
+		tm.defaultProvider = tm.fallback[0]
+	}
+	
 	tm.initialized = true
 	return nil
 }
 
+// Cleanup 清理所有TTS提供商的资源
+func (tm *TTSManager) Cleanup() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	
+	for name, provider := range tm.providers {
+		if err := provider.Cleanup(); err != nil {
+			log.Printf("[TTS] Failed to clean up provider %s: %v", name, err)
+		}
+	}
+	tm.initialized = false
+}
+
+// SetCache 设置合成结果缓存，传入 nil 关闭缓存
+func (tm *TTSManager) SetCache(cache *AudioCache) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	
+	tm.cache = cache
+}
+
+// CacheStats 返回合成结果缓存的使用情况，没有缓存时返回 false
+func (tm *TTSManager) CacheStats() (CacheStats, bool) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+	
+	if tm.cache == nil {
+		return CacheStats{}, false
+	}
+	return tm.cache.Stats(), true
+}
+
 // GetProvider 获取指定的 TTS 提供商
 func (tm *TTSManager) GetProvider(name string) (Provider, error) {
 	tm.mutex.RLock()
@@ -106,7 +168,8 @@ func (tm *TTSManager) GetProvider(name string) (Provider, error) {
 	return provider, nil
 }
 
-// SynthesizeSpeech 使用默认提供商合成语音
+// SynthesizeSpeech 使用默认提供商合成一段纯文本
+// 默认提供商失败或超时时按备用链依次尝试，切换时把音色替换为备用提供商中相近的音色
 func (tm *TTSManager) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
 	tm.mutex.RLock()
 	defer tm.mutex.RUnlock()
@@ -114,19 +177,72 @@ func (tm *TTSManager) SynthesizeSpeech(text string, options map[string]string) (
 	if !tm.initialized {
 		return nil, ErrNotInitialized
 	}
+	return tm.synthesizeRun(plainRun(text), options)
+}
+
+// synthesizeRun 按提供商链合成一组片段（调用方需持有读锁）
+// 支持 SSML 的提供商收到 SSML，其余提供商收到降级后的纯文本
+func (tm *TTSManager) synthesizeRun(run *speechRun, options map[string]string) ([]byte, error) {
+	// 带提供商前缀的音色优先使用该提供商
+	primary := tm.defaultProvider
+	if name, voice := tm.splitVoiceID(options["voice_id"]); name != "" {
+		primary = name
+		options = withOption(options, "voice_id", voice)
+	}
 	
-	provider, exists := tm.providers[tm.defaultProvider]
-	if !exists {
-		return nil, ErrProviderNotFound
+	cacheText := run.PlainText()
+	if run.markup {
+		cacheText = run.SSML()
+	}
+	cacheOptions := withOption(options, "provider", primary)
+	if tm.cache != nil {
+		if audioData, ok := tm.cache.Get(cacheText, cacheOptions); ok {
+			return audioData, nil
+		}
+	}
+	
+	var lastErr error
+	for i, name := range tm.chain(primary) {
+		provider, exists := tm.providers[name]
+		if !exists {
+			lastErr = ErrProviderNotFound
+			continue
+		}
+		
+		providerOptions := options
+		if i > 0 {
+			log.Printf("[TTS] Falling back to provider %s: %v", name, lastErr)
+			providerOptions = tm.fallbackOptions(options, primary, name)
+		}
+		text := run.PlainText()
+		if run.markup && supportsSSML(provider) {
+			text = run.SSML()
+			providerOptions = withOption(providerOptions, "text_type", "ssml")
+		}
+		
+		audioData, err := synthesizeWithTimeout(provider, text, providerOptions, tm.timeout)
+		if err == nil {
+			// 只缓存请求的提供商合成的结果，避免故障恢复后仍然返回备用提供商的音色
+			if i == 0 && tm.cache != nil {
+				tm.cache.Put(cacheText, cacheOptions, audioData)
+			}
+			return audioData, nil
+		}
+		log.Printf("[TTS] Provider %s failed: %v", name, err)
+		lastErr = err
 	}
 	
-	return provider.SynthesizeSpeech(text, options)
+	return nil, fmt.Errorf("%w: %v", ErrAllProvidersFailed, lastErr)
 }
 
 // 错误定义
 var (
-	ErrProviderNotFound = NewTTSError("tts provider not found")
-	ErrNotInitialized   = NewTTSError("tts manager not initialized")
+	ErrProviderNotFound   = NewTTSError("tts provider not found")
+	ErrNotInitialized     = NewTTSError("tts manager not initialized")
+	ErrTimeout            = NewTTSError("tts synthesis timed out")
+	ErrAllProvidersFailed = NewTTSError("all tts providers failed")
+	ErrVoiceNotFound      = NewTTSError("tts voice not found")
+	ErrEmptyText          = NewTTSError("no speakable text")
 )
 
 // TTSError 表示TTS操作中的错误
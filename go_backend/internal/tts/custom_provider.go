@@ -0,0 +1,338 @@
+package tts
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 自定义 TTS 服务的响应解析方式
+const (
+	CustomResponseRaw  = "raw"  // 响应体就是音频
+	CustomResponseJSON = "json" // JSON 字段中是 base64 编码的音频，例如 json:data.audio
+	CustomResponseURL  = "url"  // JSON 字段中是音频地址，再下载一次，例如 url:result.audio_url
+)
+
+// CustomTTSProvider 通过配置接入任意 HTTP 语音合成服务，无需为新的厂商编写代码
+// URL、请求头和请求体都是模板，可以使用 {text}、{voice}、{speed}、{format} 占位符
+type CustomTTSProvider struct {
+	url          string
+	method       string
+	headers      map[string]string
+	body         string
+	responseMode string
+	responsePath []string // JSON 字段路径，数组元素用下标表示，例如 data.0.audio
+	defaultVoice string
+	voices       []Voice
+	ssml         bool // 服务是否接受 SSML 文本
+	httpClient   *http.Client
+	initialized  bool
+}
+
+// NewCustomTTSProvider 创建一个新的自定义 HTTP TTS 提供商
+func NewCustomTTSProvider(url string) *CustomTTSProvider {
+	return &CustomTTSProvider{
+		url:          url,
+		method:       http.MethodPost,
+		headers:      make(map[string]string),
+		responseMode: CustomResponseRaw,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// SetRequest 设置请求方法、请求头和请求体模板
+func (p *CustomTTSProvider) SetRequest(method string, headers map[string]string, body string) {
+	if method != "" {
+		p.method = strings.ToUpper(method)
+	}
+	for key, value := range headers {
+		p.headers[http.CanonicalHeaderKey(key)] = value
+	}
+	p.body = body
+
+	// 请求体看起来是 JSON 而没有指定 Content-Type 时按 JSON 发送
+	trimmed := strings.TrimSpace(body)
+	if p.headers["Content-Type"] == "" && (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) {
+		p.headers["Content-Type"] = "application/json"
+	}
+}
+
+// SetResponse 设置响应解析方式：raw、json:字段路径 或 url:字段路径
+func (p *CustomTTSProvider) SetResponse(spec string) {
+	if spec == "" {
+		return
+	}
+	mode, path, _ := strings.Cut(spec, ":")
+	p.responseMode = mode
+	p.responsePath = nil
+	if path != "" {
+		p.responsePath = strings.Split(path, ".")
+	}
+}
+
+// SetVoices 设置默认音色和可用音色列表
+func (p *CustomTTSProvider) SetVoices(defaultVoice string, voices []string) {
+	p.defaultVoice = defaultVoice
+	p.voices = nil
+	for _, id := range voices {
+		p.voices = append(p.voices, Voice{
+			ID:       id,
+			Name:     id,
+			Language: "zh-CN",
+			Tags:     map[string]string{"type": "custom"},
+		})
+	}
+}
+
+// SetSSML 声明服务是否接受 SSML，接受时 {text} 替换为 <speak> 包裹的 SSML
+func (p *CustomTTSProvider) SetSSML(enabled bool) {
+	p.ssml = enabled
+}
+
+// SupportsSSML 实现 SSMLProvider 接口
+func (p *CustomTTSProvider) SupportsSSML() bool {
+	return p.ssml
+}
+
+// SynthesizeSpeech 按模板请求自定义服务并取出音频
+// 支持的选项：voice_id、speed、format（默认 mp3）
+func (p *CustomTTSProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	req, err := p.newRequest(text, options)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[TTS:Custom] Synthesizing speech for text: %s", text)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error response from API [%d]: %s", resp.StatusCode, truncateBody(body))
+	}
+
+	audioData, contentType, err := p.extractAudio(req.URL, resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+
+	format := detectAudioFormat(contentType, audioData)
+	if format == "" {
+		return nil, fmt.Errorf("custom tts response is not audio (%s): %s", contentType, truncateBody(audioData))
+	}
+	if format == "wav" && options["format"] == "pcm" {
+		pcm, _, err := unwrapWAV(audioData)
+		return pcm, err
+	}
+
+	log.Printf("[TTS:Custom] Successfully generated %s audio, size: %d bytes", format, len(audioData))
+	return audioData, nil
+}
+
+// newRequest 展开模板，构造 HTTP 请求
+// URL 中的占位符做 URL 编码；请求体按 Content-Type 做 JSON 或表单编码
+func (p *CustomTTSProvider) newRequest(text string, options map[string]string) (*http.Request, error) {
+	values := map[string]string{
+		"text":   text,
+		"voice":  p.defaultVoice,
+		"speed":  strconv.FormatFloat(parseRatio(options["speed"], 1.0), 'f', -1, 64),
+		"format": "mp3",
+	}
+	if voice := options["voice_id"]; voice != "" {
+		values["voice"] = voice
+	}
+	if format := options["format"]; format != "" {
+		values["format"] = format
+	}
+
+	requestURL := renderTemplate(p.url, values, url.QueryEscape)
+
+	var bodyReader io.Reader
+	if p.body != "" {
+		escape := func(s string) string { return s }
+		contentType := strings.ToLower(p.headers["Content-Type"])
+		switch {
+		case strings.Contains(contentType, "json"):
+			escape = jsonEscape
+		case strings.Contains(contentType, "x-www-form-urlencoded"):
+			escape = url.QueryEscape
+		}
+		bodyReader = strings.NewReader(renderTemplate(p.body, values, escape))
+	}
+
+	req, err := http.NewRequest(p.method, requestURL, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, renderTemplate(value, values, func(s string) string { return s }))
+	}
+	return req, nil
+}
+
+// renderTemplate 替换模板中的 {name} 占位符，替换值先经过 escape 编码
+func renderTemplate(template string, values map[string]string, escape func(string) string) string {
+	pairs := make([]string, 0, len(values)*2)
+	for name, value := range values {
+		pairs = append(pairs, "{"+name+"}", escape(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// jsonEscape 返回可以直接放进 JSON 字符串字面量中的文本
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// extractAudio 按配置的方式从响应中取出音频，返回音频和它的 Content-Type
+func (p *CustomTTSProvider) extractAudio(requestURL *url.URL, contentType string, body []byte) ([]byte, string, error) {
+	switch p.responseMode {
+	case CustomResponseRaw:
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return body, contentType, nil
+
+	case CustomResponseJSON:
+		value, err := jsonField(body, p.responsePath)
+		if err != nil {
+			return nil, "", err
+		}
+		audioData, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("error decoding audio: %w", err)
+		}
+		// 解码后的数据没有 Content-Type，无法从文件头识别时按 PCM 处理
+		return audioData, "application/octet-stream", nil
+
+	case CustomResponseURL:
+		value, err := jsonField(body, p.responsePath)
+		if err != nil {
+			return nil, "", err
+		}
+		audioURL, err := requestURL.Parse(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid audio url %q: %w", value, err)
+		}
+		return p.download(audioURL.String())
+
+	default:
+		return nil, "", fmt.Errorf("unknown custom tts response mode: %s", p.responseMode)
+	}
+}
+
+// download 下载响应中给出的音频文件
+func (p *CustomTTSProvider) download(audioURL string) ([]byte, string, error) {
+	resp, err := p.httpClient.Get(audioURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("error downloading audio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	audioData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("error downloading audio: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("error downloading audio [%d]: %s", resp.StatusCode, truncateBody(audioData))
+	}
+	return audioData, resp.Header.Get("Content-Type"), nil
+}
+
+// jsonField 按路径取出 JSON 中的字符串字段
+func jsonField(body []byte, path []string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", fmt.Errorf("error parsing response: %w: %s", err, truncateBody(body))
+	}
+
+	for _, key := range path {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", fmt.Errorf("response field %q not found: %s", strings.Join(path, "."), truncateBody(body))
+			}
+			value = node[index]
+		default:
+			value = nil
+		}
+		if value == nil {
+			return "", fmt.Errorf("response field %q not found: %s", strings.Join(path, "."), truncateBody(body))
+		}
+	}
+
+	text, ok := value.(string)
+	if !ok || text == "" {
+		return "", fmt.Errorf("response field %q is not a string: %s", strings.Join(path, "."), truncateBody(body))
+	}
+	return text, nil
+}
+
+// truncateBody 截取响应体的开头用于日志和错误信息
+func truncateBody(body []byte) string {
+	const limit = 200
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
+	}
+	return string(body)
+}
+
+// GetVoices 返回配置的音色
+func (p *CustomTTSProvider) GetVoices() ([]Voice, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+	voices := make([]Voice, len(p.voices))
+	copy(voices, p.voices)
+	return voices, nil
+}
+
+// Initialize 检查配置
+func (p *CustomTTSProvider) Initialize() error {
+	if _, err := url.Parse(p.url); err != nil || p.url == "" {
+		return fmt.Errorf("invalid custom tts url: %q", p.url)
+	}
+	switch p.responseMode {
+	case CustomResponseRaw:
+	case CustomResponseJSON, CustomResponseURL:
+		if len(p.responsePath) == 0 {
+			return fmt.Errorf("custom tts response mode %s requires a field path", p.responseMode)
+		}
+	default:
+		return fmt.Errorf("unknown custom tts response mode: %s", p.responseMode)
+	}
+
+	log.Printf("[TTS:Custom] Initializing custom TTS provider: %s %s", p.method, p.url)
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理自定义 TTS 提供商资源
+func (p *CustomTTSProvider) Cleanup() error {
+	log.Printf("[TTS:Custom] Cleaning up custom TTS provider")
+	p.initialized = false
+	return nil
+}
@@ -0,0 +1,199 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 文本中带引号、换行和表单分隔符，检查模板展开时的编码
+const customTestText = "他说：\"你好\"\n1+1=2 & 再见"
+
+// mp3Audio 是一个最小的 MP3 帧头，足以被识别为 mp3
+var mp3Audio = []byte{0xFF, 0xFB, 0x90, 0x00, 0x01, 0x02}
+
+// newCustomProvider 创建并初始化一个指向 server 的自定义提供商
+func newCustomProvider(t *testing.T, url, method string, headers map[string]string, body, response string) *CustomTTSProvider {
+	t.Helper()
+
+	provider := NewCustomTTSProvider(url)
+	provider.SetRequest(method, headers, body)
+	provider.SetResponse(response)
+	provider.SetVoices("xiaoyan", []string{"xiaoyan", "xiaofeng"})
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return provider
+}
+
+func TestCustomProviderJSONBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q, want json detected from the body", r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("X-Voice") != "xiaofeng" {
+			t.Errorf("X-Voice = %q", r.Header.Get("X-Voice"))
+		}
+		if r.URL.Query().Get("t") != customTestText || r.URL.Query().Get("fmt") != "mp3" {
+			t.Errorf("query = %q", r.URL.RawQuery)
+		}
+
+		var body struct {
+			Text  string  `json:"text"`
+			Voice string  `json:"voice"`
+			Speed float64 `json:"speed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("body is not valid json: %v", err)
+		}
+		if body.Text != customTestText || body.Voice != "xiaofeng" || body.Speed != 1.5 {
+			t.Errorf("body = %+v", body)
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write(mp3Audio)
+	}))
+	defer server.Close()
+
+	provider := newCustomProvider(t, server.URL+"/tts?fmt={format}&t={text}", "post",
+		map[string]string{"x-voice": "{voice}"},
+		`{"text":"{text}","voice":"{voice}","speed":{speed}}`, "raw")
+
+	audio, err := provider.SynthesizeSpeech(customTestText, map[string]string{"voice_id": "xiaofeng", "speed": "1.5"})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if !bytes.Equal(audio, mp3Audio) {
+		t.Errorf("audio = % x", audio)
+	}
+}
+
+func TestCustomProviderFormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s", r.Method)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		if r.PostForm.Get("text") != customTestText || r.PostForm.Get("voice") != "xiaoyan" || r.PostForm.Get("format") != "wav" {
+			t.Errorf("form = %v", r.PostForm)
+		}
+		// 没有 Content-Type 时按文件头识别
+		w.Header().Set("Content-Type", "")
+		w.Write(wrapPCM([]byte{1, 0, 2, 0}, pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}))
+	}))
+	defer server.Close()
+
+	provider := newCustomProvider(t, server.URL, "PUT",
+		map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		"text={text}&voice={voice}&format={format}", "raw")
+
+	audio, err := provider.SynthesizeSpeech(customTestText, map[string]string{"format": "wav"})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if !isWAV(audio) {
+		t.Errorf("audio is not wav: %d bytes", len(audio))
+	}
+}
+
+func TestCustomProviderJSONResponse(t *testing.T) {
+	wav := wrapPCM([]byte{1, 0, 2, 0, 3, 0}, pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":0,"data":[{"audio":%q}]}`, base64.StdEncoding.EncodeToString(wav))
+	}))
+	defer server.Close()
+
+	provider := newCustomProvider(t, server.URL, "", nil, `{"text":"{text}"}`, "json:data.0.audio")
+
+	audio, err := provider.SynthesizeSpeech("你好", nil)
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if !bytes.Equal(audio, wav) {
+		t.Errorf("audio = % x, want the decoded wav", audio)
+	}
+
+	// 请求 pcm 时去掉 WAV 文件头
+	pcm, err := provider.SynthesizeSpeech("你好", map[string]string{"format": "pcm"})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech pcm: %v", err)
+	}
+	if !bytes.Equal(pcm, []byte{1, 0, 2, 0, 3, 0}) {
+		t.Errorf("pcm = % x", pcm)
+	}
+}
+
+func TestCustomProviderURLResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// 相对地址按请求地址解析
+		w.Write([]byte(`{"result":{"audio_url":"/files/1.mp3"}}`))
+	})
+	mux.HandleFunc("/files/1.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write(mp3Audio)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := newCustomProvider(t, server.URL+"/tts", "", nil, `{"text":"{text}"}`, "url:result.audio_url")
+
+	audio, err := provider.SynthesizeSpeech("你好", nil)
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if !bytes.Equal(audio, mp3Audio) {
+		t.Errorf("audio = % x", audio)
+	}
+}
+
+func TestCustomProviderErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string // 提供商的响应解析方式
+		status      int
+		contentType string
+		body        string
+		want        string // 错误信息中应包含的内容
+	}{
+		{"html page", "raw", http.StatusOK, "text/html", "<html>登录已过期</html>", "not audio"},
+		{"json error", "raw", http.StatusOK, "application/json", `{"error":"quota exceeded"}`, "not audio"},
+		{"http error", "raw", http.StatusInternalServerError, "text/plain", "boom", "[500]"},
+		{"missing field", "json:data.0.audio", http.StatusOK, "application/json", `{"data":[]}`, "not found"},
+		{"not a string", "json:data.audio", http.StatusOK, "application/json", `{"data":{"audio":42}}`, "not a string"},
+		{"bad base64", "json:audio", http.StatusOK, "application/json", `{"audio":"***"}`, "decoding audio"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			provider := newCustomProvider(t, server.URL, "", nil, `{"text":"{text}"}`, tt.response)
+			audio, err := provider.SynthesizeSpeech("你好", nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v (audio %d bytes), want it to mention %q", err, len(audio), tt.want)
+			}
+		})
+	}
+
+	// json 和 url 方式必须给出字段路径
+	provider := NewCustomTTSProvider("http://127.0.0.1/tts")
+	provider.SetResponse("json")
+	if err := provider.Initialize(); err == nil {
+		t.Error("Initialize accepted json response mode without a field path")
+	}
+}
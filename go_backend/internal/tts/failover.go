@@ -0,0 +1,157 @@
+package tts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultSynthesisTimeout 是单句合成的默认超时，与 python 端 tts_timeout 的默认值一致
+const defaultSynthesisTimeout = 10 * time.Second
+
+// SetFallbackChain 设置备用提供商链，默认提供商失败或超时后按顺序尝试
+func (tm *TTSManager) SetFallbackChain(names ...string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	for _, name := range names {
+		if _, exists := tm.providers[name]; !exists {
+			return fmt.Errorf("%w: %s", ErrProviderNotFound, name)
+		}
+	}
+	tm.fallback = append([]string{}, names...)
+	return nil
+}
+
+// SetTimeout 设置单句合成的超时，超时后切换到下一个提供商
+func (tm *TTSManager) SetTimeout(timeout time.Duration) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if timeout > 0 {
+		tm.timeout = timeout
+	}
+}
+
+// SetVoiceMapping 设置切换到 provider 时 voice 对应的音色，voice 可以带提供商前缀
+func (tm *TTSManager) SetVoiceMapping(voice, provider, mapped string) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if tm.voiceMap[voice] == nil {
+		tm.voiceMap[voice] = make(map[string]string)
+	}
+	tm.voiceMap[voice][provider] = mapped
+}
+
+// ParseVoiceMapping 解析 音色=提供商:音色 格式的音色映射
+func ParseVoiceMapping(spec string) (voice, provider, mapped string, err error) {
+	voice, target, ok := strings.Cut(spec, "=")
+	if ok {
+		provider, mapped, ok = strings.Cut(target, ":")
+	}
+	voice, provider, mapped = strings.TrimSpace(voice), strings.TrimSpace(provider), strings.TrimSpace(mapped)
+	if !ok || voice == "" || provider == "" || mapped == "" {
+		return "", "", "", fmt.Errorf("invalid tts voice mapping %q, expected voice=provider:voice", spec)
+	}
+	return voice, provider, mapped, nil
+}
+
+// chain 返回本次合成要依次尝试的提供商，primary 排在最前（调用方需持有读锁）
+func (tm *TTSManager) chain(primary string) []string {
+	names := []string{primary}
+	if primary != tm.defaultProvider {
+		names = append(names, tm.defaultProvider)
+	}
+	for _, name := range tm.fallback {
+		if name != primary && name != tm.defaultProvider {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// synthesizeWithTimeout 调用提供商合成语音，超过 timeout 未返回时放弃等待
+// 提供商接口不支持取消，超时的请求在后台自行结束
+func synthesizeWithTimeout(provider Provider, text string, options map[string]string, timeout time.Duration) ([]byte, error) {
+	type result struct {
+		audio []byte
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		audioData, err := provider.SynthesizeSpeech(text, options)
+		done <- result{audioData, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.audio, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%w after %v", ErrTimeout, timeout)
+	}
+}
+
+// fallbackOptions 返回切换到备用提供商时使用的选项
+// 请求的音色按映射表替换；没有映射时选择性别和语言相同的音色，都找不到时使用该提供商的默认音色
+func (tm *TTSManager) fallbackOptions(options map[string]string, from, to string) map[string]string {
+	voice := options["voice_id"]
+	if voice == "" {
+		return options
+	}
+
+	mapped := tm.voiceMap[QualifyVoiceID(from, voice)][to]
+	if mapped == "" {
+		mapped = tm.voiceMap[voice][to]
+	}
+	if mapped == "" {
+		mapped = tm.similarVoice(voice, from, to)
+	}
+	return withOption(options, "voice_id", mapped)
+}
+
+// withOption 返回设置了 key 的选项副本，value 为空时删除该选项
+func withOption(options map[string]string, key, value string) map[string]string {
+	copied := make(map[string]string, len(options)+1)
+	for k, v := range options {
+		copied[k] = v
+	}
+	if value == "" {
+		delete(copied, key)
+	} else {
+		copied[key] = value
+	}
+	return copied
+}
+
+// similarVoice 在提供商 to 中查找与 from 的音色性别、语言相同的音色（调用方需持有读锁）
+func (tm *TTSManager) similarVoice(voice, from, to string) string {
+	source, target := tm.providers[from], tm.providers[to]
+	if source == nil || target == nil {
+		return ""
+	}
+	sourceVoices, err := source.GetVoices()
+	if err != nil {
+		return ""
+	}
+	targetVoices, err := target.GetVoices()
+	if err != nil {
+		return ""
+	}
+
+	for _, sv := range sourceVoices {
+		if sv.ID != voice {
+			continue
+		}
+		for _, tv := range targetVoices {
+			if tv.Gender == sv.Gender && (tv.Language == sv.Language || tv.Language == "" || sv.Language == "") {
+				return tv.ID
+			}
+		}
+		break
+	}
+	return ""
+}
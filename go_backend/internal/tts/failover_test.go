@@ -0,0 +1,167 @@
+package tts
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider 按配置返回固定音频或错误，并记录每次调用收到的选项
+type fakeProvider struct {
+	audio  []byte
+	err    error
+	delay  time.Duration
+	voices []Voice
+
+	mu    sync.Mutex
+	calls []map[string]string
+}
+
+func (p *fakeProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, options)
+	p.mu.Unlock()
+
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.audio, nil
+}
+
+func (p *fakeProvider) GetVoices() ([]Voice, error) { return p.voices, nil }
+func (p *fakeProvider) Initialize() error           { return nil }
+func (p *fakeProvider) Cleanup() error              { return nil }
+
+// callCount 返回提供商被调用的次数
+func (p *fakeProvider) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.calls)
+}
+
+// lastVoice 返回最后一次调用的 voice_id 选项
+func (p *fakeProvider) lastVoice() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.calls) == 0 {
+		return ""
+	}
+	return p.calls[len(p.calls)-1]["voice_id"]
+}
+
+// newFailoverManager 按注册顺序创建管理器，第一个提供商为默认，其余依次组成备用链
+func newFailoverManager(t *testing.T, names []string, providers ...*fakeProvider) *TTSManager {
+	t.Helper()
+
+	manager := NewTTSManager()
+	for i, name := range names {
+		manager.RegisterProvider(name, providers[i])
+	}
+	if err := manager.SetFallbackChain(names[1:]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+func TestFailoverOrder(t *testing.T) {
+	failure := errors.New("vendor unavailable")
+	primary := &fakeProvider{err: failure}
+	second := &fakeProvider{err: failure}
+	third := &fakeProvider{audio: []byte("third")}
+	manager := newFailoverManager(t, []string{"primary", "second", "third"}, primary, second, third)
+
+	audio, err := manager.SynthesizeSpeech("你好", nil)
+	if err != nil || string(audio) != "third" {
+		t.Fatalf("audio = %q, err %v, want the third provider's audio", audio, err)
+	}
+	if primary.callCount() != 1 || second.callCount() != 1 || third.callCount() != 1 {
+		t.Errorf("calls = %d %d %d, want each provider tried once in order", primary.callCount(), second.callCount(), third.callCount())
+	}
+
+	// 带提供商前缀的音色先尝试该提供商，成功后不再调用其他提供商
+	if _, err := manager.SynthesizeSpeech("你好", map[string]string{"voice_id": "third:girl"}); err != nil {
+		t.Fatal(err)
+	}
+	if primary.callCount() != 1 || third.lastVoice() != "girl" {
+		t.Errorf("prefixed voice: primary calls %d, third voice %q", primary.callCount(), third.lastVoice())
+	}
+
+	third.err = failure
+	if _, err := manager.SynthesizeSpeech("再见", nil); !errors.Is(err, ErrAllProvidersFailed) {
+		t.Errorf("err = %v, want ErrAllProvidersFailed", err)
+	}
+}
+
+func TestFailoverTimeout(t *testing.T) {
+	slow := &fakeProvider{audio: []byte("slow"), delay: 300 * time.Millisecond}
+	fast := &fakeProvider{audio: []byte("fast")}
+	manager := newFailoverManager(t, []string{"slow", "fast"}, slow, fast)
+	manager.SetTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	audio, err := manager.SynthesizeSpeech("你好", nil)
+	if err != nil || string(audio) != "fast" {
+		t.Fatalf("audio = %q, err %v, want the fallback's audio", audio, err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("fallback took %v, want it right after the 50ms timeout", elapsed)
+	}
+
+	// 没有备用提供商时返回超时错误
+	alone := newFailoverManager(t, []string{"slow"}, slow)
+	alone.SetTimeout(50 * time.Millisecond)
+	_, err = alone.SynthesizeSpeech("你好", nil)
+	if !errors.Is(err, ErrAllProvidersFailed) || !strings.Contains(err.Error(), ErrTimeout.Error()) {
+		t.Errorf("err = %v, want all providers failed with a timeout", err)
+	}
+}
+
+func TestFailoverVoiceMapping(t *testing.T) {
+	failure := errors.New("vendor unavailable")
+	primary := &fakeProvider{err: failure, voices: []Voice{
+		{ID: "zh_female_1", Gender: "female", Language: "zh-CN"},
+		{ID: "zh_male_1", Gender: "male", Language: "zh-CN"},
+		{ID: "robot", Language: "zh-CN"},
+	}}
+	backup := &fakeProvider{audio: []byte("backup"), voices: []Voice{
+		{ID: "en_male", Gender: "male", Language: "en-US"},
+		{ID: "xiaoyun", Gender: "female", Language: "zh-CN"},
+		{ID: "xiaogang", Gender: "male", Language: "zh-CN"},
+	}}
+	manager := newFailoverManager(t, []string{"primary", "backup"}, primary, backup)
+
+	voice, provider, mapped, err := ParseVoiceMapping(" primary:zh_female_1 = backup:xiaogang ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetVoiceMapping(voice, provider, mapped)
+	if _, _, _, err := ParseVoiceMapping("zh_female_1=xiaogang"); err == nil {
+		t.Error("ParseVoiceMapping accepted a mapping without a provider")
+	}
+
+	tests := []struct {
+		voice string
+		want  string
+	}{
+		{"zh_female_1", "xiaogang"}, // 映射表优先
+		{"zh_male_1", "xiaogang"},   // 性别和语言都相同
+		{"robot", ""},               // 没有相近的音色时使用备用提供商的默认音色
+	}
+	for _, tt := range tests {
+		if _, err := manager.SynthesizeSpeech("你好", map[string]string{"voice_id": tt.voice}); err != nil {
+			t.Fatalf("%s: %v", tt.voice, err)
+		}
+		if got := backup.lastVoice(); got != tt.want {
+			t.Errorf("voice %s mapped to %q, want %q", tt.voice, got, tt.want)
+		}
+		if primary.lastVoice() != tt.voice {
+			t.Errorf("primary received voice %q, want %q", primary.lastVoice(), tt.voice)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 合成接口支持的输出格式
+const (
+	FormatMP3  = "mp3"
+	FormatWAV  = "wav"
+	FormatPCM  = "pcm"
+	FormatOpus = "opus" // Ogg 封装的 Opus
+	FormatP3   = "p3"   // 固件使用的 Opus 帧格式，每帧前有 4 字节帧头
+)
+
+// ErrUnsupportedConversion 表示无法把提供商返回的音频转换为请求的格式
+var ErrUnsupportedConversion = NewTTSError("unsupported audio conversion")
+
+// IsSupportedFormat 判断是否是合成接口支持的输出格式
+func IsSupportedFormat(format string) bool {
+	switch format {
+	case FormatMP3, FormatWAV, FormatPCM, FormatOpus, FormatP3:
+		return true
+	}
+	return false
+}
+
+// ProviderFormat 返回请求提供商时使用的 format 选项
+// 提供商不直接输出 p3，先请求 Ogg Opus 再重新封装
+func ProviderFormat(format string) string {
+	switch format {
+	case FormatOpus, FormatP3:
+		return "ogg_opus"
+	}
+	return format
+}
+
+// FormatContentType 返回输出格式对应的 MIME 类型
+func FormatContentType(format string) string {
+	switch format {
+	case FormatMP3:
+		return "audio/mpeg"
+	case FormatWAV:
+		return "audio/wav"
+	case FormatPCM:
+		return "audio/pcm"
+	case FormatOpus:
+		return "audio/ogg"
+	}
+	return "application/octet-stream"
+}
+
+// DetectFormat 根据文件头判断音频属于哪种输出格式，无法识别时返回空字符串
+func DetectFormat(data []byte) string {
+	switch detectAudioFormat("", data) {
+	case "mp3":
+		return FormatMP3
+	case "wav":
+		return FormatWAV
+	case "ogg_opus":
+		return FormatOpus
+	}
+	return ""
+}
+
+// ConvertAudio 把音频转换为指定的输出格式
+// 只做封装层面的转换（WAV 转 PCM、Ogg Opus 转 p3），不做编解码；无法识别的数据原样返回
+func ConvertAudio(data []byte, format string) ([]byte, error) {
+	source := DetectFormat(data)
+	switch {
+	case source == "" || source == format:
+		return data, nil
+	case source == FormatWAV && format == FormatPCM:
+		pcm, _, err := unwrapWAV(data)
+		return pcm, err
+	case source == FormatOpus && format == FormatP3:
+		return oggToP3(data)
+	}
+	return nil, fmt.Errorf("%w: %s to %s", ErrUnsupportedConversion, source, format)
+}
+
+// oggToP3 从 Ogg 容器中取出 Opus 包，按 p3 格式逐帧写出
+// p3 帧头为 1 字节类型、1 字节保留和 2 字节大端帧长
+func oggToP3(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	var packet []byte
+	packetIndex := 0
+
+	for offset := 0; offset < len(data); {
+		if offset+27 > len(data) || !bytes.Equal(data[offset:offset+4], []byte("OggS")) {
+			return nil, fmt.Errorf("invalid ogg page at offset %d", offset)
+		}
+		segmentCount := int(data[offset+26])
+		tableEnd := offset + 27 + segmentCount
+		if tableEnd > len(data) {
+			return nil, fmt.Errorf("ogg segment table truncated")
+		}
+
+		body := tableEnd
+		for _, lacing := range data[offset+27 : tableEnd] {
+			size := int(lacing)
+			if body+size > len(data) {
+				return nil, fmt.Errorf("ogg page truncated")
+			}
+			packet = append(packet, data[body:body+size]...)
+			body += size
+
+			// 长度小于 255 的段表示一个包结束，前两个包是 OpusHead 和 OpusTags
+			if size < 255 {
+				if packetIndex >= 2 {
+					header := make([]byte, 4)
+					binary.BigEndian.PutUint16(header[2:4], uint16(len(packet)))
+					out.Write(header)
+					out.Write(packet)
+				}
+				packetIndex++
+				packet = packet[:0]
+			}
+		}
+		offset = body
+	}
+	return out.Bytes(), nil
+}
+
+// AudioStream 把逐句合成的音频拼接成一路连续输出
+type AudioStream struct {
+	format  string
+	started bool
+}
+
+// NewAudioStream 创建一个输出 format 格式的音频流
+func NewAudioStream(format string) *AudioStream {
+	return &AudioStream{format: format}
+}
+
+// Next 把一段合成结果转换为要写出的数据，无法转换为请求的格式时返回 ErrUnsupportedConversion，
+// 不会改用提供商返回的格式输出
+// WAV 只在第一段写出文件头，头中的长度字段按流式输出填最大值，后续各段只写 PCM 数据
+func (s *AudioStream) Next(segment []byte) ([]byte, error) {
+	first := !s.started
+	s.started = true
+
+	if s.format != FormatWAV {
+		return ConvertAudio(segment, s.format)
+	}
+
+	if source := DetectFormat(segment); source != "" && source != FormatWAV {
+		return nil, fmt.Errorf("%w: %s to %s", ErrUnsupportedConversion, source, FormatWAV)
+	}
+	pcm, format, err := unwrapWAV(segment)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return pcm, nil
+	}
+	header := wrapPCM(nil, format)
+	binary.LittleEndian.PutUint32(header[4:8], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(header[40:44], 0xFFFFFFFF)
+	return append(header, pcm...), nil
+}
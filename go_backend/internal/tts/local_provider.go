@@ -0,0 +1,382 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 本地引擎的输出格式
+const (
+	LocalOutputWAV = "wav" // 引擎输出完整的 WAV 文件
+	LocalOutputPCM = "pcm" // 引擎输出原始 16 位 PCM，由提供商补上 WAV 头
+)
+
+// localSynthesisTimeout 是单句合成的最长时间，超时后结束引擎进程
+const localSynthesisTimeout = 30 * time.Second
+
+// LocalTTSProvider 通过子进程调用本地语音合成引擎（Piper、espeak-ng、sherpa-onnx 等），适用于离线部署
+// 文本从标准输入写入，音频从标准输出读取
+// 引擎加载模型较慢，因此预先启动若干等待输入的进程，每个进程只合成一句，用完后在后台补充
+type LocalTTSProvider struct {
+	command      []string // 命令模板，参数中可以使用 {voice}、{speed}、{length_scale}、{sample_rate}、{text} 占位符
+	output       string
+	sampleRate   int
+	defaultVoice string
+	voices       []Voice
+	poolSize     int
+
+	mu          sync.Mutex
+	pool        chan *localProcess // 按默认参数预先启动的进程
+	poolKey     string
+	closed      bool
+	initialized bool
+}
+
+// localProcess 是一个已启动、等待文本输入的引擎进程
+type localProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	cancel context.CancelFunc
+	warm   bool // 来自进程池，失败时允许用新进程重试一次
+}
+
+// NewLocalTTSProvider 创建一个新的本地 TTS 提供商，command 为命令模板，例如：
+// piper --model /models/{voice}.onnx --output_file - --length_scale {length_scale}
+func NewLocalTTSProvider(command string) *LocalTTSProvider {
+	return &LocalTTSProvider{
+		command:    splitCommand(command),
+		output:     LocalOutputWAV,
+		sampleRate: 22050,
+		poolSize:   2,
+	}
+}
+
+// SetOutput 设置引擎的输出格式和 PCM 采样率
+func (p *LocalTTSProvider) SetOutput(output string, sampleRate int) {
+	if output != "" {
+		p.output = output
+	}
+	if sampleRate > 0 {
+		p.sampleRate = sampleRate
+	}
+}
+
+// SetVoices 设置默认音色和可用音色列表，音色 ID 会替换命令模板中的 {voice}
+func (p *LocalTTSProvider) SetVoices(defaultVoice string, voices []string) {
+	p.defaultVoice = defaultVoice
+	p.voices = nil
+	for _, id := range voices {
+		p.voices = append(p.voices, Voice{
+			ID:       id,
+			Name:     id,
+			Language: "zh-CN",
+			Tags:     map[string]string{"type": "local"},
+		})
+	}
+}
+
+// SetPoolSize 设置预先启动的进程数，0 表示每句话都临时启动进程
+func (p *LocalTTSProvider) SetPoolSize(size int) {
+	if size >= 0 {
+		p.poolSize = size
+	}
+}
+
+// SynthesizeSpeech 调用本地引擎合成语音
+// 支持的选项：voice_id、speed、format（wav 或 pcm，默认 wav）
+func (p *LocalTTSProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	args, stdinText := p.render(text, options)
+	log.Printf("[TTS:Local] Synthesizing speech for text: %s", text)
+
+	output, err := p.run(args, stdinText)
+	if err != nil {
+		return nil, err
+	}
+
+	audioData, err := p.convert(output, options["format"])
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[TTS:Local] Successfully generated audio, size: %d bytes", len(audioData))
+	return audioData, nil
+}
+
+// render 按选项展开命令模板；模板包含 {text} 时文本作为参数传入，不再写入标准输入
+func (p *LocalTTSProvider) render(text string, options map[string]string) ([]string, string) {
+	voice := p.defaultVoice
+	if id := options["voice_id"]; id != "" {
+		voice = id
+	}
+	speed := parseRatio(options["speed"], 1.0)
+
+	replacer := strings.NewReplacer(
+		"{voice}", voice,
+		"{speed}", strconv.FormatFloat(speed, 'f', 2, 64),
+		"{length_scale}", strconv.FormatFloat(1/speed, 'f', 2, 64),
+		"{sample_rate}", strconv.Itoa(p.sampleRate),
+		"{text}", text,
+	)
+
+	stdinText := text + "\n"
+	args := make([]string, len(p.command))
+	for i, arg := range p.command {
+		if strings.Contains(arg, "{text}") {
+			stdinText = ""
+		}
+		args[i] = replacer.Replace(arg)
+	}
+	return args, stdinText
+}
+
+// run 用一个进程合成一句话，优先使用预先启动的进程
+func (p *LocalTTSProvider) run(args []string, stdinText string) ([]byte, error) {
+	process := p.take(args, stdinText)
+	if process == nil {
+		var err error
+		if process, err = startLocalProcess(args); err != nil {
+			return nil, err
+		}
+	}
+
+	output, err := process.synthesize(stdinText)
+	if err == nil || !process.warm {
+		return output, err
+	}
+
+	// 预先启动的进程可能已经意外退出，临时启动一个进程重试一次
+	log.Printf("[TTS:Local] Warm process failed, retrying with a new process: %v", err)
+	if process, err = startLocalProcess(args); err != nil {
+		return nil, err
+	}
+	return process.synthesize(stdinText)
+}
+
+// take 取出一个与本次参数相同的预启动进程，并在后台补充进程池
+func (p *LocalTTSProvider) take(args []string, stdinText string) *localProcess {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pool == nil || stdinText == "" || strings.Join(args, "\x00") != p.poolKey {
+		return nil
+	}
+
+	select {
+	case process := <-p.pool:
+		go p.refill(args)
+		process.warm = true
+		return process
+	default:
+		return nil
+	}
+}
+
+// refill 启动一个新进程放入进程池
+func (p *LocalTTSProvider) refill(args []string) {
+	process, err := startLocalProcess(args)
+	if err != nil {
+		log.Printf("[TTS:Local] Error starting warm process: %v", err)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		go process.kill()
+		return
+	}
+	select {
+	case p.pool <- process:
+	default:
+		go process.kill()
+	}
+}
+
+// convert 把引擎输出转换为请求的格式
+func (p *LocalTTSProvider) convert(output []byte, format string) ([]byte, error) {
+	if len(output) == 0 {
+		return nil, fmt.Errorf("local tts engine produced no audio")
+	}
+
+	if p.output == LocalOutputPCM {
+		if format == "pcm" {
+			return output, nil
+		}
+		return wrapPCM(output, pcmFormat{SampleRate: p.sampleRate, Channels: 1, BitsPerSample: 16}), nil
+	}
+
+	if format == "pcm" {
+		pcm, _, err := unwrapWAV(output)
+		return pcm, err
+	}
+	return output, nil
+}
+
+// startLocalProcess 启动引擎进程，进程会阻塞在读取标准输入上
+func startLocalProcess(args []string) (*localProcess, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("local tts command is empty")
+	}
+
+	// 进程可能在池中等待较久，超时从取出进程时开始计算
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("error creating stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("error creating stdout pipe: %w", err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	// 引擎被结束后，它派生的子进程可能仍占用输出管道，不再等待它们
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("error starting local tts engine: %w", err)
+	}
+	return &localProcess{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr, cancel: cancel}, nil
+}
+
+// synthesize 写入文本并读取全部输出，进程随后退出
+func (lp *localProcess) synthesize(text string) ([]byte, error) {
+	timer := time.AfterFunc(localSynthesisTimeout, lp.cancel)
+	defer timer.Stop()
+	defer lp.cancel()
+
+	if text != "" {
+		if _, err := io.WriteString(lp.stdin, text); err != nil {
+			lp.kill()
+			return nil, fmt.Errorf("error writing text to local tts engine: %w", err)
+		}
+	}
+	lp.stdin.Close()
+
+	output, readErr := io.ReadAll(lp.stdout)
+	if err := lp.cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("local tts engine failed: %w: %s", err, strings.TrimSpace(lp.stderr.String()))
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("error reading audio from local tts engine: %w", readErr)
+	}
+	return output, nil
+}
+
+// kill 结束进程并回收资源
+func (lp *localProcess) kill() {
+	lp.cancel()
+	lp.cmd.Wait()
+}
+
+// splitCommand 按空白拆分命令模板，支持用单引号或双引号包含空格
+func splitCommand(command string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// GetVoices 返回配置的本地音色
+func (p *LocalTTSProvider) GetVoices() ([]Voice, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+	voices := make([]Voice, len(p.voices))
+	copy(voices, p.voices)
+	return voices, nil
+}
+
+// Initialize 检查引擎可执行文件并预先启动进程池
+func (p *LocalTTSProvider) Initialize() error {
+	if len(p.command) == 0 {
+		return fmt.Errorf("local tts command is empty")
+	}
+	if _, err := exec.LookPath(p.command[0]); err != nil {
+		return fmt.Errorf("local tts engine not found: %w", err)
+	}
+
+	log.Printf("[TTS:Local] Initializing local TTS provider: %s (pool size %d)", p.command[0], p.poolSize)
+
+	p.mu.Lock()
+	p.closed = false
+	args, stdinText := p.render("", nil)
+	if p.poolSize > 0 && stdinText != "" {
+		p.pool = make(chan *localProcess, p.poolSize)
+		p.poolKey = strings.Join(args, "\x00")
+	}
+	p.mu.Unlock()
+
+	for i := 0; i < p.poolSize && p.pool != nil; i++ {
+		p.refill(args)
+	}
+
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 结束进程池中所有等待的进程
+func (p *LocalTTSProvider) Cleanup() error {
+	log.Printf("[TTS:Local] Cleaning up local TTS provider")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.initialized = false
+	if p.pool == nil {
+		return nil
+	}
+	for {
+		select {
+		case process := <-p.pool:
+			go process.kill()
+		default:
+			p.pool = nil
+			return nil
+		}
+	}
+}
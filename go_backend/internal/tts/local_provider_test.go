@@ -0,0 +1,284 @@
+package tts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEngine 写一个模拟本地引擎的脚本：启动时记录进程号，读到的文本和参数写入日志，
+// 然后把 audio 原样输出到标准输出
+type fakeEngine struct {
+	dir    string
+	script string
+	audio  []byte
+}
+
+func newFakeEngine(t *testing.T, audio []byte) *fakeEngine {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake engine is a shell script")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "audio"), audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "engine.sh")
+	content := `#!/bin/sh
+dir=$(dirname "$0")
+echo "$$" >> "$dir/starts"
+if [ -n "$FAKE_ENGINE_TEXT_ARG" ]; then
+	line=""
+else
+	read -r line
+fi
+echo "$$|$line|$*" >> "$dir/requests"
+cat "$dir/audio"
+`
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &fakeEngine{dir: dir, script: script, audio: audio}
+}
+
+// lines 读取日志文件的各行
+func (e *fakeEngine) lines(name string) []string {
+	data, _ := os.ReadFile(filepath.Join(e.dir, name))
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// waitStarts 等待至少 n 个引擎进程启动，返回它们的进程号
+func (e *fakeEngine) waitStarts(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if starts := e.lines("starts"); len(starts) >= n {
+			return starts
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d engine processes", n)
+	return nil
+}
+
+// requests 返回引擎收到的请求，每项为 进程号|文本|参数
+func (e *fakeEngine) requests() [][]string {
+	var requests [][]string
+	for _, line := range e.lines("requests") {
+		requests = append(requests, strings.SplitN(line, "|", 3))
+	}
+	return requests
+}
+
+// waitPool 等待后台补充的进程放入进程池
+func waitPool(t *testing.T, provider *LocalTTSProvider) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		provider.mu.Lock()
+		ready := len(provider.pool) > 0
+		provider.mu.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the pool to be refilled")
+}
+
+func testPCM() []byte {
+	return bytes.Repeat([]byte{0x01, 0x02}, 100)
+}
+
+func TestLocalRenderTemplate(t *testing.T) {
+	provider := NewLocalTTSProvider(`piper --model "/models/{voice}.onnx" --length_scale {length_scale} --speed {speed} --rate {sample_rate}`)
+	provider.SetVoices("zh_CN-huayan", nil)
+
+	args, stdinText := provider.render("你好", map[string]string{"speed": "2"})
+	want := []string{"piper", "--model", "/models/zh_CN-huayan.onnx", "--length_scale", "0.50", "--speed", "2.00", "--rate", "22050"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+	if stdinText != "你好\n" {
+		t.Errorf("stdin = %q, want the text followed by a newline", stdinText)
+	}
+
+	args, _ = provider.render("你好", map[string]string{"voice_id": "other"})
+	if args[2] != "/models/other.onnx" {
+		t.Errorf("voice_id was not applied: %q", args[2])
+	}
+
+	provider = NewLocalTTSProvider("espeak-ng -v zh --stdout '{text}'")
+	args, stdinText = provider.render("早上 好", nil)
+	if args[len(args)-1] != "早上 好" || stdinText != "" {
+		t.Errorf("args = %q stdin = %q, want text passed as argument only", args, stdinText)
+	}
+}
+
+func TestLocalStdinWAV(t *testing.T) {
+	wav := wrapPCM(testPCM(), pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16})
+	engine := newFakeEngine(t, wav)
+
+	provider := NewLocalTTSProvider(engine.script + " --voice {voice}")
+	provider.SetVoices("huayan", nil)
+	provider.SetPoolSize(0)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer provider.Cleanup()
+
+	audio, err := provider.SynthesizeSpeech("你好世界", nil)
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if !bytes.Equal(audio, wav) {
+		t.Errorf("wav output was modified")
+	}
+
+	pcm, err := provider.SynthesizeSpeech("再见", map[string]string{"format": "pcm"})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech pcm: %v", err)
+	}
+	if !bytes.Equal(pcm, testPCM()) {
+		t.Errorf("pcm output = %d bytes, want the wav payload", len(pcm))
+	}
+
+	requests := engine.requests()
+	if len(requests) != 2 || requests[0][1] != "你好世界" || requests[0][2] != "--voice huayan" {
+		t.Errorf("engine requests = %q", requests)
+	}
+}
+
+func TestLocalTextArgument(t *testing.T) {
+	engine := newFakeEngine(t, wrapPCM(testPCM(), pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}))
+	t.Setenv("FAKE_ENGINE_TEXT_ARG", "1")
+
+	provider := NewLocalTTSProvider(engine.script + " {text}")
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer provider.Cleanup()
+
+	if provider.pool != nil {
+		t.Error("pool is enabled although the text is passed as an argument")
+	}
+	if _, err := provider.SynthesizeSpeech("你好", nil); err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if requests := engine.requests(); len(requests) != 1 || requests[0][2] != "你好" {
+		t.Errorf("engine requests = %q", requests)
+	}
+}
+
+func TestLocalPCMOutput(t *testing.T) {
+	engine := newFakeEngine(t, testPCM())
+
+	provider := NewLocalTTSProvider(engine.script)
+	provider.SetOutput(LocalOutputPCM, 24000)
+	provider.SetPoolSize(0)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer provider.Cleanup()
+
+	wav, err := provider.SynthesizeSpeech("你好", nil)
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	pcm, format, err := unwrapWAV(wav)
+	if err != nil {
+		t.Fatalf("output is not wav: %v", err)
+	}
+	if format.SampleRate != 24000 || format.Channels != 1 || format.BitsPerSample != 16 {
+		t.Errorf("format = %+v", format)
+	}
+	if !bytes.Equal(pcm, testPCM()) {
+		t.Errorf("pcm payload changed")
+	}
+
+	raw, err := provider.SynthesizeSpeech("你好", map[string]string{"format": "pcm"})
+	if err != nil || !bytes.Equal(raw, testPCM()) {
+		t.Errorf("raw pcm = %d bytes, err %v", len(raw), err)
+	}
+}
+
+func TestLocalWarmPool(t *testing.T) {
+	engine := newFakeEngine(t, wrapPCM(testPCM(), pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}))
+
+	provider := NewLocalTTSProvider(engine.script)
+	provider.SetPoolSize(1)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer provider.Cleanup()
+
+	warm := engine.waitStarts(t, 1)[0]
+	if _, err := provider.SynthesizeSpeech("第一句", nil); err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if requests := engine.requests(); len(requests) != 1 || requests[0][0] != warm {
+		t.Errorf("first sentence was not served by the warm process %s: %q", warm, requests)
+	}
+
+	// 使用过的进程已经退出，后台补充的进程接着服务下一句
+	waitPool(t, provider)
+	refilled := engine.waitStarts(t, 2)[1]
+	if _, err := provider.SynthesizeSpeech("第二句", nil); err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if requests := engine.requests(); len(requests) != 2 || requests[1][0] != refilled {
+		t.Errorf("second sentence was not served by the refilled process %s: %q", refilled, requests)
+	}
+}
+
+func TestLocalWarmPoolRecoversFromCrash(t *testing.T) {
+	engine := newFakeEngine(t, wrapPCM(testPCM(), pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}))
+
+	provider := NewLocalTTSProvider(engine.script)
+	provider.SetPoolSize(1)
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer provider.Cleanup()
+
+	// 模拟预先启动的进程意外退出
+	pid, _ := strconv.Atoi(engine.waitStarts(t, 1)[0])
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Kill(); err != nil {
+		t.Fatalf("killing warm process: %v", err)
+	}
+
+	audio, err := provider.SynthesizeSpeech("还能说话吗", nil)
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech after crash: %v", err)
+	}
+	if len(audio) == 0 {
+		t.Fatal("no audio after crash")
+	}
+	requests := engine.requests()
+	if len(requests) != 1 || requests[0][0] == strconv.Itoa(pid) || requests[0][1] != "还能说话吗" {
+		t.Errorf("engine requests = %q, want the sentence served by a new process", requests)
+	}
+
+	// 进程池已经补充，之后的句子照常使用预先启动的进程
+	waitPool(t, provider)
+	if _, err := provider.SynthesizeSpeech("再来一句", nil); err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package tts
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SynthesisRequest 是结构化的合成请求
+type SynthesisRequest struct {
+	Text    string            // 要合成的文本，Markup 为 true 时是 SSML-lite 标记
+	Markup  bool              // Text 是否是 SSML-lite 标记
+	Voice   string            // 音色ID，可带提供商前缀，为空时使用提供商默认音色
+	Format  string            // 提供商输出格式，为空时使用 mp3
+	Speed   float64           // 语速倍率，0 表示默认
+	Pitch   float64           // 音调倍率，0 表示默认
+	Volume  float64           // 音量倍率，0 表示默认
+	Options map[string]string // 提供商特有的其他选项，如 emotion
+}
+
+// options 把请求转换为提供商使用的选项
+func (r SynthesisRequest) options() map[string]string {
+	options := make(map[string]string, len(r.Options)+5)
+	for k, v := range r.Options {
+		options[k] = v
+	}
+	options["format"] = "mp3"
+	if r.Format != "" {
+		options["format"] = r.Format
+	}
+	if r.Voice != "" {
+		options["voice_id"] = r.Voice
+	}
+	setRatio(options, "speed", r.Speed)
+	setRatio(options, "pitch", r.Pitch)
+	setRatio(options, "volume", r.Volume)
+	return options
+}
+
+// setRatio 设置倍率选项，不为正数时不设置
+func setRatio(options map[string]string, key string, value float64) {
+	if value > 0 {
+		options[key] = strconv.FormatFloat(value, 'f', 2, 64)
+	}
+}
+
+// Synthesize 按结构化请求合成语音
+// 标记按音色分段依次合成后拼接；每段都按提供商的能力决定发送 SSML 还是降级后的纯文本
+func (tm *TTSManager) Synthesize(req SynthesisRequest) ([]byte, error) {
+	runs := []*speechRun{plainRun(req.Text)}
+	if req.Markup {
+		segments, err := ParseMarkup(req.Text)
+		if err != nil {
+			return nil, err
+		}
+		runs = splitRuns(segments)
+	}
+
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+
+	if !tm.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	var parts [][]byte
+	var pauses []time.Duration
+	var pause time.Duration
+	for _, run := range runs {
+		pause += run.pause
+		if strings.TrimSpace(run.PlainText()) == "" {
+			continue
+		}
+		options := req.options()
+		if run.voice != "" {
+			options["voice_id"] = run.voice
+		}
+		audioData, err := tm.synthesizeRun(run, options)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, audioData)
+		pauses = append(pauses, pause)
+		pause = 0
+	}
+	if len(parts) == 0 {
+		return nil, ErrEmptyText
+	}
+	return joinAudio(parts, pauses, req.options()), nil
+}
+
+// joinAudio 拼接多段音频，pauses[i] 是第 i 段之前要插入的静音时长，第一段之前不插入
+// WAV 合并为一个文件，静音按第一段的采样格式生成；MP3 插入与前一段参数相同的静音帧；
+// PCM 需要 sample_rate 选项才能计算静音长度；其余格式直接首尾相接
+func joinAudio(parts [][]byte, pauses []time.Duration, options map[string]string) []byte {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	if isWAV(parts[0]) {
+		_, format, err := unwrapWAV(parts[0])
+		if err == nil {
+			var pcm []byte
+			for i, part := range parts {
+				if i > 0 {
+					pcm = append(pcm, silentPCM(format, pauses[i])...)
+				}
+				data, _, err := unwrapWAV(part)
+				if err != nil {
+					data = part
+				}
+				pcm = append(pcm, data...)
+			}
+			return wrapPCM(pcm, format)
+		}
+	}
+
+	var joined []byte
+	for i, part := range parts {
+		if i > 0 {
+			joined = append(joined, silence(parts[i-1], pauses[i], options)...)
+		}
+		joined = append(joined, part...)
+	}
+	return joined
+}
@@ -0,0 +1,104 @@
+package tts
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wavProvider 把每段文本合成为固定长度的 WAV，并记录收到的文本
+type wavProvider struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (p *wavProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	p.mu.Lock()
+	p.texts = append(p.texts, text)
+	p.mu.Unlock()
+	return wrapPCM(bytes.Repeat([]byte{0x01}, 320), pcmFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}), nil
+}
+
+func (p *wavProvider) GetVoices() ([]Voice, error) { return nil, nil }
+func (p *wavProvider) Initialize() error           { return nil }
+func (p *wavProvider) Cleanup() error              { return nil }
+
+func TestSplitRunsPauses(t *testing.T) {
+	segments, err := ParseMarkup(`<voice name="a">你好<break time="200ms"/>朋友</voice><break time="300ms"/><break time="100ms"/><voice name="b">再见</voice><break time="500ms"/>`)
+	if err != nil {
+		t.Fatalf("ParseMarkup: %v", err)
+	}
+
+	runs := splitRuns(segments)
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	// 同一音色内的停顿留在组内，换人处的停顿成为下一组的 pause
+	if runs[0].PlainText() != "你好，朋友" || runs[0].pause != 0 {
+		t.Errorf("first run = %q pause %v", runs[0].PlainText(), runs[0].pause)
+	}
+	if runs[1].PlainText() != "再见。" || runs[1].pause != 400*time.Millisecond {
+		t.Errorf("second run = %q pause %v", runs[1].PlainText(), runs[1].pause)
+	}
+}
+
+func TestSynthesizeSpeakerPauseWAV(t *testing.T) {
+	provider := &wavProvider{}
+	manager := NewTTSManager()
+	manager.RegisterProvider("wav", provider)
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	audio, err := manager.Synthesize(SynthesisRequest{
+		Text:   `<voice name="a">你好</voice><break time="300ms"/><voice name="b">再见</voice>`,
+		Markup: true,
+		Format: "wav",
+	})
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if len(provider.texts) != 2 || provider.texts[0] != "你好" || provider.texts[1] != "再见" {
+		t.Errorf("provider texts = %q", provider.texts)
+	}
+
+	pcm, _, err := unwrapWAV(audio)
+	if err != nil {
+		t.Fatalf("output is not wav: %v", err)
+	}
+	// 300ms 16kHz 16bit 单声道静音为 9600 字节
+	if len(pcm) != 320+9600+320 {
+		t.Fatalf("pcm = %d bytes, want both parts with 9600 bytes of silence between", len(pcm))
+	}
+	if !bytes.Equal(pcm[320:320+9600], make([]byte, 9600)) {
+		t.Error("gap between speakers is not silent")
+	}
+}
+
+func TestJoinAudioSilence(t *testing.T) {
+	pause := []time.Duration{0, 300 * time.Millisecond}
+
+	// MPEG1 Layer III，128kbps，44.1kHz，帧长 417 字节
+	frame := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	joined := joinAudio([][]byte{frame, frame}, pause, map[string]string{"format": "mp3"})
+	// 300ms 为 13230 个采样，需要 12 帧（每帧 1152 个采样）
+	silent := joined[len(frame) : len(joined)-len(frame)]
+	if len(silent) != 12*417 {
+		t.Fatalf("mp3 silence = %d bytes, want 12 frames", len(silent))
+	}
+	if !bytes.Equal(silent[:4], []byte{0xFF, 0xFB, 0x90, 0x00}) || !bytes.Equal(silent[417:421], silent[:4]) {
+		t.Errorf("silent frame header = % x", silent[:4])
+	}
+
+	pcm := joinAudio([][]byte{{1, 1}, {2, 2}}, pause, map[string]string{"format": "pcm", "sample_rate": "8000"})
+	if len(pcm) != 2+4800+2 {
+		t.Errorf("pcm = %d bytes, want 4800 bytes of silence", len(pcm))
+	}
+
+	// 无法确定格式时直接拼接
+	raw := joinAudio([][]byte{{1}, {2}}, pause, map[string]string{"format": "opus"})
+	if !bytes.Equal(raw, []byte{1, 2}) {
+		t.Errorf("opus = %v", raw)
+	}
+}
@@ -0,0 +1,123 @@
+package tts
+
+import (
+	"strconv"
+	"time"
+)
+
+// silence 生成与 sample 格式相同、可以直接拼接在它后面的静音，无法确定格式时返回 nil
+func silence(sample []byte, d time.Duration, options map[string]string) []byte {
+	if d <= 0 {
+		return nil
+	}
+	switch options["format"] {
+	case "pcm":
+		rate, err := strconv.Atoi(options["sample_rate"])
+		if err != nil || rate <= 0 {
+			return nil
+		}
+		return silentPCM(pcmFormat{SampleRate: rate, Channels: 1, BitsPerSample: 16}, d)
+	case "mp3":
+		return silentMP3(sample, d)
+	}
+	return nil
+}
+
+// silentPCM 返回给定时长的静音 PCM 数据
+func silentPCM(format pcmFormat, d time.Duration) []byte {
+	blockAlign := format.Channels * format.BitsPerSample / 8
+	samples := int(int64(format.SampleRate) * int64(d) / int64(time.Second))
+	if samples <= 0 || blockAlign <= 0 {
+		return nil
+	}
+	return make([]byte, samples*blockAlign)
+}
+
+// MPEG Layer III 各版本的比特率（kbps）和采样率表
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3SampleRate = [4]int{44100, 48000, 32000, 0}
+)
+
+// mp3Frame 描述一个 Layer III 帧头
+type mp3Frame struct {
+	header     [4]byte
+	size       int // 不含填充字节的帧长
+	samples    int // 每帧采样数
+	sampleRate int
+}
+
+// findMP3Frame 跳过 ID3v2 标签，解析第一个 Layer III 帧头
+func findMP3Frame(data []byte) (mp3Frame, bool) {
+	offset := 0
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		// 标签长度为 4 字节 syncsafe 整数，每字节只用低 7 位
+		size := int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9])
+		offset = 10 + size
+		if data[5]&0x10 != 0 {
+			offset += 10 // 标签尾
+		}
+	}
+
+	for ; offset+4 <= len(data); offset++ {
+		if data[offset] != 0xFF || data[offset+1]&0xE0 != 0xE0 {
+			continue
+		}
+		if frame, ok := parseMP3Header(data[offset : offset+4]); ok {
+			return frame, true
+		}
+	}
+	return mp3Frame{}, false
+}
+
+// parseMP3Header 解析 4 字节帧头，只支持 Layer III
+func parseMP3Header(b []byte) (mp3Frame, bool) {
+	version := b[1] >> 3 & 0x3 // 3: MPEG1，2: MPEG2，0: MPEG2.5
+	layer := b[1] >> 1 & 0x3   // 1: Layer III
+	bitrateIndex := b[2] >> 4
+	rateIndex := b[2] >> 2 & 0x3
+	if version == 1 || layer != 1 || mp3SampleRate[rateIndex] == 0 {
+		return mp3Frame{}, false
+	}
+
+	frame := mp3Frame{samples: 1152, sampleRate: mp3SampleRate[rateIndex]}
+	bitrate := mp3BitratesV1[bitrateIndex]
+	coefficient := 144
+	if version != 3 {
+		frame.samples = 576
+		frame.sampleRate /= 2
+		bitrate = mp3BitratesV2[bitrateIndex]
+		coefficient = 72
+	}
+	if version == 0 {
+		frame.sampleRate /= 2
+	}
+	if bitrate == 0 {
+		return mp3Frame{}, false
+	}
+
+	frame.size = coefficient * bitrate * 1000 / frame.sampleRate
+	copy(frame.header[:], b)
+	frame.header[1] |= 0x01  // 不带 CRC
+	frame.header[2] &^= 0x02 // 不填充
+	return frame, true
+}
+
+// silentMP3 按 sample 中第一帧的参数生成给定时长的静音帧，无法解析时返回 nil
+// 帧头之后的边信息和主数据全为零，解码结果是静音
+func silentMP3(sample []byte, d time.Duration) []byte {
+	frame, ok := findMP3Frame(sample)
+	if !ok || d <= 0 {
+		return nil
+	}
+
+	samples := int64(frame.sampleRate) * int64(d) / int64(time.Second)
+	count := int((samples + int64(frame.samples) - 1) / int64(frame.samples))
+	silence := make([]byte, 0, count*frame.size)
+	for i := 0; i < count; i++ {
+		silence = append(silence, frame.header[:]...)
+		silence = append(silence, make([]byte, frame.size-4)...)
+	}
+	return silence
+}
@@ -0,0 +1,285 @@
+package tts
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/textnorm"
+)
+
+// SSML-lite 是 SSML 的一个小子集，所有提供商都能使用：
+//
+//	<break time="500ms"/>                     停顿，也可写 strength="weak|medium|strong"
+//	<emphasis>重点</emphasis>                  强调
+//	<say-as interpret-as="telephone">...</say-as>  读法：digits、telephone、cardinal、date
+//	<voice name="provider:voice">...</voice>  这一段换用其他音色
+//
+// 外层的 <speak> 可以省略。支持 SSML 的提供商收到标准 SSML，
+// 其余提供商收到降级后的纯文本：停顿变成标点，say-as 展开为中文读法，强调被忽略。
+
+// SSMLProvider 是支持 SSML 输入的提供商可选实现的接口
+// 支持时 TTSManager 传入 <speak> 包裹的 SSML，并设置 text_type=ssml 选项
+type SSMLProvider interface {
+	SupportsSSML() bool
+}
+
+// supportsSSML 判断提供商是否接受 SSML
+func supportsSSML(provider Provider) bool {
+	p, ok := provider.(SSMLProvider)
+	return ok && p.SupportsSSML()
+}
+
+// 降级为纯文本时，不短于这个时长的停顿用句号，否则用逗号
+const longBreak = 400 * time.Millisecond
+
+// breakStrengths 是 strength 属性对应的停顿时长
+var breakStrengths = map[string]time.Duration{
+	"none":     0,
+	"x-weak":   100 * time.Millisecond,
+	"weak":     200 * time.Millisecond,
+	"medium":   400 * time.Millisecond,
+	"strong":   700 * time.Millisecond,
+	"x-strong": 1000 * time.Millisecond,
+}
+
+// Segment 是标记解析后的一段内容，停顿段的 Text 为空
+type Segment struct {
+	Text     string
+	Voice    string // 这一段的音色，为空时使用请求的音色
+	SayAs    string // say-as 的 interpret-as
+	Emphasis bool
+	Break    time.Duration
+}
+
+// ParseMarkup 解析 SSML-lite 标记，不认识的标签保留其中的文字
+func ParseMarkup(markup string) ([]Segment, error) {
+	markup = strings.TrimSpace(markup)
+	if !strings.HasPrefix(markup, "<speak") {
+		markup = "<speak>" + markup + "</speak>"
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(markup))
+	decoder.Strict = false
+
+	var segments []Segment
+	var voices, sayAs []string
+	emphasis := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid markup: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "break":
+				segments = append(segments, Segment{Voice: last(voices), Break: parseBreak(t)})
+			case "emphasis":
+				emphasis++
+			case "say-as":
+				sayAs = append(sayAs, attr(t, "interpret-as"))
+			case "voice":
+				voices = append(voices, attr(t, "name"))
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "emphasis":
+				if emphasis > 0 {
+					emphasis--
+				}
+			case "say-as":
+				sayAs = pop(sayAs)
+			case "voice":
+				voices = pop(voices)
+			}
+		case xml.CharData:
+			if text := string(t); strings.TrimSpace(text) != "" {
+				segments = append(segments, Segment{
+					Text:     text,
+					Voice:    last(voices),
+					SayAs:    last(sayAs),
+					Emphasis: emphasis > 0,
+				})
+			}
+		}
+	}
+	return segments, nil
+}
+
+// parseBreak 读取 break 标签的停顿时长，默认为中等停顿
+func parseBreak(element xml.StartElement) time.Duration {
+	if value := attr(element, "time"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if d, ok := breakStrengths[attr(element, "strength")]; ok {
+		return d
+	}
+	return breakStrengths["medium"]
+}
+
+// attr 返回元素的属性值
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+// last 返回栈顶元素，栈为空时返回空字符串
+func last(stack []string) string {
+	if len(stack) == 0 {
+		return ""
+	}
+	return stack[len(stack)-1]
+}
+
+// pop 弹出栈顶元素
+func pop(stack []string) []string {
+	if len(stack) == 0 {
+		return stack
+	}
+	return stack[:len(stack)-1]
+}
+
+// speechRun 是使用同一个音色连续合成的一组片段
+type speechRun struct {
+	voice    string
+	segments []Segment
+	markup   bool          // 是否来自标记，纯文本请求原样交给提供商
+	pause    time.Duration // 这一组之前的停顿，拼接音频时插入静音
+}
+
+// plainRun 把一段纯文本包装成合成单元
+func plainRun(text string) *speechRun {
+	return &speechRun{segments: []Segment{{Text: text}}}
+}
+
+// splitRuns 按音色把片段分组
+// 同一音色内的停顿留在组内；换音色处的停顿记为下一组的 pause，
+// 否则它会落在上一组末尾，被降级为纯文本时去掉
+func splitRuns(segments []Segment) []*speechRun {
+	var runs []*speechRun
+	var pending []Segment
+	for _, segment := range segments {
+		if segment.Text == "" {
+			pending = append(pending, segment)
+			continue
+		}
+		if len(runs) == 0 || segment.Voice != runs[len(runs)-1].voice {
+			run := &speechRun{voice: segment.Voice, markup: true}
+			if len(runs) == 0 {
+				run.segments = pending
+			} else {
+				for _, pause := range pending {
+					run.pause += pause.Break
+				}
+			}
+			runs = append(runs, run)
+		} else {
+			runs[len(runs)-1].segments = append(runs[len(runs)-1].segments, pending...)
+		}
+		pending = nil
+		current := runs[len(runs)-1]
+		current.segments = append(current.segments, segment)
+	}
+	if len(runs) == 0 {
+		return []*speechRun{{markup: true, segments: pending}}
+	}
+	last := runs[len(runs)-1]
+	last.segments = append(last.segments, pending...)
+	return runs
+}
+
+// PlainText 返回降级后的纯文本
+func (r *speechRun) PlainText() string {
+	if !r.markup {
+		return r.segments[0].Text
+	}
+
+	var b strings.Builder
+	for _, segment := range r.segments {
+		switch {
+		case segment.Text != "":
+			b.WriteString(readSayAs(strings.TrimSpace(segment.Text), segment.SayAs))
+		case segment.Break >= longBreak:
+			b.WriteString("。")
+		case segment.Break > 0:
+			b.WriteString("，")
+		}
+	}
+	return strings.Trim(b.String(), "，")
+}
+
+// SSML 返回 <speak> 包裹的标准 SSML
+func (r *speechRun) SSML() string {
+	if !r.markup {
+		return "<speak>" + escapeXML(r.segments[0].Text) + "</speak>"
+	}
+
+	var b strings.Builder
+	b.WriteString("<speak>")
+	for _, segment := range r.segments {
+		if segment.Text == "" {
+			fmt.Fprintf(&b, `<break time="%dms"/>`, segment.Break.Milliseconds())
+			continue
+		}
+		text := escapeXML(strings.TrimSpace(segment.Text))
+		if segment.SayAs != "" {
+			text = fmt.Sprintf(`<say-as interpret-as="%s">%s</say-as>`, escapeXML(segment.SayAs), text)
+		}
+		if segment.Emphasis {
+			text = "<emphasis>" + text + "</emphasis>"
+		}
+		b.WriteString(text)
+	}
+	b.WriteString("</speak>")
+	return b.String()
+}
+
+// escapeXML 转义 XML 特殊字符
+func escapeXML(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// digitRe 匹配单个数字
+var digitRe = regexp.MustCompile(`\d`)
+
+// readSayAs 按 interpret-as 把文字展开为中文读法
+func readSayAs(text, interpretAs string) string {
+	switch interpretAs {
+	case "digits", "characters":
+		return digitRe.ReplaceAllStringFunc(text, textnorm.ReadDigits)
+	case "telephone":
+		// 电话号码中的 1 读作“幺”，分隔符变成短停顿
+		text = strings.NewReplacer("-", "，", " ", "，").Replace(text)
+		return digitRe.ReplaceAllStringFunc(text, func(d string) string {
+			if d == "1" {
+				return "幺"
+			}
+			return textnorm.ReadDigits(d)
+		})
+	case "cardinal", "number":
+		return textnorm.ExpandNumbers(text)
+	case "date":
+		return textnorm.ExpandNumbers(strings.ReplaceAll(text, "/", "-"))
+	}
+	return text
+}
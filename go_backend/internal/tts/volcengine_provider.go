@@ -0,0 +1,501 @@
+package tts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// 火山引擎语音合成接口
+const (
+	volcengineHTTPEndpoint = "https://openspeech.bytedance.com/api/v1/tts"
+	volcengineWSEndpoint   = "wss://openspeech.bytedance.com/api/v1/tts/ws_binary"
+	volcengineDefaultVoice = "BV001_streaming"
+	// volcengineSuccessCode 是 HTTP 接口表示合成成功的业务状态码
+	volcengineSuccessCode = 3000
+)
+
+// 火山引擎的传输方式
+const (
+	VolcengineModeHTTP      = "http"      // 一次请求返回 base64 编码的完整音频
+	VolcengineModeWebSocket = "websocket" // 二进制协议，音频分片返回
+)
+
+// volcengineVoices 是常用的火山引擎音色（voice_type）
+var volcengineVoices = []Voice{
+	{ID: "BV001_streaming", Name: "通用女声", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "adult"}},
+	{ID: "BV002_streaming", Name: "通用男声", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "adult"}},
+	{ID: "BV700_streaming", Name: "灿灿", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "young", "emotion": "true"}},
+	{ID: "BV701_streaming", Name: "擎苍", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "adult", "emotion": "true"}},
+	{ID: "BV406_streaming", Name: "梓梓", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV407_streaming", Name: "燃燃", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV405_streaming", Name: "甜美小源", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV104_streaming", Name: "温柔淑女", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "adult"}},
+	{ID: "BV004_streaming", Name: "开朗青年", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV102_streaming", Name: "儒雅青年", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV113_streaming", Name: "甜宠少御", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "young"}},
+	{ID: "BV051_streaming", Name: "奶气萌娃", Gender: "male", Language: "zh-CN", Tags: map[string]string{"age": "child"}},
+	{ID: "BV064_streaming", Name: "小萝莉", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "child"}},
+	{ID: "BV034_streaming", Name: "知性姐姐-双语", Gender: "female", Language: "zh-CN", Tags: map[string]string{"age": "adult", "bilingual": "en-US"}},
+	{ID: "BV503_streaming", Name: "活力女声-Ariana", Gender: "female", Language: "en-US", Tags: map[string]string{"age": "young"}},
+	{ID: "BV504_streaming", Name: "活力男声-Jackson", Gender: "male", Language: "en-US", Tags: map[string]string{"age": "young"}},
+}
+
+// VolcengineTTSProvider 实现火山引擎（豆包）语音合成协议
+type VolcengineTTSProvider struct {
+	appID        string
+	token        string
+	cluster      string
+	defaultVoice string
+	mode         string
+	httpEndpoint string
+	wsEndpoint   string
+	httpClient   *http.Client
+	dialer       *websocket.Dialer
+	initialized  bool
+}
+
+// NewVolcengineTTSProvider 创建一个新的火山引擎 TTS 提供商
+func NewVolcengineTTSProvider(appID, token, cluster string) *VolcengineTTSProvider {
+	if cluster == "" {
+		cluster = "volcano_tts"
+	}
+	return &VolcengineTTSProvider{
+		appID:        appID,
+		token:        token,
+		cluster:      cluster,
+		defaultVoice: volcengineDefaultVoice,
+		mode:         VolcengineModeHTTP,
+		httpEndpoint: volcengineHTTPEndpoint,
+		wsEndpoint:   volcengineWSEndpoint,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		dialer: &websocket.Dialer{
+			HandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// SetMode 设置传输方式（http 或 websocket）
+func (p *VolcengineTTSProvider) SetMode(mode string) {
+	if mode != "" {
+		p.mode = mode
+	}
+}
+
+// SetEndpoints 设置 HTTP 和 WebSocket 接口地址，为空的保持默认
+func (p *VolcengineTTSProvider) SetEndpoints(httpEndpoint, wsEndpoint string) {
+	if httpEndpoint != "" {
+		p.httpEndpoint = httpEndpoint
+	}
+	if wsEndpoint != "" {
+		p.wsEndpoint = wsEndpoint
+	}
+}
+
+// SetDefaultVoice 设置未指定 voice_id 时使用的音色
+func (p *VolcengineTTSProvider) SetDefaultVoice(voice string) {
+	if voice != "" {
+		p.defaultVoice = voice
+	}
+}
+
+// volcengineRequest 表示合成请求，HTTP 和 WebSocket 使用相同的 JSON 结构
+type volcengineRequest struct {
+	App     volcengineApp          `json:"app"`
+	User    volcengineUser         `json:"user"`
+	Audio   volcengineAudio        `json:"audio"`
+	Request volcengineRequestParam `json:"request"`
+}
+
+// volcengineApp 表示应用鉴权信息
+type volcengineApp struct {
+	AppID   string `json:"appid"`
+	Token   string `json:"token"`
+	Cluster string `json:"cluster"`
+}
+
+// volcengineUser 表示调用方用户信息
+type volcengineUser struct {
+	UID string `json:"uid"`
+}
+
+// volcengineAudio 表示音频参数
+type volcengineAudio struct {
+	VoiceType   string  `json:"voice_type"`
+	Encoding    string  `json:"encoding"`
+	Rate        int     `json:"rate,omitempty"`
+	SpeedRatio  float64 `json:"speed_ratio"`
+	VolumeRatio float64 `json:"volume_ratio"`
+	PitchRatio  float64 `json:"pitch_ratio"`
+	Emotion     string  `json:"emotion,omitempty"`
+}
+
+// volcengineRequestParam 表示本次请求的文本和操作
+type volcengineRequestParam struct {
+	ReqID     string `json:"reqid"`
+	Text      string `json:"text"`
+	TextType  string `json:"text_type"` // plain 或 ssml
+	Operation string `json:"operation"` // HTTP 为 query，WebSocket 为 submit
+}
+
+// volcengineResponse 表示 HTTP 接口的响应
+type volcengineResponse struct {
+	ReqID    string `json:"reqid"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Sequence int    `json:"sequence"`
+	Data     string `json:"data"` // base64 编码的音频
+}
+
+// VolcengineError 表示火山引擎返回的业务错误
+type VolcengineError struct {
+	Code    int
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *VolcengineError) Error() string {
+	return fmt.Sprintf("volcengine tts error [%d]: %s", e.Code, e.Message)
+}
+
+// SupportsSSML 实现 SSMLProvider 接口，SSML 通过 text_type=ssml 发送
+func (p *VolcengineTTSProvider) SupportsSSML() bool {
+	return true
+}
+
+// SynthesizeSpeech 按配置的传输方式合成语音
+// 支持的选项：voice_id、format（mp3、wav、pcm、ogg_opus）、speed、volume、pitch、sample_rate、emotion、text_type
+func (p *VolcengineTTSProvider) SynthesizeSpeech(text string, options map[string]string) ([]byte, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+
+	request := p.newRequest(text, options)
+	log.Printf("[TTS:Volcengine] Synthesizing via %s with voice %s: %s", p.mode, request.Audio.VoiceType, text)
+
+	var (
+		audioData []byte
+		err       error
+	)
+	if p.mode == VolcengineModeWebSocket {
+		audioData, err = p.synthesizeWebSocket(request)
+	} else {
+		audioData, err = p.synthesizeHTTP(request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[TTS:Volcengine] Successfully generated audio, size: %d bytes", len(audioData))
+	return audioData, nil
+}
+
+// newRequest 根据选项构造合成请求
+func (p *VolcengineTTSProvider) newRequest(text string, options map[string]string) *volcengineRequest {
+	request := &volcengineRequest{
+		App:  volcengineApp{AppID: p.appID, Token: p.token, Cluster: p.cluster},
+		User: volcengineUser{UID: "xiaozhi"},
+		Audio: volcengineAudio{
+			VoiceType:   p.defaultVoice,
+			Encoding:    "mp3",
+			SpeedRatio:  1.0,
+			VolumeRatio: 1.0,
+			PitchRatio:  1.0,
+		},
+		Request: volcengineRequestParam{
+			ReqID:     uuid.New().String(),
+			Text:      text,
+			TextType:  "plain",
+			Operation: "query",
+		},
+	}
+
+	if voice := options["voice_id"]; voice != "" {
+		request.Audio.VoiceType = voice
+	}
+	if format := options["format"]; format != "" {
+		request.Audio.Encoding = format
+	}
+	if textType := options["text_type"]; textType != "" {
+		request.Request.TextType = textType
+	}
+	request.Audio.Emotion = options["emotion"]
+	request.Audio.SpeedRatio = parseRatio(options["speed"], request.Audio.SpeedRatio)
+	request.Audio.VolumeRatio = parseRatio(options["volume"], request.Audio.VolumeRatio)
+	request.Audio.PitchRatio = parseRatio(options["pitch"], request.Audio.PitchRatio)
+	if rate, err := strconv.Atoi(options["sample_rate"]); err == nil && rate > 0 {
+		request.Audio.Rate = rate
+	}
+	return request
+}
+
+// parseRatio 解析倍率选项，无法解析或不为正数时返回默认值
+func parseRatio(value string, fallback float64) float64 {
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio <= 0 {
+		return fallback
+	}
+	return ratio
+}
+
+// synthesizeHTTP 通过 HTTP 接口合成，音频以 base64 放在响应的 data 字段中
+func (p *VolcengineTTSProvider) synthesizeHTTP(request *volcengineRequest) ([]byte, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", p.httpEndpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// 火山引擎的鉴权头格式为 "Bearer;token"
+	req.Header.Set("Authorization", "Bearer;"+p.token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// 业务错误也可能以非 200 状态返回，优先解析响应体中的错误码
+	var result volcengineResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error response from API [%d]: %s", resp.StatusCode, string(body))
+	}
+	if result.Code != volcengineSuccessCode {
+		return nil, &VolcengineError{Code: result.Code, Message: result.Message}
+	}
+
+	audioData, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding audio: %w", err)
+	}
+	return audioData, nil
+}
+
+// 二进制协议的消息类型
+const (
+	volcMsgFullClientRequest = 0x1
+	volcMsgAudioOnlyResponse = 0xB
+	volcMsgFrontendResponse  = 0xC
+	volcMsgError             = 0xF
+)
+
+// 二进制协议的其他头部字段
+const (
+	volcProtocolVersion = 0x1
+	volcHeaderSize      = 0x1 // 以 4 字节为单位
+	volcSerializeJSON   = 0x1
+	volcCompressNone    = 0x0
+	volcCompressGzip    = 0x1
+)
+
+// synthesizeWebSocket 通过二进制 WebSocket 协议合成，拼接所有音频分片
+func (p *VolcengineTTSProvider) synthesizeWebSocket(request *volcengineRequest) ([]byte, error) {
+	request.Request.Operation = "submit"
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer; "+p.token)
+	conn, resp, err := p.dialer.Dial(p.wsEndpoint, header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("error connecting websocket [%d]: %s", resp.StatusCode, string(body))
+		}
+		return nil, fmt.Errorf("error connecting websocket: %w", err)
+	}
+	defer conn.Close()
+
+	frame, err := encodeVolcengineRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	var audio bytes.Buffer
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+
+		message, err := decodeVolcengineResponse(data)
+		if err != nil {
+			return nil, err
+		}
+		if message.err != nil {
+			return nil, message.err
+		}
+
+		audio.Write(message.audio)
+		if message.last {
+			return audio.Bytes(), nil
+		}
+	}
+}
+
+// encodeVolcengineRequest 把请求编码为二进制帧：4 字节头部 + 4 字节负载长度 + gzip 压缩的 JSON
+func encodeVolcengineRequest(request *volcengineRequest) ([]byte, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	var payload bytes.Buffer
+	writer := gzip.NewWriter(&payload)
+	if _, err := writer.Write(jsonData); err != nil {
+		return nil, fmt.Errorf("error compressing request: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing request: %w", err)
+	}
+
+	frame := make([]byte, 8, 8+payload.Len())
+	frame[0] = volcProtocolVersion<<4 | volcHeaderSize
+	frame[1] = volcMsgFullClientRequest << 4
+	frame[2] = volcSerializeJSON<<4 | volcCompressGzip
+	binary.BigEndian.PutUint32(frame[4:8], uint32(payload.Len()))
+	return append(frame, payload.Bytes()...), nil
+}
+
+// volcengineMessage 表示解码后的服务端消息
+type volcengineMessage struct {
+	audio []byte
+	last  bool // 序号为负表示最后一个分片
+	err   error
+}
+
+// decodeVolcengineResponse 解码服务端的二进制帧
+func decodeVolcengineResponse(data []byte) (*volcengineMessage, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("volcengine frame too short: %d bytes", len(data))
+	}
+
+	headerSize := int(data[0]&0x0F) * 4
+	messageType := data[1] >> 4
+	flags := data[1] & 0x0F
+	compression := data[2] & 0x0F
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("volcengine frame shorter than header: %d bytes", len(data))
+	}
+	body := data[headerSize:]
+
+	switch messageType {
+	case volcMsgAudioOnlyResponse:
+		message := &volcengineMessage{}
+		// 带序号的分片：flags 为 1 表示正序号，2 或 3 表示最后一个分片（负序号）
+		if flags != 0 {
+			if len(body) < 4 {
+				return nil, fmt.Errorf("volcengine audio frame missing sequence")
+			}
+			sequence := int32(binary.BigEndian.Uint32(body[:4]))
+			message.last = sequence < 0
+			body = body[4:]
+		}
+		payload, err := readVolcenginePayload(body)
+		if err != nil {
+			return nil, err
+		}
+		message.audio = payload
+		return message, nil
+
+	case volcMsgFrontendResponse:
+		// 前端信息（如时间戳），不包含音频
+		return &volcengineMessage{}, nil
+
+	case volcMsgError:
+		if len(body) < 4 {
+			return nil, fmt.Errorf("volcengine error frame missing code")
+		}
+		code := int(binary.BigEndian.Uint32(body[:4]))
+		payload, err := readVolcenginePayload(body[4:])
+		if err != nil {
+			return nil, err
+		}
+		if compression == volcCompressGzip {
+			if payload, err = gunzip(payload); err != nil {
+				return nil, err
+			}
+		}
+		return &volcengineMessage{err: &VolcengineError{Code: code, Message: string(payload)}}, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected volcengine message type: %#x", messageType)
+	}
+}
+
+// readVolcenginePayload 读取 4 字节长度前缀的负载
+func readVolcenginePayload(body []byte) ([]byte, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("volcengine frame missing payload size")
+	}
+	size := binary.BigEndian.Uint32(body[:4])
+	if uint32(len(body)-4) < size {
+		return nil, fmt.Errorf("volcengine payload truncated: want %d bytes, got %d", size, len(body)-4)
+	}
+	return body[4 : 4+size], nil
+}
+
+// gunzip 解压 gzip 数据
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing payload: %w", err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// GetVoices 返回火山引擎常用的音色
+func (p *VolcengineTTSProvider) GetVoices() ([]Voice, error) {
+	if !p.initialized {
+		return nil, ErrNotInitialized
+	}
+	voices := make([]Voice, len(volcengineVoices))
+	copy(voices, volcengineVoices)
+	return voices, nil
+}
+
+// Initialize 初始化火山引擎 TTS 提供商
+func (p *VolcengineTTSProvider) Initialize() error {
+	if p.appID == "" || p.token == "" {
+		return fmt.Errorf("volcengine TTS appid and token are required")
+	}
+	if p.mode != VolcengineModeHTTP && p.mode != VolcengineModeWebSocket {
+		return fmt.Errorf("unknown volcengine TTS mode: %s", p.mode)
+	}
+
+	log.Printf("[TTS:Volcengine] Initializing Volcengine TTS provider (cluster %s, mode %s)", p.cluster, p.mode)
+	p.initialized = true
+	return nil
+}
+
+// Cleanup 清理火山引擎 TTS 提供商资源
+func (p *VolcengineTTSProvider) Cleanup() error {
+	log.Printf("[TTS:Volcengine] Cleaning up Volcengine TTS provider")
+	p.initialized = false
+	return nil
+}
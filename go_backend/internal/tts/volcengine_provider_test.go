@@ -0,0 +1,255 @@
+package tts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// volcAudioFrame 编码一个带序号的音频分片，seq 为负表示最后一个分片
+func volcAudioFrame(seq int32, audio []byte) []byte {
+	frame := []byte{volcProtocolVersion<<4 | volcHeaderSize, volcMsgAudioOnlyResponse<<4 | 0x1, 0, 0}
+	if seq < 0 {
+		frame[1] = volcMsgAudioOnlyResponse<<4 | 0x3
+	}
+	frame = binary.BigEndian.AppendUint32(frame, uint32(seq))
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(audio)))
+	return append(frame, audio...)
+}
+
+// volcErrorFrame 编码一个 gzip 压缩的错误帧
+func volcErrorFrame(code uint32, message string) []byte {
+	var payload bytes.Buffer
+	writer := gzip.NewWriter(&payload)
+	writer.Write([]byte(message))
+	writer.Close()
+
+	frame := []byte{volcProtocolVersion<<4 | volcHeaderSize, volcMsgError << 4, volcSerializeJSON<<4 | volcCompressGzip, 0}
+	frame = binary.BigEndian.AppendUint32(frame, code)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(payload.Len()))
+	return append(frame, payload.Bytes()...)
+}
+
+// decodeClientFrame 按二进制协议解出客户端请求，并检查头部字段
+func decodeClientFrame(t *testing.T, frame []byte) volcengineRequest {
+	t.Helper()
+
+	if len(frame) < 8 {
+		t.Fatalf("client frame too short: %d bytes", len(frame))
+	}
+	if frame[0] != volcProtocolVersion<<4|volcHeaderSize {
+		t.Errorf("version/header size byte = %#x", frame[0])
+	}
+	if frame[1] != volcMsgFullClientRequest<<4 {
+		t.Errorf("message type byte = %#x, want full client request", frame[1])
+	}
+	if frame[2] != volcSerializeJSON<<4|volcCompressGzip {
+		t.Errorf("serialization byte = %#x, want JSON + gzip", frame[2])
+	}
+	size := binary.BigEndian.Uint32(frame[4:8])
+	if int(size) != len(frame)-8 {
+		t.Errorf("payload size = %d, frame carries %d bytes", size, len(frame)-8)
+	}
+
+	jsonData, err := gunzip(frame[8:])
+	if err != nil {
+		t.Fatalf("decompressing payload: %v", err)
+	}
+	var request volcengineRequest
+	if err := json.Unmarshal(jsonData, &request); err != nil {
+		t.Fatalf("parsing payload: %v", err)
+	}
+	return request
+}
+
+// fakeVolcengineWS 启动一个说二进制协议的本地 WebSocket 服务，respond 返回要依次发送的帧
+func fakeVolcengineWS(t *testing.T, respond func(request volcengineRequest) [][]byte) *VolcengineTTSProvider {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer; test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		messageType, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Errorf("request message type = %d, want binary", messageType)
+		}
+		for _, response := range respond(decodeClientFrame(t, frame)) {
+			if err := conn.WriteMessage(websocket.BinaryMessage, response); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewVolcengineTTSProvider("test-app", "test-token", "")
+	provider.SetMode(VolcengineModeWebSocket)
+	provider.SetEndpoints("", "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return provider
+}
+
+func TestVolcengineWebSocket(t *testing.T) {
+	provider := fakeVolcengineWS(t, func(request volcengineRequest) [][]byte {
+		if request.Request.Operation != "submit" {
+			t.Errorf("operation = %q, want submit", request.Request.Operation)
+		}
+		if request.App.AppID != "test-app" || request.App.Cluster != "volcano_tts" {
+			t.Errorf("app = %+v", request.App)
+		}
+		if request.Audio.VoiceType != "BV700_streaming" || request.Audio.Encoding != "pcm" || request.Audio.SpeedRatio != 1.2 {
+			t.Errorf("audio = %+v", request.Audio)
+		}
+		if request.Request.Text != "你好" {
+			t.Errorf("text = %q", request.Request.Text)
+		}
+		frontend := []byte{volcProtocolVersion<<4 | volcHeaderSize, volcMsgFrontendResponse << 4, 0, 0}
+		return [][]byte{
+			volcAudioFrame(1, []byte("aa")),
+			frontend,
+			volcAudioFrame(2, []byte("bb")),
+			volcAudioFrame(-3, []byte("cc")),
+			volcAudioFrame(4, []byte("ignored after last frame")),
+		}
+	})
+
+	audio, err := provider.SynthesizeSpeech("你好", map[string]string{
+		"voice_id": "BV700_streaming",
+		"format":   "pcm",
+		"speed":    "1.2",
+	})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if string(audio) != "aabbcc" {
+		t.Errorf("audio = %q, want the three chunks up to the negative sequence", audio)
+	}
+}
+
+func TestVolcengineWebSocketError(t *testing.T) {
+	provider := fakeVolcengineWS(t, func(volcengineRequest) [][]byte {
+		return [][]byte{
+			volcAudioFrame(1, []byte("aa")),
+			volcErrorFrame(3011, "invalid text"),
+		}
+	})
+
+	_, err := provider.SynthesizeSpeech("你好", nil)
+	var volcErr *VolcengineError
+	if !errors.As(err, &volcErr) {
+		t.Fatalf("err = %v, want VolcengineError", err)
+	}
+	if volcErr.Code != 3011 || volcErr.Message != "invalid text" {
+		t.Errorf("error = %+v", volcErr)
+	}
+}
+
+func TestDecodeVolcengineResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		audio string
+		last  bool
+		fails bool
+	}{
+		{"positive sequence", volcAudioFrame(5, []byte("xy")), "xy", false, false},
+		{"negative sequence", volcAudioFrame(-6, []byte("z")), "z", true, false},
+		{"too short", []byte{0x11}, "", false, true},
+		{"truncated payload", volcAudioFrame(1, []byte("xyz"))[:14], "", false, true},
+		{"unknown type", []byte{0x11, 0x20, 0, 0}, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := decodeVolcengineResponse(tt.frame)
+			if tt.fails {
+				if err == nil {
+					t.Fatal("decode succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if string(message.audio) != tt.audio || message.last != tt.last {
+				t.Errorf("message = %q last=%v, want %q last=%v", message.audio, message.last, tt.audio, tt.last)
+			}
+		})
+	}
+}
+
+// fakeVolcengineHTTP 启动一个本地 HTTP 接口，按 code 返回 base64 音频或业务错误
+func fakeVolcengineHTTP(t *testing.T, status, code int, audio []byte) *VolcengineTTSProvider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer;test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var request volcengineRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if request.Request.Operation != "query" || request.Request.TextType != "ssml" {
+			t.Errorf("request = %+v", request.Request)
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"reqid":%q,"code":%d,"message":"msg","data":%q}`,
+			request.Request.ReqID, code, base64.StdEncoding.EncodeToString(audio))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewVolcengineTTSProvider("test-app", "test-token", "")
+	provider.SetEndpoints(server.URL, "")
+	if err := provider.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return provider
+}
+
+func TestVolcengineHTTP(t *testing.T) {
+	provider := fakeVolcengineHTTP(t, http.StatusOK, volcengineSuccessCode, []byte("mp3-bytes"))
+
+	audio, err := provider.SynthesizeSpeech("<speak>你好</speak>", map[string]string{"text_type": "ssml"})
+	if err != nil {
+		t.Fatalf("SynthesizeSpeech: %v", err)
+	}
+	if string(audio) != "mp3-bytes" {
+		t.Errorf("audio = %q", audio)
+	}
+}
+
+func TestVolcengineHTTPError(t *testing.T) {
+	provider := fakeVolcengineHTTP(t, http.StatusBadRequest, 3050, nil)
+
+	_, err := provider.SynthesizeSpeech("<speak>你好</speak>", map[string]string{"text_type": "ssml"})
+	var volcErr *VolcengineError
+	if !errors.As(err, &volcErr) || volcErr.Code != 3050 {
+		t.Fatalf("err = %v, want VolcengineError 3050", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Package usage 按设备和提供商统计大模型令牌用量，并执行每日/每月配额
+//
+// 计数器保存在 JSON 文件中，服务重启后继续累计。日/月计数按服务器本地时间自然切换。
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+// unknownDevice 是没有设备 ID 的连接使用的统计键
+const unknownDevice = "unknown"
+
+// Counter 表示一组累计的令牌用量
+type Counter struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// add 累加一次请求的用量
+func (c *Counter) add(u llm.Usage) {
+	c.Requests++
+	c.PromptTokens += int64(u.PromptTokens)
+	c.CompletionTokens += int64(u.CompletionTokens)
+	c.TotalTokens += int64(u.TotalTokens)
+}
+
+// DeviceUsage 表示单个设备的用量统计
+type DeviceUsage struct {
+	Day       string              `json:"day"` // Daily 对应的日期，格式 2006-01-02
+	Daily     Counter             `json:"daily"`
+	Month     string              `json:"month"` // Monthly 对应的月份，格式 2006-01
+	Monthly   Counter             `json:"monthly"`
+	Total     Counter             `json:"total"`
+	Providers map[string]*Counter `json:"providers"` // 按提供商拆分的累计用量
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// Quota 表示令牌配额，0 表示不限制
+type Quota struct {
+	Daily   int64 `json:"daily"`
+	Monthly int64 `json:"monthly"`
+}
+
+// QuotaError 表示设备已超出配额
+type QuotaError struct {
+	DeviceID string
+	Period   string // daily 或 monthly
+	Used     int64
+	Limit    int64
+}
+
+// Error 实现 error 接口
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("device %s exceeded %s token quota (%d/%d)", e.DeviceID, e.Period, e.Used, e.Limit)
+}
+
+// snapshot 是持久化文件的格式
+type snapshot struct {
+	Devices   map[string]*DeviceUsage `json:"devices"`
+	Providers map[string]*Counter     `json:"providers"`
+}
+
+// Tracker 记录用量并检查配额
+type Tracker struct {
+	mu           sync.Mutex
+	path         string // 持久化文件路径，为空时只在内存中统计
+	devices      map[string]*DeviceUsage
+	providers    map[string]*Counter
+	defaultQuota Quota
+	quotas       map[string]Quota // 单独设置配额的设备
+	dirty        bool
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewTracker 创建一个用量统计器，path 为空时不持久化
+func NewTracker(path string, defaultQuota Quota) *Tracker {
+	return &Tracker{
+		path:         path,
+		devices:      make(map[string]*DeviceUsage),
+		providers:    make(map[string]*Counter),
+		defaultQuota: defaultQuota,
+		quotas:       make(map[string]Quota),
+	}
+}
+
+// Load 从持久化文件加载计数器，文件不存在时从零开始
+func (t *Tracker) Load() error {
+	if t.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading usage file: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("error parsing usage file: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if snap.Devices != nil {
+		t.devices = snap.Devices
+	}
+	if snap.Providers != nil {
+		t.providers = snap.Providers
+	}
+	for _, device := range t.devices {
+		if device.Providers == nil {
+			device.Providers = make(map[string]*Counter)
+		}
+	}
+	log.Printf("[Usage] Loaded usage for %d devices from %s", len(t.devices), t.path)
+	return nil
+}
+
+// SetDeviceQuota 为单个设备设置配额，覆盖默认配额
+func (t *Tracker) SetDeviceQuota(deviceID string, quota Quota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas[deviceKey(deviceID)] = quota
+}
+
+// Record 记录一次请求的用量
+func (t *Tracker) Record(deviceID, provider string, u llm.Usage) {
+	if provider == "" {
+		provider = "unknown"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	device := t.device(deviceKey(deviceID), time.Now())
+	device.Daily.add(u)
+	device.Monthly.add(u)
+	device.Total.add(u)
+	if device.Providers[provider] == nil {
+		device.Providers[provider] = &Counter{}
+	}
+	device.Providers[provider].add(u)
+	device.UpdatedAt = time.Now()
+
+	if t.providers[provider] == nil {
+		t.providers[provider] = &Counter{}
+	}
+	t.providers[provider].add(u)
+	t.dirty = true
+}
+
+// Check 检查设备是否超出配额，超出时返回 *QuotaError
+func (t *Tracker) Check(deviceID string) error {
+	key := deviceKey(deviceID)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quota, ok := t.quotas[key]
+	if !ok {
+		quota = t.defaultQuota
+	}
+	if quota.Daily <= 0 && quota.Monthly <= 0 {
+		return nil
+	}
+
+	device := t.device(key, time.Now())
+	if quota.Daily > 0 && device.Daily.TotalTokens >= quota.Daily {
+		return &QuotaError{DeviceID: key, Period: "daily", Used: device.Daily.TotalTokens, Limit: quota.Daily}
+	}
+	if quota.Monthly > 0 && device.Monthly.TotalTokens >= quota.Monthly {
+		return &QuotaError{DeviceID: key, Period: "monthly", Used: device.Monthly.TotalTokens, Limit: quota.Monthly}
+	}
+	return nil
+}
+
+// Devices 返回所有设备用量的副本
+func (t *Tracker) Devices() map[string]DeviceUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	result := make(map[string]DeviceUsage, len(t.devices))
+	for key := range t.devices {
+		result[key] = copyDevice(t.device(key, now))
+	}
+	return result
+}
+
+// Device 返回单个设备用量的副本
+func (t *Tracker) Device(deviceID string) (DeviceUsage, bool) {
+	key := deviceKey(deviceID)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, exists := t.devices[key]; !exists {
+		return DeviceUsage{}, false
+	}
+	return copyDevice(t.device(key, time.Now())), true
+}
+
+// Providers 返回按提供商汇总的用量副本
+func (t *Tracker) Providers() map[string]Counter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string]Counter, len(t.providers))
+	for name, counter := range t.providers {
+		result[name] = *counter
+	}
+	return result
+}
+
+// Save 把计数器写入持久化文件，没有变化时跳过
+func (t *Tracker) Save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(snapshot{Devices: t.devices, Providers: t.providers}, "", "  ")
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding usage: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("error creating usage directory: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免写到一半时进程退出导致文件损坏
+	tmpPath := t.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing usage file: %w", err)
+	}
+	if err := os.Rename(tmpPath, t.path); err != nil {
+		return fmt.Errorf("error replacing usage file: %w", err)
+	}
+	return nil
+}
+
+// StartAutoSave 按固定间隔在后台保存计数器
+func (t *Tracker) StartAutoSave(interval time.Duration) {
+	if t.path == "" || interval <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	if t.stop != nil {
+		t.mu.Unlock()
+		return
+	}
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	stop, done := t.stop, t.done
+	t.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Save(); err != nil {
+					log.Printf("[Usage] Failed to save usage: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止后台保存并写入最后一次计数
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	stop, done := t.stop, t.done
+	t.stop, t.done = nil, nil
+	t.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return t.Save()
+}
+
+// device 返回设备的用量记录，并在跨日/跨月时重置对应计数（调用方需持有锁）
+func (t *Tracker) device(key string, now time.Time) *DeviceUsage {
+	device, exists := t.devices[key]
+	if !exists {
+		device = &DeviceUsage{Providers: make(map[string]*Counter)}
+		t.devices[key] = device
+	}
+
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+	if device.Day != day {
+		device.Day = day
+		device.Daily = Counter{}
+	}
+	if device.Month != month {
+		device.Month = month
+		device.Monthly = Counter{}
+	}
+	return device
+}
+
+// copyDevice 深拷贝设备用量，避免调用方持有内部指针
+func copyDevice(device *DeviceUsage) DeviceUsage {
+	result := *device
+	result.Providers = make(map[string]*Counter, len(device.Providers))
+	for name, counter := range device.Providers {
+		c := *counter
+		result.Providers[name] = &c
+	}
+	return result
+}
+
+// deviceKey 返回设备的统计键
+func deviceKey(deviceID string) string {
+	if deviceID == "" {
+		return unknownDevice
+	}
+	return deviceID
+}
+
+// ParseQuotaSpec 解析 "设备ID:每日配额:每月配额" 格式的单设备配额，配额留空或为 0 表示不限制
+// 设备ID通常是含冒号的 MAC 地址，因此从右侧取最后两个字段作为配额
+func ParseQuotaSpec(spec string) (string, Quota, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 3 {
+		return "", Quota{}, fmt.Errorf("invalid quota spec %q, expected device:daily:monthly", spec)
+	}
+	deviceID := strings.TrimSpace(strings.Join(parts[:len(parts)-2], ":"))
+	if deviceID == "" {
+		return "", Quota{}, fmt.Errorf("invalid quota spec %q, expected device:daily:monthly", spec)
+	}
+	parts = parts[len(parts)-3:]
+
+	var limits [2]int64
+	for i, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || value < 0 {
+			return "", Quota{}, fmt.Errorf("invalid quota value %q in %q", part, spec)
+		}
+		limits[i] = value
+	}
+
+	return deviceID, Quota{Daily: limits[0], Monthly: limits[1]}, nil
+}
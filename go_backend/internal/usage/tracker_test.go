@@ -0,0 +1,146 @@
+package usage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xiaozhi-esp32-server/go_backend/internal/llm"
+)
+
+func TestParseQuotaSpec(t *testing.T) {
+	tests := []struct {
+		spec   string
+		device string
+		quota  Quota
+		fails  bool
+	}{
+		{spec: "device-1:1000:20000", device: "device-1", quota: Quota{Daily: 1000, Monthly: 20000}},
+		{spec: "AA:BB:CC:DD:EE:FF:500:", device: "AA:BB:CC:DD:EE:FF", quota: Quota{Daily: 500}},
+		{spec: " aa:bb:cc:dd:ee:ff : : 9000 ", device: "aa:bb:cc:dd:ee:ff", quota: Quota{Monthly: 9000}},
+		{spec: "device-1:1000", fails: true},
+		{spec: ":1000:2000", fails: true},
+		{spec: "device-1:many:2000", fails: true},
+		{spec: "device-1:-1:2000", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			device, quota, err := ParseQuotaSpec(tt.spec)
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("parsed %q as %q %+v, want error", tt.spec, device, quota)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuotaSpec: %v", err)
+			}
+			if device != tt.device || quota != tt.quota {
+				t.Errorf("got %q %+v, want %q %+v", device, quota, tt.device, tt.quota)
+			}
+		})
+	}
+}
+
+func TestTrackerQuota(t *testing.T) {
+	tracker := NewTracker("", Quota{Daily: 100, Monthly: 250})
+	tracker.SetDeviceQuota("vip", Quota{})
+
+	tracker.Record("dev", "deepseek", llm.Usage{PromptTokens: 60, CompletionTokens: 30, TotalTokens: 90})
+	if err := tracker.Check("dev"); err != nil {
+		t.Fatalf("Check under quota: %v", err)
+	}
+
+	tracker.Record("dev", "intent", llm.Usage{PromptTokens: 8, CompletionTokens: 2, TotalTokens: 10})
+	var quotaErr *QuotaError
+	if err := tracker.Check("dev"); !errors.As(err, &quotaErr) || quotaErr.Period != "daily" || quotaErr.Used != 100 || quotaErr.Limit != 100 {
+		t.Fatalf("Check at daily limit = %v", err)
+	}
+
+	// 单独设置的配额覆盖默认配额
+	tracker.Record("vip", "deepseek", llm.Usage{TotalTokens: 1000})
+	if err := tracker.Check("vip"); err != nil {
+		t.Errorf("device without limits: %v", err)
+	}
+
+	device, ok := tracker.Device("dev")
+	if !ok {
+		t.Fatal("device not found")
+	}
+	if device.Total != (Counter{Requests: 2, PromptTokens: 68, CompletionTokens: 32, TotalTokens: 100}) {
+		t.Errorf("total = %+v", device.Total)
+	}
+	if device.Providers["deepseek"].TotalTokens != 90 || device.Providers["intent"].TotalTokens != 10 {
+		t.Errorf("providers = %+v", device.Providers)
+	}
+	if providers := tracker.Providers(); providers["deepseek"].TotalTokens != 1090 {
+		t.Errorf("provider totals = %+v", providers)
+	}
+}
+
+func TestTrackerRollover(t *testing.T) {
+	tracker := NewTracker("", Quota{Daily: 100, Monthly: 150})
+	tracker.Record("dev", "deepseek", llm.Usage{TotalTokens: 100})
+
+	// 把记录改到前一天，日计数清零，月计数保留
+	tracker.devices["dev"].Day = "2000-01-01"
+	if err := tracker.Check("dev"); err != nil {
+		t.Fatalf("Check on a new day: %v", err)
+	}
+	tracker.Record("dev", "deepseek", llm.Usage{TotalTokens: 60})
+	var quotaErr *QuotaError
+	if err := tracker.Check("dev"); !errors.As(err, &quotaErr) || quotaErr.Period != "monthly" || quotaErr.Used != 160 {
+		t.Fatalf("Check at monthly limit = %v", err)
+	}
+
+	// 跨月后日、月计数都清零，累计用量不变
+	tracker.devices["dev"].Day = "2000-01-31"
+	tracker.devices["dev"].Month = "2000-01"
+	if err := tracker.Check("dev"); err != nil {
+		t.Fatalf("Check in a new month: %v", err)
+	}
+	device, _ := tracker.Device("dev")
+	if device.Daily.TotalTokens != 0 || device.Monthly.TotalTokens != 0 || device.Total.TotalTokens != 160 {
+		t.Errorf("after rollover daily %d monthly %d total %d", device.Daily.TotalTokens, device.Monthly.TotalTokens, device.Total.TotalTokens)
+	}
+}
+
+func TestTrackerSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "usage.json")
+
+	tracker := NewTracker(path, Quota{Daily: 100})
+	tracker.Record("aa:bb:cc:dd:ee:ff", "deepseek", llm.Usage{PromptTokens: 70, CompletionTokens: 30, TotalTokens: 100})
+	tracker.Record("", "", llm.Usage{TotalTokens: 5})
+	if err := tracker.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	loaded := NewTracker(path, Quota{Daily: 100})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	device, ok := loaded.Device("aa:bb:cc:dd:ee:ff")
+	if !ok || device.Daily.TotalTokens != 100 || device.Providers["deepseek"].PromptTokens != 70 {
+		t.Fatalf("loaded device = %+v", device)
+	}
+	if _, ok := loaded.Device(""); !ok {
+		t.Error("usage without a device ID was not persisted")
+	}
+	if providers := loaded.Providers(); providers["unknown"].TotalTokens != 5 {
+		t.Errorf("loaded providers = %+v", providers)
+	}
+	// 持久化的日计数在重启后继续参与配额检查
+	if err := loaded.Check("aa:bb:cc:dd:ee:ff"); err == nil {
+		t.Error("quota was reset by reloading")
+	}
+
+	// 文件不存在时从零开始
+	if err := NewTracker(filepath.Join(t.TempDir(), "missing.json"), Quota{}).Load(); err != nil {
+		t.Errorf("Load without a file: %v", err)
+	}
+}